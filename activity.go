@@ -0,0 +1,95 @@
+package main
+
+import (
+	"regexp"
+)
+
+// What kind of work a window is for
+const (
+	ActivityCoding        = "coding"
+	ActivityReviewing     = "reviewing"
+	ActivityDocs          = "docs"
+	ActivityCommunication = "communication"
+	ActivityMeetings      = "meetings"
+	ActivityEntertainment = "entertainment"
+	ActivitySystem        = "system"
+	ActivityUncategorized = "uncategorized"
+)
+
+var activities = map[string]bool{
+	ActivityCoding: true, ActivityReviewing: true, ActivityDocs: true,
+	ActivityCommunication: true, ActivityMeetings: true, ActivityEntertainment: true,
+	ActivitySystem: true, ActivityUncategorized: true,
+}
+
+// What time on each kind of site is spent doing
+var siteActivities = map[string]string{
+	SiteDocs:         ActivityDocs,
+	SiteCodeReview:   ActivityReviewing,
+	SiteIssueTracker: ActivityReviewing,
+	SiteCodeHosting:  ActivityReviewing,
+	SiteSocial:       ActivityEntertainment,
+	SiteVideo:        ActivityEntertainment,
+}
+
+// ActivityRule puts windows into an activity. Every pattern that is set
+// has to match.
+type ActivityRule struct {
+	Name         string
+	Activity     string
+	Program      *regexp.Regexp
+	Title        *regexp.Regexp
+	Project      *regexp.Regexp
+	SiteCategory string
+}
+
+func (r *ActivityRule) matches(ctx *Context) bool {
+	if r.Program != nil && !r.Program.MatchString(ctx.Program) {
+		return false
+	}
+	if r.Title != nil && !r.Title.MatchString(ctx.WindowTitle) {
+		return false
+	}
+	if r.Project != nil && !matchesProject(r.Project, ctx) {
+		return false
+	}
+	if r.SiteCategory != "" && r.SiteCategory != ctx.SiteCategory {
+		return false
+	}
+	return true
+}
+
+// matchesProject tries every name the project goes by.
+func matchesProject(pattern *regexp.Regexp, ctx *Context) bool {
+	for _, name := range []string{ctx.ProjectName, ctx.RepoName, ctx.GitRoot, ctx.ProjectPath} {
+		if name != "" && pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyActivity decides what kind of work the context is. Rules come
+// first, then what the site or program already says about it.
+func (cd *ContextDetector) classifyActivity(ctx *Context) string {
+	for _, rule := range cd.activityRules {
+		if rule.matches(ctx) {
+			return rule.Activity
+		}
+	}
+
+	if activity, ok := siteActivities[ctx.SiteCategory]; ok {
+		return activity
+	}
+	if ctx.IsProgramming {
+		return ActivityCoding
+	}
+	// A shell inside a repository is usually part of working on it
+	if ctx.Terminal != "" {
+		if ctx.GitRoot != "" {
+			return ActivityCoding
+		}
+		return ActivitySystem
+	}
+	return ActivityUncategorized
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestClassifyActivity(t *testing.T) {
+	config := DefaultConfig()
+	config.merge(&Config{Activities: []ActivityRuleConfig{
+		{Name: "dotfiles", Activity: "system", Project: `^dotfiles$`},
+		{Name: "work-chat", Activity: "communication", Program: `^firefox$`, Title: `(?i)mattermost`},
+		{Name: "reading-code", Activity: "docs", Program: `^firefox$`, SiteCategory: SiteCodeHosting},
+	}})
+	cd := NewContextDetector(config)
+
+	tests := []struct {
+		name string
+		ctx  Context
+		want string
+	}{
+		{"editor", Context{Program: "vim", IsProgramming: true}, ActivityCoding},
+		{"chat app", Context{Program: "slack"}, ActivityCommunication},
+		{"mail in a terminal", Context{Program: "neomutt", Terminal: "kitty"}, ActivityCommunication},
+		{"meeting app", Context{Program: "zoom"}, ActivityMeetings},
+		{"web meeting", Context{Program: "firefox", WindowTitle: "Meet – abc-defg-hij — Mozilla Firefox"}, ActivityMeetings},
+		{"web mail", Context{Program: "chrome", WindowTitle: "Inbox (3) - me@example.com - Gmail"}, ActivityCommunication},
+		{"media player", Context{Program: "mpv"}, ActivityEntertainment},
+		{"file manager", Context{Program: "nautilus"}, ActivitySystem},
+
+		{"docs site", Context{Program: "firefox", SiteCategory: SiteDocs}, ActivityDocs},
+		{"pull request", Context{Program: "firefox", SiteCategory: SiteCodeReview}, ActivityReviewing},
+		{"issue", Context{Program: "firefox", SiteCategory: SiteIssueTracker}, ActivityReviewing},
+		{"social site", Context{Program: "firefox", SiteCategory: SiteSocial}, ActivityEntertainment},
+		{"video site", Context{Program: "firefox", SiteCategory: SiteVideo}, ActivityEntertainment},
+		{"unknown site", Context{Program: "firefox", Site: "example.com"}, ActivityUncategorized},
+
+		{"shell in a repository", Context{Program: "bash", Terminal: "kitty", GitRoot: "/src/app"}, ActivityCoding},
+		{"shell elsewhere", Context{Program: "bash", Terminal: "kitty"}, ActivitySystem},
+		{"unknown program", Context{Program: "gimp"}, ActivityUncategorized},
+
+		// Rules come before what the program or site says
+		{"rule by project name", Context{Program: "vim", IsProgramming: true, RepoName: "dotfiles"}, ActivitySystem},
+		{"rule by project path", Context{Program: "vim", IsProgramming: true, ProjectPath: "dotfiles"}, ActivitySystem},
+		{"rule needs every field", Context{Program: "chrome", WindowTitle: "Mattermost"}, ActivityUncategorized},
+		{"rule with program and title", Context{Program: "firefox", WindowTitle: "Town Square - Mattermost"}, ActivityCommunication},
+		{"rule with a site category", Context{Program: "firefox", SiteCategory: SiteCodeHosting}, ActivityDocs},
+		{"rule site category not matching", Context{Program: "chrome", SiteCategory: SiteCodeHosting}, ActivityReviewing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cd.classifyActivity(&tt.ctx); got != tt.want {
+				t.Errorf("classifyActivity(%+v) = %q, want %q", tt.ctx, got, tt.want)
+			}
+		})
+	}
+}
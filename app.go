@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"strings"
 	"time"
 )
 
@@ -11,6 +12,10 @@ type NotificationTiming struct {
 	// WindowCheckInterval is how often to check for active window changes
 	WindowCheckInterval time.Duration
 
+	// FocusEventPollInterval is how often the tracker is still polled when it
+	// pushes focus events, to catch changes the events don't report
+	FocusEventPollInterval time.Duration
+
 	// TimeBasedNotifications configures time-based coding notifications
 	TimeBasedNotifications struct {
 		// Intervals are the time milestones to trigger notifications (e.g., 30min, 1hr, 2hr)
@@ -32,12 +37,28 @@ type NotificationTiming struct {
 		// Interval between health reminder notifications
 		Interval time.Duration
 	}
+
+	// Idle configures away-from-keyboard detection
+	Idle struct {
+		// Threshold is how long without input before the user counts as away
+		Threshold time.Duration
+	}
+
+	// Actions configures what the buttons on notifications do
+	Actions struct {
+		// Snooze is how long "Snooze" silences that kind of notification
+		Snooze time.Duration
+		// BreakGrace is how long after "I'm on a break" input is taken as
+		// the user getting up rather than coming back
+		BreakGrace time.Duration
+	}
 }
 
 // DefaultNotificationTiming returns sensible default timing configuration
 func DefaultNotificationTiming() *NotificationTiming {
 	nt := &NotificationTiming{
-		WindowCheckInterval: 5 * time.Second,
+		WindowCheckInterval:    5 * time.Second,
+		FocusEventPollInterval: 1 * time.Minute,
 	}
 
 	// Time-based: notify at 30min, 1hr, 2hr, 3hr, etc.
@@ -57,20 +78,69 @@ func DefaultNotificationTiming() *NotificationTiming {
 	// Health reminders: every 20 minutes
 	nt.HealthReminders.Interval = 2 * time.Minute
 
+	// Away after 5 minutes without input
+	nt.Idle.Threshold = 5 * time.Minute
+
+	nt.Actions.Snooze = 30 * time.Minute
+	nt.Actions.BreakGrace = 1 * time.Minute
+
 	return nt
 }
 
 type EmotionalSupportApp struct {
-	tracker   *WindowTracker
+	tracker   WindowTracker
 	detector  *ContextDetector
 	messenger *MessageGenerator
-	notifier  *Notifier
+	notifier  Notifier
 	state     *AppState
 	timing    *NotificationTiming
 	database  *Database
+	idle      IdleDetector
+	heartbeat *HeartbeatServer // nil unless enabled in the config
+	meetings  *MeetingDetector // nil unless enabled in the config
+
+	// The window currently being timed
+	lastWindow           string
+	lastWindowTime       time.Time
+	lastContext          *Context
+	lastWindowInfo       *WindowInfo
+	lastNotificationTime map[string]time.Time
+
+	// Why the user is away, empty while they are at the computer
+	awayReason string
+	awaySince  time.Time
+	locked     bool
+	asleep     bool
+
+	// What shows the user is in a meeting, empty when they aren't
+	meeting string
+
+	// Notifications held back during a call, while the focused window is
+	// fullscreen or while the desktop is in do-not-disturb mode, and every
+	// reason there was to hold them
+	fullscreen bool
+	dnd        DNDChecker // nil unless enabled in the config
+	dndDigest  bool
+	held       []*NotificationLog
+	heldWhile  map[string]bool
+
+	// Until when the user silenced each notification type, or each
+	// language's messages, from a notification's buttons
+	snoozed        map[string]time.Time
+	mutedLanguages map[string]time.Time
+
+	// When encouragement is welcome, and the end of the last working day
+	// that was wrapped up
+	schedule  *Schedule // nil unless set in the config
+	wrappedUp time.Time
 }
 
-func NewEmotionalSupportApp() *EmotionalSupportApp {
+func NewEmotionalSupportApp() (*EmotionalSupportApp, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	state, err := LoadState()
 	if err != nil {
 		log.Printf("Warning: Could not load state: %v", err)
@@ -83,34 +153,71 @@ func NewEmotionalSupportApp() *EmotionalSupportApp {
 		database = nil
 	}
 
+	notifier, err := NewNotifier(config.Notifications)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up notifications: %w", err)
+	}
+
+	detector := NewContextDetector(config)
+	var heartbeat *HeartbeatServer
+	if detector.heartbeats != nil {
+		heartbeat = NewHeartbeatServer(detector.heartbeats, config.Heartbeat.Listen)
+	}
+	var meetings *MeetingDetector
+	if config.Meetings != nil && config.Meetings.Enabled {
+		meetings = NewMeetingDetector(config.Meetings)
+	}
+	var schedule *Schedule
+	if config.Schedule != nil {
+		schedule = NewSchedule(config.Schedule)
+	}
+	var dnd DNDChecker
+	if config.DoNotDisturb != nil && config.DoNotDisturb.Enabled {
+		dnd, _ = notifier.(DNDChecker)
+	}
+
 	return &EmotionalSupportApp{
 		tracker:   NewWindowTracker(),
-		detector:  NewContextDetector(),
+		detector:  detector,
 		messenger: NewMessageGenerator(),
-		notifier:  NewNotifier(),
+		notifier:  notifier,
 		state:     state,
 		timing:    DefaultNotificationTiming(),
 		database:  database,
-	}
+		idle:      NewIdleDetector(),
+		heartbeat: heartbeat,
+		meetings:  meetings,
+		dnd:       dnd,
+		dndDigest: config.DoNotDisturb != nil && config.DoNotDisturb.Digest,
+		schedule:  schedule,
+
+		lastWindowTime:       time.Now(),
+		lastContext:          &Context{},
+		lastWindowInfo:       &WindowInfo{},
+		lastNotificationTime: make(map[string]time.Time),
+		snoozed:              make(map[string]time.Time),
+		mutedLanguages:       make(map[string]time.Time),
+	}, nil
 }
 
 func (app *EmotionalSupportApp) Run() error {
 	log.Println("Starting Emotional Support Activity Tracker...")
 
-	// Send initial welcome message
+	if app.heartbeat != nil {
+		app.heartbeat.Start()
+	}
+
+	// Send initial welcome message, unless that would interrupt a call
+	app.checkMeeting(time.Now())
 	welcomeMsg := "I'm here to support you! Let's have a great coding session! 💚"
-	if err := app.notifier.Send("Emotional Support", welcomeMsg, ""); err != nil {
-		log.Printf("Warning: Could not send welcome notification: %v", err)
-	} else if app.database != nil {
-		// Log welcome notification
-		notif := &NotificationLog{
+	if app.meeting != "" {
+		log.Printf("Not sending the welcome message during a meeting")
+	} else {
+		app.notify(&NotificationLog{
 			Type:    "welcome",
 			Title:   "Emotional Support",
 			Message: welcomeMsg,
-		}
-		if err := app.database.LogNotification(notif); err != nil {
-			log.Printf("Error logging welcome notification: %v", err)
-		}
+		}, time.Now())
 	}
 
 	// Ensure database is closed on exit
@@ -125,92 +232,437 @@ func (app *EmotionalSupportApp) Run() error {
 	ticker := time.NewTicker(app.timing.WindowCheckInterval)
 	defer ticker.Stop()
 
-	lastWindow := ""
-	lastWindowTime := time.Now()
-	lastContext := &Context{}
-	lastWindowInfo := &WindowInfo{}
-	lastNotificationTime := make(map[string]time.Time)
+	done := make(chan struct{})
+	defer close(done)
+	focusEvents := app.watchFocus(done)
+	lastPoll := time.Time{}
+
+	sessionEvents, err := WatchSessionEvents(done)
+	if err != nil {
+		log.Printf("Warning: Could not watch for screen lock and suspend: %v", err)
+	}
+	responses := app.watchResponses(done)
 
 	for {
 		select {
-		case <-ticker.C:
-			windowInfo, err := app.tracker.GetActiveWindow()
-			if err != nil {
-				// Log but don't spam - only log occasionally
-				// This can happen in i3 when switching windows quickly
-				log.Printf("Warning: Could not get active window: %v", err)
+		case windowInfo, ok := <-focusEvents:
+			if !ok {
+				log.Println("Warning: Focus events stopped, falling back to polling")
+				focusEvents = nil
 				continue
 			}
+			app.handleFocusEvent(windowInfo, time.Now())
 
-			// Log window check to database
-			if app.database != nil {
-				check := &WindowCheck{
-					WindowKey:   fmt.Sprintf("%s|%s", windowInfo.Process, windowInfo.Title),
-					Program:     windowInfo.Process,
-					WindowTitle: windowInfo.Title,
-					ProcessName: windowInfo.Process,
-					PID:         windowInfo.PID,
-				}
-				if err := app.database.LogWindowCheck(check); err != nil {
-					log.Printf("Error logging window check: %v", err)
-				}
+		case response, ok := <-responses:
+			if !ok {
+				responses = nil
+				continue
 			}
+			app.handleResponse(response)
 
-			// Detect context
-			context := app.detector.DetectContext(windowInfo)
-
-			// Check if window changed
-			windowKey := fmt.Sprintf("%s|%s", context.Program, context.WindowTitle)
-			if windowKey != lastWindow {
-				// Save time spent in previous window
-				if lastWindow != "" {
-					duration := time.Since(lastWindowTime)
-					app.state.RecordActivity(lastWindow, duration)
-					if err := app.state.Save(); err != nil {
-						log.Printf("Error saving state: %v", err)
-					}
+		case event, ok := <-sessionEvents:
+			if !ok {
+				sessionEvents = nil
+				continue
+			}
+			back := app.handleSessionEvent(event)
+			// The session is closed, so the machine can go to sleep
+			event.Release()
+			if back {
+				// Back at the computer, so look at what's focused right away
+				lastPoll = event.At
+				app.pollWindow(event.At)
+			}
 
-					// Log window session to database (using previous window's context)
-					if app.database != nil {
-						session := &WindowSession{
-							WindowKey:     lastWindow,
-							Program:       lastContext.Program,
-							WindowTitle:    lastContext.WindowTitle,
-							ProcessName:    lastWindowInfo.Process,
-							PID:           lastWindowInfo.PID,
-							Language:      lastContext.Language,
-							IsProgramming: lastContext.IsProgramming,
-							StartedAt:      lastWindowTime,
-							EndedAt:        time.Now(),
-							Duration:       duration,
-							ProjectPath:    lastContext.ProjectPath,
-						}
-						if err := app.database.LogWindowSession(session); err != nil {
-							log.Printf("Error logging window session: %v", err)
-						}
-					}
+		case now := <-ticker.C:
+			wasAway := app.awayReason != ""
+			if app.checkIdle(now) {
+				// Nothing is timed or celebrated while the user is away
+				continue
+			}
+
+			// With focus events we only poll occasionally as a safety net,
+			// but always look again when the user comes back
+			if focusEvents == nil || wasAway || now.Sub(lastPoll) >= app.timing.FocusEventPollInterval {
+				lastPoll = now
+				if !app.pollWindow(now) {
+					continue
 				}
+			}
 
-				// Update tracking variables
-				lastWindow = windowKey
-				lastWindowTime = time.Now()
-				lastContext = context
-				lastWindowInfo = windowInfo
+			if app.lastWindow == "" {
+				continue
 			}
 
+			// Nothing pops up during a call; it's held until the call ends
+			app.checkMeeting(now)
+
+			// Sum up the working day once it's over
+			app.checkWrapUp(now)
+
+			// Catch up on what was held back
+			app.catchUpHeld(now)
+
 			// Calculate time spent in current window
-			currentDuration := time.Since(lastWindowTime)
+			currentDuration := now.Sub(app.lastWindowTime)
 
 			// Generate and send notifications based on context and time
-			app.checkAndNotify(context, currentDuration, lastNotificationTime)
+			app.checkAndNotify(app.lastContext, currentDuration, app.lastNotificationTime, now)
 		}
 	}
 }
 
-func (app *EmotionalSupportApp) checkAndNotify(context *Context, duration time.Duration, lastNotificationTime map[string]time.Time) {
-	now := time.Now()
+// watchFocus subscribes to focus events if the tracker supports them. A nil
+// channel is returned otherwise, which leaves the loop polling.
+func (app *EmotionalSupportApp) watchFocus(done <-chan struct{}) <-chan *WindowInfo {
+	watcher, ok := app.tracker.(FocusWatcher)
+	if !ok {
+		return nil
+	}
+	events, err := watcher.WatchFocus(done)
+	if err != nil {
+		log.Printf("Warning: Could not watch focus changes, polling instead: %v", err)
+		return nil
+	}
+	return events
+}
+
+// watchResponses subscribes to what the user does with notifications if the
+// notifier can tell. A nil channel is returned otherwise.
+func (app *EmotionalSupportApp) watchResponses(done <-chan struct{}) <-chan NotificationResponse {
+	watcher, ok := app.notifier.(ResponseWatcher)
+	if !ok {
+		return nil
+	}
+	responses, err := watcher.WatchResponses(done)
+	if err != nil {
+		log.Printf("Warning: Could not watch notification responses, buttons won't work: %v", err)
+		return nil
+	}
+	return responses
+}
+
+// pollWindow asks the tracker for the active window and handles it,
+// reporting whether that worked.
+func (app *EmotionalSupportApp) pollWindow(now time.Time) bool {
+	windowInfo, err := app.tracker.GetActiveWindow()
+	if err != nil {
+		// Log but don't spam - only log occasionally
+		// This can happen in i3 when switching windows quickly
+		log.Printf("Warning: Could not get active window: %v", err)
+		return false
+	}
+	app.handleWindow(windowInfo, now)
+	return true
+}
+
+// handleFocusEvent handles a focus change the tracker pushed at now.
+func (app *EmotionalSupportApp) handleFocusEvent(windowInfo *WindowInfo, now time.Time) {
+	// Switching windows is proof enough that the user is back, unless
+	// they're only just leaving for a break
+	if app.awayReason == "idle" || (app.awayReason == "break" && now.Sub(app.awaySince) > app.timing.Actions.BreakGrace) {
+		app.endAway(now)
+	}
+	// The lock screen, waking up or leaving for a break shouldn't start a
+	// session the user isn't there for
+	if app.awayReason != "" {
+		return
+	}
+	app.handleWindow(windowInfo, now)
+}
+
+// handleWindow records an observation of the active window made at now,
+// closing the previous window's session if focus moved.
+func (app *EmotionalSupportApp) handleWindow(windowInfo *WindowInfo, now time.Time) {
+	// Log window check to database
+	if app.database != nil {
+		check := &WindowCheck{
+			WindowKey:   fmt.Sprintf("%s|%s", windowInfo.Process, windowInfo.Title),
+			Program:     windowInfo.Process,
+			WindowTitle: windowInfo.Title,
+			ProcessName: windowInfo.Process,
+			PID:         windowInfo.PID,
+		}
+		if err := app.database.LogWindowCheck(check); err != nil {
+			log.Printf("Error logging window check: %v", err)
+		}
+	}
+
+	// Detect context
+	context := app.detector.DetectContext(windowInfo)
+
+	// Going fullscreen leaves the window the same but makes popups intrusive
+	if windowInfo.Fullscreen != app.fullscreen {
+		if windowInfo.Fullscreen {
+			log.Printf("%s is fullscreen, holding notifications", context.Program)
+		}
+		app.fullscreen = windowInfo.Fullscreen
+	}
+
+	// Check if window changed
+	windowKey := fmt.Sprintf("%s|%s", context.Program, context.WindowTitle)
+	if windowKey == app.lastWindow {
+		return
+	}
+
+	app.endSession(now)
+
+	// Update tracking variables
+	app.lastWindow = windowKey
+	app.lastWindowTime = now
+	app.lastContext = context
+	app.lastWindowInfo = windowInfo
+
+	// Focusing a meeting window starts the meeting along with the session
+	app.checkMeeting(now)
+}
+
+// endSession saves the time spent in the current window up to now.
+func (app *EmotionalSupportApp) endSession(now time.Time) {
+	if app.lastWindow != "" {
+		duration := now.Sub(app.lastWindowTime)
+		// Without a state file, only the database keeps the session
+		if app.state != nil {
+			app.state.RecordActivity(app.lastWindow, duration)
+			if err := app.state.Save(); err != nil {
+				log.Printf("Error saving state: %v", err)
+			}
+		}
+
+		// Log window session to database (using previous window's context)
+		if app.database != nil {
+			session := &WindowSession{
+				WindowKey:     app.lastWindow,
+				Program:       app.lastContext.Program,
+				WindowTitle:   app.lastContext.WindowTitle,
+				ProcessName:   app.lastWindowInfo.Process,
+				PID:           app.lastWindowInfo.PID,
+				Language:      app.lastContext.Language,
+				IsProgramming: app.lastContext.IsProgramming,
+				StartedAt:     app.lastWindowTime,
+				EndedAt:       now,
+				Duration:      duration,
+				ProjectPath:   app.lastContext.ProjectPath,
+				RepoRoot:      app.lastContext.GitRoot,
+				RepoName:      app.lastContext.RepoName,
+				GitBranch:     app.lastContext.GitBranch,
+				GitRemote:     app.lastContext.GitRemote,
+				Site:          app.lastContext.Site,
+				SiteCategory:  app.lastContext.SiteCategory,
+				Activity:      app.lastContext.Activity,
+				IsMeeting:     app.meeting != "",
+			}
+			if err := app.database.LogWindowSession(session); err != nil {
+				log.Printf("Error logging window session: %v", err)
+			}
+		}
+	}
+
+	app.lastWindow = ""
+}
+
+// checkIdle updates the away state from the idle detector and reports
+// whether the user is currently away.
+func (app *EmotionalSupportApp) checkIdle(now time.Time) bool {
+	// Locking and sleeping take precedence over input idleness
+	if app.locked || app.asleep {
+		return true
+	}
+	if app.idle == nil {
+		return app.awayReason != ""
+	}
+
+	idle, err := app.idle.IdleTime()
+	if err != nil {
+		return app.awayReason != ""
+	}
+
+	// A break is over at the first input once the user has had time to
+	// get up
+	if app.awayReason == "break" {
+		if back := now.Add(-idle); back.Sub(app.awaySince) > app.timing.Actions.BreakGrace {
+			app.endAway(back)
+		}
+		return app.awayReason != ""
+	}
+
+	// Sitting still through a meeting isn't being away
+	threshold := app.timing.Idle.Threshold
+	if app.awayReason == "" && idle >= threshold && app.meeting == "" {
+		// The user really left when they last touched anything
+		app.startAway("idle", now.Add(-idle))
+	} else if app.awayReason == "idle" && idle < threshold {
+		app.endAway(now.Add(-idle))
+	}
+	return app.awayReason != ""
+}
+
+// handleSessionEvent logs a lock or sleep transition and moves the away
+// state along with it. It reports whether the user has just come back.
+func (app *EmotionalSupportApp) handleSessionEvent(event SessionEvent) bool {
+	if app.database != nil {
+		if err := app.database.LogSessionEvent(&event); err != nil {
+			log.Printf("Error logging session event: %v", err)
+		}
+	}
+
+	switch event.Kind {
+	case "lock":
+		app.locked = true
+	case "unlock":
+		app.locked = false
+	case "sleep":
+		app.asleep = true
+	case "resume":
+		app.asleep = false
+	}
+
+	// A machine usually locks before it sleeps and resumes before it
+	// unlocks, so sleeping wins while both are true
+	reason := ""
+	if app.asleep {
+		reason = "sleep"
+	} else if app.locked {
+		reason = "lock"
+	}
+
+	switch {
+	case reason == app.awayReason:
+		return false
+	case reason == "":
+		// Idleness is left for checkIdle to resolve
+		if app.awayReason == "idle" {
+			return false
+		}
+		app.endAway(event.At)
+		return true
+	default:
+		if app.awayReason != "" {
+			app.endAway(event.At)
+		}
+		app.startAway(reason, event.At)
+		return false
+	}
+}
+
+// startAway closes the current session at the moment the user left.
+func (app *EmotionalSupportApp) startAway(reason string, since time.Time) {
+	// The current session may have begun after the user stopped typing,
+	// when a meeting ended
+	if app.lastWindow != "" && since.Before(app.lastWindowTime) {
+		since = app.lastWindowTime
+	}
+	log.Printf("User is away (%s) since %s", reason, since.Format(time.Kitchen))
+	app.endSession(since)
+	app.awayReason = reason
+	app.awaySince = since
+}
+
+// endAway records the away period; the next window observation starts a
+// fresh session.
+func (app *EmotionalSupportApp) endAway(now time.Time) {
+	log.Printf("User is back after %s away (%s)", now.Sub(app.awaySince).Round(time.Second), app.awayReason)
+	if app.database != nil {
+		period := &AwayPeriod{
+			Reason:    app.awayReason,
+			StartedAt: app.awaySince,
+			EndedAt:   now,
+			Duration:  now.Sub(app.awaySince),
+		}
+		if err := app.database.LogAwayPeriod(period); err != nil {
+			log.Printf("Error logging away period: %v", err)
+		}
+	}
+	app.awayReason = ""
+}
+
+// checkMeeting looks for a meeting in the focused window and microphone
+// use. When one starts or ends, the current session is split there so
+// meeting time is recorded on its own.
+func (app *EmotionalSupportApp) checkMeeting(now time.Time) {
+	if app.meetings == nil {
+		return
+	}
+	meeting := app.meetings.Detect(app.lastContext, now)
+	if (meeting != "") == (app.meeting != "") {
+		app.meeting = meeting
+		return
+	}
+
+	if meeting != "" {
+		log.Printf("Meeting detected (%s), holding notifications", meeting)
+	} else {
+		log.Printf("Meeting over, notifications resume")
+	}
+	app.splitSession(now)
+	app.meeting = meeting
+}
+
+// splitSession ends the current session at now and starts timing the same
+// window again from there.
+func (app *EmotionalSupportApp) splitSession(now time.Time) {
+	if app.lastWindow == "" || !now.After(app.lastWindowTime) {
+		return
+	}
+	window := app.lastWindow
+	app.endSession(now)
+	app.lastWindow = window
+	app.lastWindowTime = now
+}
+
+// checkWrapUp sends a summary of the working day once it has ended, if the
+// schedule asks for one. Run only while the user is here, it waits for
+// them to come back and for anything else that would hold it back, until
+// the next working day begins.
+func (app *EmotionalSupportApp) checkWrapUp(now time.Time) {
+	if app.schedule == nil || !app.schedule.wrapUp || app.database == nil || app.holdReason() != "" {
+		return
+	}
+	start, end, ok := app.schedule.LastWorkday(now)
+	if !ok || !app.wrappedUp.Before(end) || app.schedule.WorkdayStarted(end, now) {
+		return
+	}
+
+	// It may have gone out before a restart
+	sent, err := app.database.LastNotificationTime("wrap_up")
+	if err != nil {
+		log.Printf("Error checking for a wrap-up: %v", err)
+		return
+	}
+	if !sent.Before(end) {
+		app.wrappedUp = end
+		return
+	}
+
+	// The session in progress counts too
+	app.splitSession(now)
+	summary, err := app.database.Summary(start, now)
+	if err != nil {
+		log.Printf("Error summing up the day: %v", err)
+		return
+	}
+	// Tried again on the next check if it couldn't be sent
+	if app.notify(&NotificationLog{
+		Type:            "wrap_up",
+		Title:           "That's a wrap",
+		Message:         app.messenger.GetWrapUpMessage(summary),
+		DurationSeconds: int(summary.Total.Seconds()),
+		SentAt:          now,
+	}, now) {
+		app.wrappedUp = end
+	}
+}
+
+// checkAndNotify sends whatever encouragement is due at now, after duration
+// in the current window.
+func (app *EmotionalSupportApp) checkAndNotify(context *Context, duration time.Duration, lastNotificationTime map[string]time.Time, now time.Time) {
 	timing := app.timing
 
+	// Outside the schedule only tracking goes on
+	if app.schedule != nil && !app.schedule.Allows(now) {
+		return
+	}
+
 	// Check for time-based notifications (for all programs, not just programming)
 	if context.Program != "" && duration >= timing.TimeBasedNotifications.MinDuration {
 		// Check if we've reached any of the configured intervals
@@ -223,24 +675,17 @@ func (app *EmotionalSupportApp) checkAndNotify(context *Context, duration time.D
 				if lastNotif, ok := lastNotificationTime[key]; !ok || now.Sub(lastNotif) > timing.TimeBasedNotifications.Cooldown {
 					message := app.messenger.GetTimeBasedMessage(context, duration)
 					if message != "" {
-						if err := app.notifier.Send("Emotional Support", message, ""); err != nil {
-							log.Printf("Error sending notification: %v", err)
-						} else {
+						notif := &NotificationLog{
+							Type:            "time_based",
+							Title:           "Emotional Support",
+							Message:         message,
+							Program:         context.Program,
+							Language:        context.Language,
+							DurationSeconds: int(duration.Seconds()),
+							Activity:        context.Activity,
+						}
+						if app.notify(notif, now) {
 							lastNotificationTime[key] = now
-							// Log notification to database
-							if app.database != nil {
-								notif := &NotificationLog{
-									Type:            "time_based",
-									Title:           "Emotional Support",
-									Message:         message,
-									Program:         context.Program,
-									Language:        context.Language,
-									DurationSeconds: int(duration.Seconds()),
-								}
-								if err := app.database.LogNotification(notif); err != nil {
-									log.Printf("Error logging notification: %v", err)
-								}
-							}
 						}
 					}
 				}
@@ -255,23 +700,16 @@ func (app *EmotionalSupportApp) checkAndNotify(context *Context, duration time.D
 		if lastNotif, ok := lastNotificationTime[key]; !ok || now.Sub(lastNotif) > timing.LanguageNotifications.Cooldown {
 			message := app.messenger.GetLanguageMessage(context.Language)
 			if message != "" {
-				if err := app.notifier.Send("Emotional Support", message, ""); err != nil {
-					log.Printf("Error sending notification: %v", err)
-				} else {
+				notif := &NotificationLog{
+					Type:     "language",
+					Title:    "Emotional Support",
+					Message:  message,
+					Program:  context.Program,
+					Language: context.Language,
+					Activity: context.Activity,
+				}
+				if app.notify(notif, now) {
 					lastNotificationTime[key] = now
-					// Log notification to database
-					if app.database != nil {
-						notif := &NotificationLog{
-							Type:     "language",
-							Title:    "Emotional Support",
-							Message:  message,
-							Program:  context.Program,
-							Language: context.Language,
-						}
-						if err := app.database.LogNotification(notif); err != nil {
-							log.Printf("Error logging notification: %v", err)
-						}
-					}
 				}
 			}
 		}
@@ -282,22 +720,243 @@ func (app *EmotionalSupportApp) checkAndNotify(context *Context, duration time.D
 	if lastNotif, ok := lastNotificationTime[key]; !ok || now.Sub(lastNotif) > timing.HealthReminders.Interval {
 		message := app.messenger.GetHealthReminder()
 		if message != "" {
-			if err := app.notifier.Send("Emotional Support", message, ""); err != nil {
-				log.Printf("Error sending notification: %v", err)
-			} else {
+			notif := &NotificationLog{
+				Type:    "health",
+				Title:   "Emotional Support",
+				Message: message,
+			}
+			if app.notify(notif, now) {
 				lastNotificationTime[key] = now
-				// Log notification to database
-				if app.database != nil {
-					notif := &NotificationLog{
-						Type:    "health",
-						Title:   "Emotional Support",
-						Message: message,
-					}
-					if err := app.database.LogNotification(notif); err != nil {
-						log.Printf("Error logging notification: %v", err)
-					}
-				}
 			}
 		}
 	}
 }
+
+// How each kind of notification is presented. Encouragement is low-key and
+// transient so it doesn't fill up the notification history; health
+// reminders and catching up after fullscreen are worth a sound.
+var notificationStyles = map[string]Notification{
+	"welcome":    {Urgency: UrgencyLow, Category: "x-emotional-support.welcome", Transient: true},
+	"time_based": {Urgency: UrgencyLow, Category: "x-emotional-support.time", Transient: true},
+	"language":   {Urgency: UrgencyLow, Category: "x-emotional-support.language", Transient: true},
+	"health":     {Urgency: UrgencyNormal, Category: "x-emotional-support.health", Sound: "bell"},
+	"summary":    {Urgency: UrgencyNormal, Category: "x-emotional-support.summary", Sound: "message-new-instant"},
+	"wrap_up":    {Urgency: UrgencyNormal, Category: "x-emotional-support.wrap-up", Sound: "complete"},
+}
+
+// notify shows a notification and logs it, or holds it back during a call,
+// while the focused window is fullscreen or while the desktop is in
+// do-not-disturb mode, unless it's critical. It reports whether the
+// notification was shown, held or silenced at now, any of which starts its
+// cooldown.
+func (app *EmotionalSupportApp) notify(notif *NotificationLog, now time.Time) bool {
+	if app.silenced(notif, now) {
+		return true
+	}
+	notification := notificationStyles[notif.Type]
+	if notification.Urgency < UrgencyCritical {
+		if reasons := app.holdReasons(); len(reasons) > 0 {
+			if len(app.held) == 0 {
+				app.heldWhile = make(map[string]bool)
+			}
+			for _, reason := range reasons {
+				app.heldWhile[reason] = true
+			}
+			app.held = append(app.held, notif)
+			return true
+		}
+	}
+
+	notification.Type = notif.Type
+	notification.Title = notif.Title
+	notification.Message = notif.Message
+	notification.Actions = app.notificationActions(notif)
+
+	// Log notification to database first, so the notifier has its ID to
+	// hand back with a response
+	if app.database != nil {
+		if err := app.database.LogNotification(notif); err != nil {
+			log.Printf("Error logging notification: %v", err)
+		}
+		notification.LogID = notif.ID
+	}
+	if err := app.notifier.Send(&notification); err != nil {
+		log.Printf("Error sending notification: %v", err)
+		// It was never seen, so it shouldn't count as sent
+		if app.database != nil && notif.ID != 0 {
+			if err := app.database.DeleteNotification(notif.ID); err != nil {
+				log.Printf("Error removing unsent notification: %v", err)
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// silenced reports whether the user asked not to see notifications like
+// this one for now.
+func (app *EmotionalSupportApp) silenced(notif *NotificationLog, now time.Time) bool {
+	if now.Before(app.snoozed[notif.Type]) {
+		return true
+	}
+	return notif.Type == "language" && now.Before(app.mutedLanguages[notif.Language])
+}
+
+// notificationActions offers the buttons that fit a kind of notification.
+// Keys carry what they apply to after a colon.
+func (app *EmotionalSupportApp) notificationActions(notif *NotificationLog) []NotificationAction {
+	dismiss := NotificationAction{Key: "dismiss", Label: "Dismiss"}
+	switch notif.Type {
+	case "time_based", "health":
+		return []NotificationAction{
+			{Key: "snooze:" + notif.Type, Label: fmt.Sprintf("Snooze %d min", int(app.timing.Actions.Snooze.Minutes()))},
+			{Key: "break", Label: "I'm on a break"},
+			dismiss,
+		}
+	case "language":
+		return []NotificationAction{
+			{Key: "mute:" + notif.Language, Label: fmt.Sprintf("Mute %s today", formatLanguageName(notif.Language))},
+			dismiss,
+		}
+	}
+	return nil
+}
+
+// handleResponse records what the user did with a notification and does
+// what the button they pressed asks for.
+func (app *EmotionalSupportApp) handleResponse(response NotificationResponse) {
+	action, target, _ := strings.Cut(response.Action, ":")
+	if app.database != nil && response.Notification.LogID != 0 {
+		if err := app.database.LogNotificationResponse(response.Notification.LogID, action, response.At); err != nil {
+			log.Printf("Error logging notification response: %v", err)
+		}
+	}
+
+	switch action {
+	case "snooze":
+		until := response.At.Add(app.timing.Actions.Snooze)
+		app.snoozed[target] = until
+		log.Printf("Snoozing %s notifications until %s", target, until.Format(time.Kitchen))
+	case "mute":
+		year, month, day := response.At.Date()
+		app.mutedLanguages[target] = time.Date(year, month, day+1, 0, 0, 0, 0, response.At.Location())
+		log.Printf("Muting %s messages for the rest of the day", target)
+	case "break":
+		if app.awayReason == "" {
+			app.startAway("break", response.At)
+		}
+	}
+}
+
+// holdReason says why notifications should wait for now, if they should.
+func (app *EmotionalSupportApp) holdReason() string {
+	if reasons := app.holdReasons(); len(reasons) > 0 {
+		return reasons[0]
+	}
+	return ""
+}
+
+// holdReasons lists every reason notifications should wait for now.
+func (app *EmotionalSupportApp) holdReasons() []string {
+	var reasons []string
+	if app.meeting != "" {
+		reasons = append(reasons, "meeting")
+	}
+	if app.fullscreen {
+		reasons = append(reasons, "fullscreen")
+	}
+	if app.dnd != nil && app.dnd.DoNotDisturb() {
+		reasons = append(reasons, "do_not_disturb")
+	}
+	return reasons
+}
+
+// catchUpHeld sends what was held back once nothing holds it any more.
+// Past the schedule it's out of date, so it's dropped instead.
+func (app *EmotionalSupportApp) catchUpHeld(now time.Time) {
+	if len(app.held) == 0 {
+		return
+	}
+	if app.schedule != nil && !app.schedule.Allows(now) {
+		var while []string
+		for _, reason := range []string{"meeting", "fullscreen", "do_not_disturb"} {
+			if app.heldWhile[reason] {
+				while = append(while, reason)
+			}
+		}
+		log.Printf("Dropping %d notifications held for %s, the schedule closed before they could be sent",
+			len(app.held), strings.Join(while, " and "))
+		app.held, app.heldWhile = nil, nil
+		return
+	}
+	if app.holdReason() == "" {
+		app.sendHeld(now)
+	}
+}
+
+// sendHeld delivers what was held back: a single notification as it was,
+// several as one summary with the latest message of each type, or as the
+// latest of each type on its own when do-not-disturb held them and no
+// digest is wanted. What can't be sent stays held for the next try.
+func (app *EmotionalSupportApp) sendHeld(now time.Time) {
+	if len(app.held) == 0 || app.holdReason() != "" {
+		return
+	}
+	if len(app.held) == 1 {
+		if app.notify(app.held[0], now) {
+			app.held, app.heldWhile = nil, nil
+		}
+		return
+	}
+
+	var types []string
+	latest := make(map[string]*NotificationLog)
+	for _, notif := range app.held {
+		if _, ok := latest[notif.Type]; !ok {
+			types = append(types, notif.Type)
+		}
+		latest[notif.Type] = notif
+	}
+	if app.heldWhile["do_not_disturb"] && !app.dndDigest {
+		var unsent []*NotificationLog
+		for _, notifType := range types {
+			if !app.notify(latest[notifType], now) {
+				unsent = append(unsent, latest[notifType])
+			}
+		}
+		app.held = unsent
+		if len(unsent) == 0 {
+			app.heldWhile = nil
+		}
+		return
+	}
+
+	lines := make([]string, 0, len(types))
+	for _, notifType := range types {
+		lines = append(lines, latest[notifType].Message)
+	}
+
+	// Name everything that held them back, however the hold began
+	var while []string
+	if app.heldWhile["meeting"] {
+		while = append(while, "you were in a call")
+	}
+	if app.heldWhile["fullscreen"] {
+		while = append(while, "you were fullscreen")
+	}
+	if app.heldWhile["do_not_disturb"] {
+		while = append(while, "do not disturb was on")
+	}
+	title := "While you were busy"
+	if len(while) > 0 {
+		title = "While " + strings.Join(while, " and ")
+	}
+	summary := &NotificationLog{
+		Type:    "summary",
+		Title:   title,
+		Message: strings.Join(lines, "\n"),
+	}
+	if app.notify(summary, now) {
+		app.held, app.heldWhile = nil, nil
+	}
+}
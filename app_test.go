@@ -0,0 +1,683 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestApp returns an app that records its notifications and has no
+// tracker, state file, database or idle detector.
+func newTestApp() (*EmotionalSupportApp, *RecordingNotifier) {
+	recorder := NewRecordingNotifier()
+	return &EmotionalSupportApp{
+		detector:             NewContextDetector(DefaultConfig()),
+		messenger:            NewMessageGenerator(),
+		notifier:             recorder,
+		timing:               DefaultNotificationTiming(),
+		lastContext:          &Context{},
+		lastWindowInfo:       &WindowInfo{},
+		lastNotificationTime: make(map[string]time.Time),
+		snoozed:              make(map[string]time.Time),
+		mutedLanguages:       make(map[string]time.Time),
+	}, recorder
+}
+
+// fakeDND is a desktop whose do-not-disturb mode the test sets.
+type fakeDND bool
+
+func (d *fakeDND) DoNotDisturb() bool { return bool(*d) }
+
+var testCodingContext = &Context{Program: "vim", Language: "go", IsProgramming: true, Activity: ActivityCoding}
+
+func sentTypes(recorder *RecordingNotifier) []string {
+	var types []string
+	for _, notification := range recorder.Sent() {
+		types = append(types, notification.Type)
+	}
+	return types
+}
+
+func TestCheckAndNotify(t *testing.T) {
+	app, recorder := newTestApp()
+
+	app.checkAndNotify(testCodingContext, 30*time.Minute, app.lastNotificationTime, time.Now())
+	if got := strings.Join(sentTypes(recorder), ","); got != "time_based,language,health" {
+		t.Fatalf("sent %s, want time_based,language,health", got)
+	}
+	for _, notification := range recorder.Sent() {
+		if notification.Message == "" {
+			t.Errorf("%s notification has no message", notification.Type)
+		}
+		if notification.Category == "" {
+			t.Errorf("%s notification has no style", notification.Type)
+		}
+	}
+	if sent := recorder.Sent(); sent[1].Actions[0].Key != "mute:go" {
+		t.Errorf("language notification actions = %v", sent[1].Actions)
+	}
+
+	// Everything is on cooldown now
+	app.checkAndNotify(testCodingContext, 30*time.Minute+5*time.Second, app.lastNotificationTime, time.Now())
+	if n := len(recorder.Sent()); n != 3 {
+		t.Errorf("%d notifications after the cooldown started, want 3", n)
+	}
+}
+
+func TestCheckAndNotifyBetweenIntervals(t *testing.T) {
+	app, recorder := newTestApp()
+
+	// Health reminders go out anyway, but 45 minutes isn't a milestone
+	app.checkAndNotify(&Context{Program: "firefox"}, 45*time.Minute, app.lastNotificationTime, time.Now())
+	if got := strings.Join(sentTypes(recorder), ","); got != "health" {
+		t.Errorf("sent %s, want health", got)
+	}
+}
+
+func TestCheckAndNotifySnoozed(t *testing.T) {
+	app, recorder := newTestApp()
+	app.snoozed["health"] = time.Now().Add(time.Minute)
+	app.mutedLanguages["go"] = time.Now().Add(time.Minute)
+
+	app.checkAndNotify(testCodingContext, 30*time.Minute, app.lastNotificationTime, time.Now())
+	if got := strings.Join(sentTypes(recorder), ","); got != "time_based" {
+		t.Errorf("sent %s, want only time_based", got)
+	}
+}
+
+func TestNotifySilenced(t *testing.T) {
+	now := time.Date(2026, time.March, 2, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		name  string
+		notif NotificationLog
+		at    time.Time
+		sent  bool
+	}{
+		{"snoozed", NotificationLog{Type: "health"}, now, false},
+		{"snooze over", NotificationLog{Type: "health"}, now.Add(30 * time.Minute), true},
+		{"other type", NotificationLog{Type: "time_based"}, now, true},
+		{"muted language", NotificationLog{Type: "language", Language: "go"}, now, false},
+		{"mute over", NotificationLog{Type: "language", Language: "go"}, now.Add(14 * time.Hour), true},
+		{"other language", NotificationLog{Type: "language", Language: "rust"}, now, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, recorder := newTestApp()
+			app.snoozed["health"] = now.Add(30 * time.Minute)
+			app.mutedLanguages["go"] = now.Add(14 * time.Hour)
+
+			// Silenced counts as handled, so cooldowns still start
+			if !app.notify(&tt.notif, tt.at) {
+				t.Error("notify() = false")
+			}
+			if sent := len(recorder.Sent()) == 1; sent != tt.sent {
+				t.Errorf("sent %v at %s, want %v", sent, tt.at.Format("15:04"), tt.sent)
+			}
+		})
+	}
+}
+
+func TestCheckAndNotifyQuietHours(t *testing.T) {
+	app, recorder := newTestApp()
+	app.schedule = NewSchedule(&ScheduleConfig{QuietHours: []string{"22:00-07:00"}})
+	night := time.Date(2026, time.March, 2, 23, 0, 0, 0, time.Local)
+
+	// Only the time checked counts, not the wall clock
+	app.checkAndNotify(testCodingContext, 30*time.Minute, app.lastNotificationTime, night)
+	if n := len(recorder.Sent()); n != 0 || len(app.held) != 0 || len(app.lastNotificationTime) != 0 {
+		t.Fatalf("%d sent, %d held and %d cooldowns started in quiet hours, want none", n, len(app.held), len(app.lastNotificationTime))
+	}
+
+	app.checkAndNotify(testCodingContext, 30*time.Minute, app.lastNotificationTime, night.Add(8*time.Hour))
+	if got := strings.Join(sentTypes(recorder), ","); got != "time_based,language,health" {
+		t.Errorf("sent %s after quiet hours, want time_based,language,health", got)
+	}
+}
+
+func TestHoldWhileFullscreen(t *testing.T) {
+	app, recorder := newTestApp()
+	app.fullscreen = true
+
+	app.checkAndNotify(testCodingContext, 30*time.Minute, app.lastNotificationTime, time.Now())
+	if n := len(recorder.Sent()); n != 0 {
+		t.Fatalf("%d notifications sent while fullscreen", n)
+	}
+	if len(app.held) != 3 {
+		t.Fatalf("%d notifications held, want 3", len(app.held))
+	}
+
+	app.fullscreen = false
+	app.sendHeld(time.Now())
+	sent := recorder.Sent()
+	if len(sent) != 1 || sent[0].Type != "summary" {
+		t.Fatalf("sent %v after fullscreen, want one summary", sentTypes(recorder))
+	}
+	if sent[0].Title != "While you were fullscreen" {
+		t.Errorf("summary title %q", sent[0].Title)
+	}
+	if lines := strings.Split(sent[0].Message, "\n"); len(lines) != 3 {
+		t.Errorf("summary has %d lines, want one per type: %q", len(lines), sent[0].Message)
+	}
+	if len(app.held) != 0 {
+		t.Errorf("%d notifications still held", len(app.held))
+	}
+}
+
+func TestHoldDuringMeeting(t *testing.T) {
+	app, recorder := newTestApp()
+	app.meeting = "window zoom"
+
+	// Milestones passed during the call aren't lost
+	app.checkAndNotify(testCodingContext, 30*time.Minute, app.lastNotificationTime, time.Now())
+	if n := len(recorder.Sent()); n != 0 || len(app.held) != 3 {
+		t.Fatalf("%d sent and %d held during a call, want 0 and 3", n, len(app.held))
+	}
+
+	app.meeting = ""
+	if reason := app.holdReason(); reason != "" {
+		t.Fatalf("still holding for %s", reason)
+	}
+	app.sendHeld(time.Now())
+	if sent := recorder.Sent(); len(sent) != 1 || sent[0].Title != "While you were in a call" {
+		t.Errorf("sent %+v, want one summary of the call", sent)
+	}
+}
+
+func TestSendHeldSummarisesLatestPerType(t *testing.T) {
+	app, recorder := newTestApp()
+	app.heldWhile = map[string]bool{"fullscreen": true}
+	app.held = []*NotificationLog{
+		{Type: "health", Message: "Drink water"},
+		{Type: "language", Message: "Go go go"},
+		{Type: "health", Message: "Stretch"},
+	}
+
+	app.sendHeld(time.Now())
+	sent := recorder.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d notifications, want 1", len(sent))
+	}
+	// Types in the order they first came up, each with its latest message
+	if want := "Stretch\nGo go go"; sent[0].Message != want {
+		t.Errorf("summary %q, want %q", sent[0].Message, want)
+	}
+}
+
+func TestSendHeldSingle(t *testing.T) {
+	app, recorder := newTestApp()
+	app.heldWhile = map[string]bool{"fullscreen": true}
+	app.held = []*NotificationLog{{Type: "health", Title: "Emotional Support", Message: "Drink water"}}
+
+	app.sendHeld(time.Now())
+	sent := recorder.Sent()
+	if len(sent) != 1 || sent[0].Type != "health" || sent[0].Message != "Drink water" {
+		t.Errorf("sent %+v, want the held health reminder as it was", sent)
+	}
+}
+
+func TestSendHeldFailing(t *testing.T) {
+	tests := []struct {
+		name string
+		held []*NotificationLog
+		want string
+	}{
+		{"single", []*NotificationLog{{Type: "health", Title: "Emotional Support", Message: "Drink water"}}, "health"},
+		{"summary", []*NotificationLog{{Type: "health", Message: "Drink water"}, {Type: "language", Message: "Go go go"}}, "summary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, recorder := newTestApp()
+			app.heldWhile = map[string]bool{"fullscreen": true}
+			app.held = tt.held
+
+			// Nothing is lost while the desktop can't be reached
+			app.notifier = failingNotifier{}
+			app.sendHeld(time.Now())
+			if len(app.held) != len(tt.held) || !app.heldWhile["fullscreen"] {
+				t.Fatalf("%d held while %v after a failed send, want %d while fullscreen", len(app.held), app.heldWhile, len(tt.held))
+			}
+
+			app.notifier = recorder
+			app.sendHeld(time.Now())
+			if got := strings.Join(sentTypes(recorder), ","); got != tt.want {
+				t.Errorf("sent %s on the next try, want %s", got, tt.want)
+			}
+			if len(app.held) != 0 || app.heldWhile != nil {
+				t.Errorf("%d still held while %v after sending", len(app.held), app.heldWhile)
+			}
+		})
+	}
+}
+
+func TestHoldWhileDoNotDisturb(t *testing.T) {
+	dnd := fakeDND(true)
+
+	t.Run("digest", func(t *testing.T) {
+		app, recorder := newTestApp()
+		app.dnd = &dnd
+		app.dndDigest = true
+
+		app.checkAndNotify(testCodingContext, 30*time.Minute, app.lastNotificationTime, time.Now())
+		if n := len(recorder.Sent()); n != 0 || len(app.held) != 3 {
+			t.Fatalf("%d sent and %d held during do not disturb, want 0 and 3", n, len(app.held))
+		}
+
+		dnd = false
+		defer func() { dnd = true }()
+		if reason := app.holdReason(); reason != "" {
+			t.Fatalf("still holding for %s", reason)
+		}
+		app.sendHeld(time.Now())
+		if sent := recorder.Sent(); len(sent) != 1 || sent[0].Title != "While do not disturb was on" {
+			t.Errorf("sent %+v, want one digest", sent)
+		}
+	})
+
+	t.Run("no digest", func(t *testing.T) {
+		app, recorder := newTestApp()
+		app.dnd = &dnd
+
+		app.checkAndNotify(testCodingContext, 30*time.Minute, app.lastNotificationTime, time.Now())
+		app.checkAndNotify(testCodingContext, 30*time.Minute, map[string]time.Time{}, time.Now())
+		if n := len(recorder.Sent()); n != 0 || len(app.held) != 6 {
+			t.Fatalf("%d sent and %d held during do not disturb, want 0 and 6", n, len(app.held))
+		}
+
+		dnd = false
+		defer func() { dnd = true }()
+		app.sendHeld(time.Now())
+		if got := strings.Join(sentTypes(recorder), ","); got != "time_based,language,health" {
+			t.Errorf("sent %s, want the latest of each type on its own", got)
+		}
+		if len(app.held) != 0 {
+			t.Errorf("%d notifications still held", len(app.held))
+		}
+	})
+}
+
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "activity.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := &Database{db: db}
+	if err := database.initSchema(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestCheckWrapUp(t *testing.T) {
+	schedule := NewSchedule(&ScheduleConfig{
+		WorkingHours: map[string][]string{"weekdays": {"09:00-17:00"}},
+		WrapUp:       true,
+	})
+	monday := func(hour, minute int) time.Time {
+		return time.Date(2026, time.March, 2, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		sent bool
+	}{
+		{"during the day", monday(16, 59), false},
+		{"once it's over", monday(17, 0), true},
+		{"back late in the evening", monday(23, 30), true},
+		{"back before work the next day", monday(24+8, 30), true},
+		{"the next working day", monday(24+9, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, recorder := newTestApp()
+			app.schedule = schedule
+			app.database = newTestDatabase(t)
+
+			app.checkWrapUp(tt.now)
+			if sent := strings.Join(sentTypes(recorder), ","); (sent == "wrap_up") != tt.sent {
+				t.Errorf("sent %q, want a wrap-up: %v", sent, tt.sent)
+			}
+		})
+	}
+}
+
+func TestCheckWrapUpOnce(t *testing.T) {
+	schedule := NewSchedule(&ScheduleConfig{
+		WorkingHours: map[string][]string{"weekdays": {"09:00-17:00"}},
+		WrapUp:       true,
+	})
+	database := newTestDatabase(t)
+	now := time.Date(2026, time.March, 2, 18, 0, 0, 0, time.Local)
+
+	app, recorder := newTestApp()
+	app.schedule = schedule
+	app.database = database
+	app.fullscreen = true
+	app.checkWrapUp(now)
+	if n := len(recorder.Sent()) + len(app.held); n != 0 {
+		t.Fatalf("%d notifications while fullscreen, want the wrap-up to wait", n)
+	}
+	app.fullscreen = false
+	app.checkWrapUp(now)
+	app.checkWrapUp(now.Add(time.Minute))
+	if n := len(recorder.Sent()); n != 1 {
+		t.Fatalf("sent %d wrap-ups, want 1", n)
+	}
+	// Logged as of the check, not the wall clock
+	if sentAt, err := database.LastNotificationTime("wrap_up"); err != nil || !sentAt.Equal(now) {
+		t.Fatalf("wrap-up logged at %v (%v), want %v", sentAt, err, now)
+	}
+
+	// A restart doesn't send it again, but the next day has its own
+	restart := func(now time.Time) int {
+		app, recorder := newTestApp()
+		app.schedule = schedule
+		app.database = database
+		app.checkWrapUp(now)
+		return len(recorder.Sent())
+	}
+	if n := restart(now.Add(time.Hour)); n != 0 {
+		t.Errorf("sent %d wrap-ups after a restart, want none", n)
+	}
+	if n := restart(now.AddDate(0, 0, 1)); n != 1 {
+		t.Errorf("sent %d wrap-ups the next evening, want 1", n)
+	}
+}
+
+func TestCheckWrapUpFailing(t *testing.T) {
+	app, recorder := newTestApp()
+	app.schedule = NewSchedule(&ScheduleConfig{
+		WorkingHours: map[string][]string{"weekdays": {"09:00-17:00"}},
+		WrapUp:       true,
+	})
+	app.database = newTestDatabase(t)
+	now := time.Date(2026, time.March, 2, 18, 0, 0, 0, time.Local)
+
+	// A wrap-up that never arrived isn't done yet
+	app.notifier = failingNotifier{}
+	app.checkWrapUp(now)
+	if !app.wrappedUp.IsZero() {
+		t.Fatalf("wrapped up at %v after a failed send", app.wrappedUp)
+	}
+
+	app.notifier = recorder
+	app.checkWrapUp(now.Add(time.Minute))
+	if got := strings.Join(sentTypes(recorder), ","); got != "wrap_up" {
+		t.Errorf("sent %s on the next check, want wrap_up", got)
+	}
+}
+
+func TestCatchUpHeld(t *testing.T) {
+	schedule := NewSchedule(&ScheduleConfig{WorkingHours: map[string][]string{"weekdays": {"09:00-17:00"}}})
+	monday := func(hour int) time.Time { return time.Date(2026, time.March, 2, hour, 0, 0, 0, time.Local) }
+
+	tests := []struct {
+		name       string
+		at         time.Time
+		fullscreen bool
+		sent       string
+		held       int
+		logged     string
+	}{
+		{"at work", monday(16), false, "summary", 0, ""},
+		{"still fullscreen", monday(16), true, "", 2, ""},
+		{"after work", monday(17), false, "", 0, "Dropping 2 notifications held for meeting and fullscreen, the schedule closed"},
+		{"after work while fullscreen", monday(17), true, "", 0, "Dropping 2 notifications"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, recorder := newTestApp()
+			app.schedule = schedule
+			app.heldWhile = map[string]bool{"fullscreen": true, "meeting": true}
+			app.held = []*NotificationLog{{Type: "health", Message: "Drink water"}, {Type: "language", Message: "Go go go"}}
+			app.fullscreen = tt.fullscreen
+
+			var logged strings.Builder
+			log.SetOutput(&logged)
+			defer log.SetOutput(os.Stderr)
+
+			app.catchUpHeld(tt.at)
+			if got := strings.Join(sentTypes(recorder), ","); got != tt.sent {
+				t.Errorf("sent %q, want %q", got, tt.sent)
+			}
+			if len(app.held) != tt.held {
+				t.Errorf("%d still held, want %d", len(app.held), tt.held)
+			}
+			// Nothing goes without a word
+			if tt.logged == "" && logged.Len() > 0 || !strings.Contains(logged.String(), tt.logged) {
+				t.Errorf("logged %q, want %q", logged.String(), tt.logged)
+			}
+		})
+	}
+}
+
+func TestWrapUpMessageTies(t *testing.T) {
+	mg := NewMessageGenerator()
+	summary := &DaySummary{
+		Total: 3 * time.Hour,
+		Activities: map[string]time.Duration{
+			"meetings": time.Hour, "docs": time.Hour, "coding": time.Hour, "reviewing": time.Hour,
+		},
+	}
+	first := mg.GetWrapUpMessage(summary)
+	prefix, _, _ := strings.Cut(first, ".")
+	for i := 0; i < 20; i++ {
+		if got, _, _ := strings.Cut(mg.GetWrapUpMessage(summary), "."); got != prefix {
+			t.Fatalf("activities reordered between runs: %q, then %q", prefix, got)
+		}
+	}
+	if !strings.Contains(prefix, "coding") || strings.Contains(prefix, "reviewing") {
+		t.Errorf("%q, want the first three activities by name", prefix)
+	}
+}
+
+// failingNotifier can't reach the desktop.
+type failingNotifier struct{}
+
+func (failingNotifier) Send(*Notification) error { return errors.New("no notification server") }
+
+func TestNotifyLogsBeforeSending(t *testing.T) {
+	app, recorder := newTestApp()
+	app.database = newTestDatabase(t)
+
+	app.notify(&NotificationLog{Type: "health", Title: "Emotional Support", Message: "Drink water"}, time.Now())
+	// The notifier keeps a copy, so the ID has to be there before Send
+	sent := recorder.Sent()
+	if len(sent) != 1 || sent[0].LogID == 0 {
+		t.Fatalf("sent %+v, want one notification with its log ID", sent)
+	}
+
+	app.notifier = failingNotifier{}
+	if app.notify(&NotificationLog{Type: "wrap_up", Title: "That's a wrap", Message: "Done"}, time.Now()) {
+		t.Error("notify() reported a failed send as sent")
+	}
+	if sentAt, err := app.database.LastNotificationTime("wrap_up"); err != nil || !sentAt.IsZero() {
+		t.Errorf("unsent wrap-up logged at %v (%v)", sentAt, err)
+	}
+}
+
+func TestHoldWhileFullscreenAndDoNotDisturb(t *testing.T) {
+	tests := []struct {
+		name  string
+		first func(app *EmotionalSupportApp, dnd *fakeDND)
+		then  func(app *EmotionalSupportApp, dnd *fakeDND)
+	}{
+		{
+			"fullscreen then do not disturb",
+			func(app *EmotionalSupportApp, dnd *fakeDND) { app.fullscreen = true },
+			func(app *EmotionalSupportApp, dnd *fakeDND) { app.fullscreen = false; *dnd = true },
+		},
+		{
+			"do not disturb then fullscreen",
+			func(app *EmotionalSupportApp, dnd *fakeDND) { *dnd = true },
+			func(app *EmotionalSupportApp, dnd *fakeDND) { *dnd = false; app.fullscreen = true },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, recorder := newTestApp()
+			dnd := fakeDND(false)
+			app.dnd = &dnd
+			app.dndDigest = true
+
+			tt.first(app, &dnd)
+			app.notify(&NotificationLog{Type: "health", Message: "Drink water"}, time.Now())
+			tt.then(app, &dnd)
+			app.notify(&NotificationLog{Type: "language", Message: "Go go go"}, time.Now())
+			if n := len(recorder.Sent()); n != 0 || len(app.held) != 2 {
+				t.Fatalf("%d sent and %d held, want 0 and 2", n, len(app.held))
+			}
+
+			app.fullscreen, dnd = false, false
+			app.sendHeld(time.Now())
+			want := "While you were fullscreen and do not disturb was on"
+			if sent := recorder.Sent(); len(sent) != 1 || sent[0].Title != want {
+				t.Errorf("sent %+v, want one summary titled %q", sent, want)
+			}
+		})
+	}
+}
+
+func TestHoldWithoutDigestAndFullscreen(t *testing.T) {
+	app, recorder := newTestApp()
+	dnd := fakeDND(true)
+	app.dnd = &dnd
+
+	// Fullscreen as well doesn't make do-not-disturb's items a digest
+	app.notify(&NotificationLog{Type: "health", Message: "Drink water"}, time.Now())
+	dnd = false
+	app.fullscreen = true
+	app.notify(&NotificationLog{Type: "health", Message: "Stretch"}, time.Now())
+	app.notify(&NotificationLog{Type: "language", Message: "Go go go"}, time.Now())
+
+	app.fullscreen = false
+	app.sendHeld(time.Now())
+	var messages []string
+	for _, notification := range recorder.Sent() {
+		messages = append(messages, notification.Message)
+	}
+	if got := strings.Join(messages, ","); got != "Stretch,Go go go" {
+		t.Errorf("sent %s, want the latest of each type on its own", got)
+	}
+
+	// Fullscreen alone still gets a summary
+	app.fullscreen = true
+	app.notify(&NotificationLog{Type: "health", Message: "Drink water"}, time.Now())
+	app.notify(&NotificationLog{Type: "language", Message: "Go go go"}, time.Now())
+	app.fullscreen = false
+	app.sendHeld(time.Now())
+	if sent := recorder.Sent(); len(sent) != 3 || sent[2].Title != "While you were fullscreen" {
+		t.Errorf("sent %+v, want a summary for fullscreen alone", sent)
+	}
+}
+
+// loggedSessions lists the window sessions in the database as
+// "program duration", oldest first.
+func loggedSessions(t *testing.T, database *Database) []string {
+	t.Helper()
+	rows, err := database.db.Query(`SELECT program, COALESCE(duration_seconds, 0) FROM window_sessions ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var sessions []string
+	for rows.Next() {
+		var program string
+		var seconds int
+		if err := rows.Scan(&program, &seconds); err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, fmt.Sprintf("%s %s", program, time.Duration(seconds)*time.Second))
+	}
+	return sessions
+}
+
+var (
+	testEditorWindow  = &WindowInfo{Title: "main.go - NVIM", Process: "nvim"}
+	testBrowserWindow = &WindowInfo{Title: "Go Documentation - Mozilla Firefox", Process: "firefox"}
+)
+
+func TestHandleFocusEvent(t *testing.T) {
+	start := time.Date(2026, time.March, 2, 10, 0, 0, 0, time.Local)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	tests := []struct {
+		name     string
+		away     string
+		awaySeen int // Minutes after the start the user went away
+		focusAt  int
+		back     bool // Whether the focus change ends the away period
+		sessions string
+	}{
+		{"at the computer", "", 0, 10, true, "vim 10m0s"},
+		{"back from idle", "idle", 5, 10, true, "vim 5m0s"},
+		{"just leaving for a break", "break", 5, 5, false, "vim 5m0s"},
+		{"back from a break", "break", 5, 10, true, "vim 5m0s"},
+		{"on the lock screen", "lock", 5, 10, false, "vim 5m0s"},
+		{"waking up", "sleep", 5, 10, false, "vim 5m0s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApp()
+			app.database = newTestDatabase(t)
+			app.handleWindow(testEditorWindow, start)
+			if tt.away != "" {
+				app.startAway(tt.away, at(tt.awaySeen))
+			}
+
+			app.handleFocusEvent(testBrowserWindow, at(tt.focusAt))
+			if back := app.awayReason == ""; back != tt.back {
+				t.Errorf("away for %q after the focus change, want back: %v", app.awayReason, tt.back)
+			}
+			// Only a user who is back starts timing the new window
+			if timing := app.lastWindow != ""; timing != tt.back {
+				t.Errorf("timing %q, want a session: %v", app.lastWindow, tt.back)
+			}
+			if got := strings.Join(loggedSessions(t, app.database), ","); got != tt.sessions {
+				t.Errorf("sessions %s, want %s", got, tt.sessions)
+			}
+		})
+	}
+}
+
+// fakeFocusTracker pushes the focus changes the test sends it.
+type fakeFocusTracker struct {
+	events chan *WindowInfo
+}
+
+func (t *fakeFocusTracker) GetActiveWindow() (*WindowInfo, error) { return testEditorWindow, nil }
+
+func (t *fakeFocusTracker) WatchFocus(done <-chan struct{}) (<-chan *WindowInfo, error) {
+	return t.events, nil
+}
+
+func TestWatchFocus(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	app, _ := newTestApp()
+	app.tracker = &fakeFocusTracker{events: make(chan *WindowInfo, 1)}
+	events := app.watchFocus(done)
+	if events == nil {
+		t.Fatal("watchFocus() = nil for a tracker with focus events")
+	}
+	app.tracker.(*fakeFocusTracker).events <- testBrowserWindow
+	if info := <-events; info != testBrowserWindow {
+		t.Errorf("focus event %+v, want the tracker's", info)
+	}
+
+	// Trackers without events are polled
+	app.tracker = struct{ WindowTracker }{app.tracker}
+	if events := app.watchFocus(done); events != nil {
+		t.Error("watchFocus() returned events for a tracker without them")
+	}
+}
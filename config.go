@@ -0,0 +1,712 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Config is read from ~/.config/emotional-support/config.json. Anything set
+// there is merged over the built-in defaults.
+type Config struct {
+	// Programs recognised from their windows. A rule with the same name as
+	// a built-in one replaces it.
+	Programs []ProgramRuleConfig `json:"programs,omitempty"`
+
+	// Languages recognised from file names, extensions, shebang interpreters
+	// and project marker files. A rule with the same name as a built-in one replaces it.
+	Languages []LanguageRuleConfig `json:"languages,omitempty"`
+
+	// Sites recognised from browser tab titles. A rule with the same name
+	// as a built-in one replaces it.
+	Sites []SiteRuleConfig `json:"sites,omitempty"`
+
+	// Activities windows are classified into. A rule with the same name as
+	// a built-in one replaces it.
+	Activities []ActivityRuleConfig `json:"activities,omitempty"`
+
+	// Neovim integration, replaced as a whole when set
+	Neovim *NeovimConfig `json:"neovim,omitempty"`
+
+	// Local heartbeat endpoint for editor plugins, replaced as a whole
+	// when set
+	Heartbeat *HeartbeatConfig `json:"heartbeat,omitempty"`
+
+	// Meeting detection, replaced as a whole when set
+	Meetings *MeetingConfig `json:"meetings,omitempty"`
+
+	// Where notifications go, replaced as a whole when set
+	Notifications *NotificationsConfig `json:"notifications,omitempty"`
+
+	// Respecting the desktop's do-not-disturb mode, replaced as a whole
+	// when set
+	DoNotDisturb *DoNotDisturbConfig `json:"do_not_disturb,omitempty"`
+
+	// When encouragement is welcome, replaced as a whole when set
+	Schedule *ScheduleConfig `json:"schedule,omitempty"`
+}
+
+// ProgramRuleConfig matches a program by regular expressions on the window
+// title, process name or WM_CLASS. Any one matching is enough. Rules with a
+// higher priority are tried first; equal priorities keep config order.
+type ProgramRuleConfig struct {
+	Name        string `json:"name"`
+	Priority    int    `json:"priority,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Process     string `json:"process,omitempty"`
+	WMClass     string `json:"wm_class,omitempty"`
+	Category    string `json:"category,omitempty"`
+	Programming bool   `json:"programming,omitempty"`
+	IDE         bool   `json:"ide,omitempty"`
+}
+
+// LanguageRuleConfig maps file extensions (".go"), exact file names
+// ("Makefile"), shebang interpreters ("python") and project marker files
+// ("go.mod") to a language.
+type LanguageRuleConfig struct {
+	Name         string   `json:"name"`
+	Extensions   []string `json:"extensions,omitempty"`
+	FileNames    []string `json:"filenames,omitempty"`
+	Interpreters []string `json:"interpreters,omitempty"`
+	Markers      []string `json:"markers,omitempty"`
+}
+
+// SiteRuleConfig recognises a site in a browser by a regular expression on
+// the page title or by a domain appearing in it. Rules are tried in order,
+// so specific ones (GitHub pull requests) go before general ones (GitHub).
+// A title pattern with a group named "project" sets the project name.
+type SiteRuleConfig struct {
+	Name     string   `json:"name"`
+	Site     string   `json:"site,omitempty"` // Name shown in messages, defaults to Name
+	Title    string   `json:"title,omitempty"`
+	Domains  []string `json:"domains,omitempty"`
+	Category string   `json:"category"`
+	Language string   `json:"language,omitempty"` // For documentation of one language
+}
+
+// ActivityRuleConfig puts windows into an activity: coding, reviewing,
+// docs, communication, meetings, entertainment, system or uncategorized.
+// Unlike program rules, every field that is set must match. Program is
+// matched against the detected program name ("vim", "firefox", "slack"),
+// and project against the project name, repository name or path. Rules
+// are tried in order; without a match the activity follows from the site
+// category or whether the program is for programming.
+type ActivityRuleConfig struct {
+	Name         string `json:"name"`
+	Activity     string `json:"activity"`
+	Program      string `json:"program,omitempty"`
+	Title        string `json:"title,omitempty"`
+	Project      string `json:"project,omitempty"`
+	SiteCategory string `json:"site_category,omitempty"`
+}
+
+// NeovimConfig controls asking running Neovim instances over their RPC
+// socket for the exact buffer, filetype and working directory.
+type NeovimConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// HeartbeatConfig controls the WakaTime-compatible endpoint editor plugins
+// can report to, at /api/v1/users/current/heartbeats and its .bulk
+// variant. Plugins of our own can post the same JSON with an "editor"
+// field in place of a user agent. While the focused editor's latest
+// heartbeat is younger than MaxAge it wins over guessing from the window.
+//
+// There is no authentication, so only loopback addresses and Unix sockets
+// (made readable by the user alone) are allowed. To keep web pages from
+// posting to it, requests must be application/json with no Origin header,
+// and over TCP must name a loopback address or localhost as the Host. A
+// socket another instance still serves is left alone, and heartbeats
+// stamped in the future count as sent when they arrive.
+type HeartbeatConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// Addresses to listen on: loopback "host:port" or "unix:/path"
+	Listen []string `json:"listen,omitempty"`
+
+	// How long a heartbeat is trusted over guessing from the window, as a
+	// Go duration ("2m"). WakaTime plugins send one at least every two
+	// minutes while the editor is in use.
+	MaxAge string `json:"max_age,omitempty"`
+}
+
+// maxAge is MaxAge parsed, falling back to the default.
+func (h *HeartbeatConfig) maxAge() time.Duration {
+	if d, err := time.ParseDuration(h.MaxAge); err == nil {
+		return d
+	}
+	return defaultHeartbeatMaxAge
+}
+
+// MeetingConfig controls detecting calls, during which notifications are
+// held back and sitting still doesn't count as being away. A call is the
+// focused window being a meeting (Zoom, Teams, Skype, or Meet, Jitsi, Zoom
+// or Teams in a browser), or a program recording from a microphone, as
+// listed by "pactl list source-outputs"; monitors and paused streams don't
+// count. Processes are regular expressions matched against that program's
+// binary name, or its application name when PulseAudio doesn't know the
+// binary. Left out, a built-in list of meeting, chat and browser programs
+// is used; an empty list leaves only meeting windows.
+type MeetingConfig struct {
+	Enabled   bool     `json:"enabled"`
+	Processes []string `json:"processes,omitempty"`
+}
+
+// NotificationsConfig lists the backends notifications are sent through.
+// With several, each notification goes to all of them.
+type NotificationsConfig struct {
+	Backends []NotifierConfig `json:"backends"`
+}
+
+// NotifierConfig is one backend: "dbus" for desktop popups, "log" for the
+// program's log, "stdout", "jsonl" for a file with a line of JSON per
+// notification, or "terminal" for escape sequences written to a terminal.
+type NotifierConfig struct {
+	Type   string `json:"type"`
+	Path   string `json:"path,omitempty"`   // jsonl file, notifications.jsonl next to the database by default
+	TTY    string `json:"tty,omitempty"`    // terminal device, the controlling terminal by default
+	Format string `json:"format,omitempty"` // terminal escape: osc777 (default), osc9 or bell
+}
+
+// DoNotDisturbConfig controls holding notifications while the desktop's
+// do-not-disturb mode is on. Which mode counts depends on the server
+// GetServerInformation names: KDE's Inhibited property, "dunstctl
+// is-paused", a mako mode named "do-not-disturb" or "dnd", SwayNC's
+// --get-dnd, or GNOME's show-banners setting. With Digest, what was held
+// is delivered as one notification afterwards, titled after everything
+// that held it back, fullscreen included; otherwise the latest of each
+// kind is delivered on its own, even if fullscreen held it too.
+type DoNotDisturbConfig struct {
+	Enabled bool `json:"enabled"`
+	Digest  bool `json:"digest"`
+}
+
+// ScheduleConfig limits encouragement to working hours and keeps it out of
+// quiet hours and holidays; tracking carries on regardless. Working hours
+// are ranges like "09:00-17:30" by day name, "weekdays" or "weekend",
+// which single days override, and a day left out or with no ranges is a
+// day off. A range like "22:00-02:00" runs past midnight. Without working
+// hours any time outside quiet hours counts. Quiet hours apply every day
+// and holidays are dates like "2026-12-25", with or without working hours.
+// Notifications still held back for a call, fullscreen or do-not-disturb
+// when the schedule closes are dropped.
+//
+// With WrapUp, which needs working hours, the end of each working day
+// brings a summary of it. If the user is away or notifications are held
+// then, it waits until they're back, unless the next working day has
+// started by then, and it's only sent once a day, even across restarts.
+type ScheduleConfig struct {
+	WorkingHours map[string][]string `json:"working_hours,omitempty"`
+	QuietHours   []string            `json:"quiet_hours,omitempty"`
+	Holidays     []string            `json:"holidays,omitempty"`
+	WrapUp       bool                `json:"wrap_up,omitempty"`
+}
+
+var notifierTypes = map[string]bool{
+	"dbus": true, "log": true, "stdout": true, "jsonl": true, "terminal": true,
+}
+
+const (
+	defaultHeartbeatListen = "127.0.0.1:8975"
+	defaultHeartbeatMaxAge = 2*time.Minute + 30*time.Second
+)
+
+// DefaultConfig returns the built-in detection rules
+func DefaultConfig() *Config {
+	return &Config{
+		Programs: []ProgramRuleConfig{
+			// Editors and IDEs. Process and class patterns are anchored to the
+			// whole name; title patterns only match whole words, since titles
+			// are free text ("code" in "GitHub code review" is not VS Code)
+			{Name: "vim", Process: `(?i)^(g?vim|nvim|nvim-qt|neovim|neovide)$`, WMClass: `(?i)^(gvim|nvim-qt|neovide)$`, Title: `(?i)\b(n?vim|neovim)\b`, Category: "editor", Programming: true},
+			{Name: "vscode", Process: `(?i)^(code|code-oss|codium|vscodium)$`, WMClass: `(?i)^(code|code-oss|vscodium)$`, Title: `(?i)\b(visual studio code|vscodium)$`, Category: "ide", Programming: true, IDE: true},
+			{Name: "emacs", Process: `(?i)^emacs(client)?(-[\d.]+)?$`, WMClass: `(?i)^emacs$`, Title: `(?i)\bgnu emacs\b`, Category: "editor", Programming: true},
+			{Name: "idea", Process: `(?i)^idea(\.sh)?$`, WMClass: `(?i)^jetbrains-idea`, Title: `(?i)\bintellij idea\b`, Category: "ide", Programming: true, IDE: true},
+			{Name: "sublime", Process: `(?i)^sublime_text$`, WMClass: `(?i)^sublime_text$`, Title: `(?i)\bsublime text\b`, Category: "editor", Programming: true, IDE: true},
+			{Name: "gedit", Process: `(?i)^gedit$`, WMClass: `(?i)^(gedit|org\.gnome\.gedit)$`, Title: `(?i)\bgedit$`, Category: "editor", Programming: true},
+			{Name: "kate", Process: `(?i)^kate$`, WMClass: `(?i)^(kate|org\.kde\.kate)$`, Title: `(?i)\bkate$`, Category: "editor", Programming: true},
+			{Name: "nano", Process: `(?i)^nano$`, Title: `(?i)\bgnu nano\b`, Category: "editor", Programming: true},
+
+			// Browsers
+			{Name: "firefox", Process: `(?i)^firefox`, WMClass: `(?i)^firefox`, Title: `(?i)\bmozilla firefox$`, Category: "browser"},
+			{Name: "chrome", Process: `(?i)^(chrome|google-chrome.*)$`, WMClass: `(?i)^google-chrome`, Title: `(?i)\bgoogle chrome$`, Category: "browser"},
+			{Name: "chromium", Process: `(?i)^chromium`, WMClass: `(?i)^chromium`, Title: `(?i)\bchromium$`, Category: "browser"},
+		},
+		// Markers are checked in this order, so languages whose markers
+		// commonly sit next to another language's come first (tsconfig.json
+		// next to package.json, go.mod next to flake.nix)
+		Languages: []LanguageRuleConfig{
+			{Name: "go", Extensions: []string{".go"}, Markers: []string{"go.mod"}},
+			{Name: "rust", Extensions: []string{".rs"}, Interpreters: []string{"rust-script"}, Markers: []string{"Cargo.toml"}},
+			{Name: "typescript", Extensions: []string{".ts", ".tsx", ".mts", ".cts"}, Interpreters: []string{"deno", "ts-node", "tsx"}, Markers: []string{"tsconfig.json"}},
+			{Name: "javascript", Extensions: []string{".js", ".jsx", ".mjs", ".cjs"}, Interpreters: []string{"node", "nodejs", "bun"}, Markers: []string{"package.json"}},
+			{Name: "python", Extensions: []string{".py", ".pyw", ".pyi"}, Interpreters: []string{"python", "pypy", "uv"}, Markers: []string{"pyproject.toml", "requirements.txt", "setup.py", "Pipfile"}},
+			{Name: "kotlin", Extensions: []string{".kt", ".kts"}, Interpreters: []string{"kotlin", "kscript"}, Markers: []string{"build.gradle.kts"}},
+			{Name: "java", Extensions: []string{".java"}, Markers: []string{"pom.xml", "build.gradle"}},
+			{Name: "scala", Extensions: []string{".scala", ".sc"}, Interpreters: []string{"scala", "scala-cli", "amm"}, Markers: []string{"build.sbt"}},
+			{Name: "c", Extensions: []string{".c", ".h"}},
+			{Name: "cpp", Extensions: []string{".cpp", ".cc", ".cxx", ".c++", ".hpp", ".hh", ".hxx"}, Markers: []string{"CMakeLists.txt", "meson.build"}},
+			{Name: "csharp", Extensions: []string{".cs", ".csx"}},
+			{Name: "ruby", Extensions: []string{".rb", ".rake", ".gemspec"}, FileNames: []string{"Rakefile", "Gemfile", "Vagrantfile", "Guardfile", "Podfile", "Brewfile"}, Interpreters: []string{"ruby", "jruby"}, Markers: []string{"Gemfile"}},
+			{Name: "php", Extensions: []string{".php"}, Interpreters: []string{"php"}, Markers: []string{"composer.json"}},
+			{Name: "swift", Extensions: []string{".swift"}, Interpreters: []string{"swift"}, Markers: []string{"Package.swift"}},
+			{Name: "dart", Extensions: []string{".dart"}, Interpreters: []string{"dart"}, Markers: []string{"pubspec.yaml"}},
+			{Name: "elixir", Extensions: []string{".ex", ".exs", ".heex"}, Interpreters: []string{"elixir"}, Markers: []string{"mix.exs"}},
+			{Name: "erlang", Extensions: []string{".erl", ".hrl"}, Interpreters: []string{"escript"}, Markers: []string{"rebar.config"}},
+			{Name: "haskell", Extensions: []string{".hs", ".lhs"}, Interpreters: []string{"runghc", "runhaskell", "stack"}, Markers: []string{"stack.yaml", "cabal.project"}},
+			{Name: "ocaml", Extensions: []string{".ml", ".mli"}, Interpreters: []string{"ocaml"}, Markers: []string{"dune-project"}},
+			{Name: "clojure", Extensions: []string{".clj", ".cljs", ".cljc", ".edn"}, Interpreters: []string{"bb", "clojure", "clj"}, Markers: []string{"project.clj", "deps.edn"}},
+			{Name: "zig", Extensions: []string{".zig"}, Markers: []string{"build.zig"}},
+			{Name: "lua", Extensions: []string{".lua"}, Interpreters: []string{"lua", "luajit"}},
+			{Name: "perl", Extensions: []string{".pl", ".pm"}, Interpreters: []string{"perl"}},
+			{Name: "julia", Extensions: []string{".jl"}, Interpreters: []string{"julia"}},
+			{Name: "shell", Extensions: []string{".sh", ".bash", ".zsh", ".fish"}, FileNames: []string{"PKGBUILD", ".bashrc", ".bash_profile", ".profile", ".zshrc", ".zprofile", "APKBUILD"}, Interpreters: []string{"sh", "bash", "zsh", "dash", "ksh", "fish"}},
+			{Name: "make", Extensions: []string{".mk", ".mak"}, FileNames: []string{"Makefile", "makefile", "GNUmakefile"}, Interpreters: []string{"make"}},
+			{Name: "cmake", Extensions: []string{".cmake"}, FileNames: []string{"CMakeLists.txt"}},
+			{Name: "meson", FileNames: []string{"meson.build", "meson_options.txt", "meson.options"}},
+			{Name: "docker", Extensions: []string{".dockerfile", ".containerfile"}, FileNames: []string{"Dockerfile", "Containerfile"}},
+			{Name: "groovy", Extensions: []string{".groovy", ".gradle"}, FileNames: []string{"Jenkinsfile"}, Interpreters: []string{"groovy"}},
+			{Name: "starlark", Extensions: []string{".bzl", ".star"}, FileNames: []string{"BUILD", "BUILD.bazel", "WORKSPACE", "WORKSPACE.bazel", "MODULE.bazel", "Tiltfile"}},
+			{Name: "just", Extensions: []string{".just"}, FileNames: []string{"justfile", "Justfile", ".justfile"}},
+			{Name: "nix", Extensions: []string{".nix"}, Interpreters: []string{"nix-shell"}, Markers: []string{"flake.nix", "default.nix"}},
+			{Name: "sql", Extensions: []string{".sql"}},
+			{Name: "html", Extensions: []string{".html", ".htm"}},
+			{Name: "css", Extensions: []string{".css", ".scss", ".sass", ".less"}},
+			{Name: "markdown", Extensions: []string{".md", ".markdown"}},
+			{Name: "yaml", Extensions: []string{".yml", ".yaml"}},
+			{Name: "toml", Extensions: []string{".toml"}},
+			{Name: "json", Extensions: []string{".json", ".jsonc"}},
+		},
+		// Title patterns run against the title with the browser's name removed
+		Sites: []SiteRuleConfig{
+			// Code review and issues first, since they're on the same
+			// sites as the code
+			{Name: "github-pull-request", Site: "GitHub", Title: `(?i)\bpull requests?(?: #\d+)? · (?P<project>[\w.-]+/[\w.-]+)`, Category: "code_review"},
+			{Name: "github-issue", Site: "GitHub", Title: `(?i)\bissues?(?: #\d+)? · (?P<project>[\w.-]+/[\w.-]+)`, Category: "issue_tracker"},
+			{Name: "gitlab-merge-request", Site: "GitLab", Title: `(?i)(\(!\d+\) · )?merge requests · (?P<project>[^·]+?) · gitlab$`, Category: "code_review"},
+			{Name: "gitlab-issue", Site: "GitLab", Title: `(?i)(\(#\d+\) · )?issues · (?P<project>[^·]+?) · gitlab$`, Category: "issue_tracker"},
+			{Name: "bitbucket-pull-request", Site: "Bitbucket", Title: `(?i)\bpull request #\d+\b.*\bbitbucket$`, Category: "code_review"},
+			{Name: "gerrit", Site: "Gerrit", Title: `(?i)\bgerrit( code review)?\b`, Category: "code_review"},
+			{Name: "jira", Site: "Jira", Title: `(?i)^\[[a-z][a-z0-9]+-\d+\]|[-–—] jira$`, Domains: []string{"atlassian.net"}, Category: "issue_tracker"},
+			{Name: "linear", Site: "Linear", Title: `(?i)[-–—|] linear$`, Domains: []string{"linear.app"}, Category: "issue_tracker"},
+			{Name: "github", Site: "GitHub", Title: `(?i)^github - (?P<project>[\w.-]+/[\w.-]+)|· github$`, Domains: []string{"github.com"}, Category: "code_hosting"},
+			{Name: "gitlab", Site: "GitLab", Title: `(?i)· gitlab$`, Domains: []string{"gitlab.com"}, Category: "code_hosting"},
+			{Name: "codeberg", Site: "Codeberg", Title: `(?i)[-–—] codeberg\.org$`, Domains: []string{"codeberg.org"}, Category: "code_hosting"},
+
+			// Documentation, with the language where a site covers one
+			{Name: "go-packages", Site: "Go Packages", Title: `(?i)[-–—] go packages$|[-–—] the go programming language$`, Domains: []string{"pkg.go.dev", "go.dev"}, Category: "docs", Language: "go"},
+			{Name: "rust-docs", Site: "Rust docs", Title: `(?i)[-–—] rust$|\bthe rust (programming language|reference)$`, Domains: []string{"docs.rs", "doc.rust-lang.org"}, Category: "docs", Language: "rust"},
+			{Name: "python-docs", Site: "Python docs", Title: `(?i)\bpython [\d.]+ documentation$`, Domains: []string{"docs.python.org"}, Category: "docs", Language: "python"},
+			{Name: "mdn", Site: "MDN", Title: `(?i)\bmdn( web docs)?$`, Domains: []string{"developer.mozilla.org"}, Category: "docs", Language: "javascript"},
+			{Name: "typescript-docs", Site: "TypeScript docs", Title: `(?i)[-–—] typescript$|^typescript: (documentation|handbook)`, Domains: []string{"typescriptlang.org"}, Category: "docs", Language: "typescript"},
+			{Name: "cppreference", Site: "cppreference", Title: `(?i)\bcppreference\.com$`, Domains: []string{"cppreference.com"}, Category: "docs", Language: "cpp"},
+			{Name: "stack-overflow", Site: "Stack Overflow", Title: `(?i)[-–—] stack overflow$`, Domains: []string{"stackoverflow.com", "stackexchange.com"}, Category: "docs"},
+			{Name: "docs", Site: "the docs", Title: `(?i)\b(documentation|docs|api reference|devdocs|read the docs)\b`, Domains: []string{"readthedocs.io", "devdocs.io"}, Category: "docs"},
+
+			// Social media
+			{Name: "reddit", Site: "Reddit", Title: `(?i)(^|\s)r/\w+\b|\breddit\b`, Domains: []string{"reddit.com"}, Category: "social"},
+			{Name: "x", Site: "X", Title: `(?i) / (x|twitter)$`, Domains: []string{"x.com", "twitter.com"}, Category: "social"},
+			{Name: "hacker-news", Site: "Hacker News", Title: `(?i)\| hacker news$`, Domains: []string{"news.ycombinator.com"}, Category: "social"},
+			{Name: "facebook", Site: "Facebook", Title: `(?i)\bfacebook$`, Domains: []string{"facebook.com"}, Category: "social"},
+			{Name: "instagram", Site: "Instagram", Title: `(?i)\binstagram\b`, Domains: []string{"instagram.com"}, Category: "social"},
+			{Name: "linkedin", Site: "LinkedIn", Title: `(?i)\| linkedin$`, Domains: []string{"linkedin.com"}, Category: "social"},
+			{Name: "bluesky", Site: "Bluesky", Title: `(?i)[-–—] bluesky$`, Domains: []string{"bsky.app"}, Category: "social"},
+			{Name: "mastodon", Site: "Mastodon", Title: `(?i)\bmastodon\b`, Category: "social"},
+			{Name: "tiktok", Site: "TikTok", Title: `(?i)\btiktok\b`, Domains: []string{"tiktok.com"}, Category: "social"},
+
+			// Video
+			{Name: "youtube", Site: "YouTube", Title: `(?i)[-–—] youtube$`, Domains: []string{"youtube.com", "youtu.be"}, Category: "video"},
+			{Name: "twitch", Site: "Twitch", Title: `(?i)[-–—] twitch$`, Domains: []string{"twitch.tv"}, Category: "video"},
+			{Name: "netflix", Site: "Netflix", Title: `(?i)^netflix$|[-–—] netflix$`, Domains: []string{"netflix.com"}, Category: "video"},
+			{Name: "vimeo", Site: "Vimeo", Title: `(?i)\bon vimeo$`, Domains: []string{"vimeo.com"}, Category: "video"},
+			{Name: "prime-video", Site: "Prime Video", Title: `(?i)\bprime video\b`, Category: "video"},
+			{Name: "disney-plus", Site: "Disney+", Title: `(?i)\bdisney\+`, Category: "video"},
+		},
+		Activities: []ActivityRuleConfig{
+			{Name: "chat-and-mail", Activity: "communication", Program: `(?i)^(slack|discord|thunderbird|evolution|geary|mailspring|signal(-desktop)?|telegram(-desktop)?|element(-desktop)?|mattermost(-desktop)?|zulip|hexchat|weechat|irssi|mutt|neomutt|aerc)$`},
+			{Name: "web-chat-and-mail", Activity: "communication", Program: `^(firefox|chrome|chromium)$`, Title: `(?i)[-–—|] (gmail|outlook|slack|discord|microsoft teams|proton mail|fastmail|element)( [-–—] [^-–—]+)?$`},
+			{Name: "meeting-apps", Activity: "meetings", Program: `(?i)^(zoom|zoom\.real|teams-for-linux|skypeforlinux)$`},
+			{Name: "web-meetings", Activity: "meetings", Program: `^(firefox|chrome|chromium)$`, Title: `(?i)^meet [-–—] [a-z]{3}-[a-z]{4}-[a-z]{3}\b|\bgoogle meet\b|\bjitsi meet\b|\bzoom meeting\b|\bmeeting\b.*\| microsoft teams`},
+			{Name: "media-and-games", Activity: "entertainment", Program: `(?i)^(spotify|vlc|mpv|totem|celluloid|rhythmbox|freetube|kodi|steam|lutris|heroic|minecraft.*)$`},
+			{Name: "system-tools", Activity: "system", Program: `(?i)^(nautilus|org\.gnome\.nautilus|dolphin|thunar|nemo|pcmanfm|ranger|lf|nnn|yazi|gnome-control-center|systemsettings|pavucontrol|nm-connection-editor|blueman-manager|gnome-system-monitor|htop|btop|top|nvtop|gnome-software|discover|synaptic|pamac-manager)$`},
+		},
+		Neovim: &NeovimConfig{Enabled: true},
+		Heartbeat: &HeartbeatConfig{
+			Listen: []string{defaultHeartbeatListen},
+			MaxAge: defaultHeartbeatMaxAge.String(),
+		},
+		Meetings: &MeetingConfig{
+			Enabled:   true,
+			Processes: defaultMeetingProcesses,
+		},
+		Notifications: &NotificationsConfig{
+			Backends: []NotifierConfig{{Type: "dbus"}},
+		},
+		DoNotDisturb: &DoNotDisturbConfig{Enabled: true, Digest: true},
+	}
+}
+
+// Programs whose microphone use means a call. Browsers are included for
+// Meet, Jitsi and the web versions of everything else.
+var defaultMeetingProcesses = []string{
+	`(?i)^(zoom|zoom\.real|zoom voiceengine|teams|teams-for-linux|skypeforlinux|webex|jitsi-meet)$`,
+	`(?i)^(slack|discord|webcord|vesktop|signal-desktop|element-desktop|telegram-desktop|mumble)$`,
+	`(?i)^(firefox|firefox-bin|firefox-esr|librewolf|chrome|chromium|chromium-browser|google-chrome|brave|vivaldi-bin|msedge)$`,
+}
+
+// LoadConfig reads the config file, validates it and merges it over the
+// defaults. A missing file just means the defaults.
+func LoadConfig() (*Config, error) {
+	config := DefaultConfig()
+
+	configDir, err := getStateDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config directory: %w", err)
+	}
+	configFile := filepath.Join(configDir, "config.json")
+
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var user Config
+	if err := json.Unmarshal(data, &user); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", configFile, err)
+	}
+	if err := user.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s:\n%w", configFile, err)
+	}
+
+	config.merge(&user)
+	return config, nil
+}
+
+// Validate checks every rule and reports all problems at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	programNames := make(map[string]bool)
+	for i, rule := range c.Programs {
+		where := fmt.Sprintf("programs[%d]", i)
+		if rule.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: name is required", where))
+		} else {
+			where = fmt.Sprintf("%s (%s)", where, rule.Name)
+			if programNames[rule.Name] {
+				errs = append(errs, fmt.Errorf("%s: duplicate program name", where))
+			}
+			programNames[rule.Name] = true
+		}
+
+		if rule.Title == "" && rule.Process == "" && rule.WMClass == "" {
+			errs = append(errs, fmt.Errorf("%s: at least one of title, process or wm_class is required", where))
+		}
+		patterns := []struct{ field, pattern string }{
+			{"title", rule.Title},
+			{"process", rule.Process},
+			{"wm_class", rule.WMClass},
+		}
+		for _, p := range patterns {
+			if p.pattern == "" {
+				continue
+			}
+			if _, err := regexp.Compile(p.pattern); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid %s pattern: %w", where, p.field, err))
+			}
+		}
+	}
+
+	languageNames := make(map[string]bool)
+	for i, rule := range c.Languages {
+		where := fmt.Sprintf("languages[%d]", i)
+		if rule.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: name is required", where))
+		} else {
+			where = fmt.Sprintf("%s (%s)", where, rule.Name)
+			if languageNames[rule.Name] {
+				errs = append(errs, fmt.Errorf("%s: duplicate language name", where))
+			}
+			languageNames[rule.Name] = true
+		}
+
+		if len(rule.Extensions) == 0 && len(rule.FileNames) == 0 && len(rule.Interpreters) == 0 && len(rule.Markers) == 0 {
+			errs = append(errs, fmt.Errorf("%s: at least one extension, filename, interpreter or marker is required", where))
+		}
+		for _, ext := range rule.Extensions {
+			if !strings.HasPrefix(ext, ".") || len(ext) < 2 {
+				errs = append(errs, fmt.Errorf("%s: extension %q must start with a dot", where, ext))
+			}
+		}
+		for _, name := range rule.FileNames {
+			if name == "" || strings.ContainsRune(name, filepath.Separator) {
+				errs = append(errs, fmt.Errorf("%s: filename %q must be a plain file name", where, name))
+			}
+		}
+		for _, interpreter := range rule.Interpreters {
+			if interpreter == "" || strings.ContainsAny(interpreter, "/ ") {
+				errs = append(errs, fmt.Errorf("%s: interpreter %q must be a bare command name", where, interpreter))
+			}
+		}
+		for _, marker := range rule.Markers {
+			if marker == "" || strings.ContainsRune(marker, filepath.Separator) {
+				errs = append(errs, fmt.Errorf("%s: marker %q must be a plain file name", where, marker))
+			}
+		}
+	}
+
+	siteNames := make(map[string]bool)
+	for i, rule := range c.Sites {
+		where := fmt.Sprintf("sites[%d]", i)
+		if rule.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: name is required", where))
+		} else {
+			where = fmt.Sprintf("%s (%s)", where, rule.Name)
+			if siteNames[rule.Name] {
+				errs = append(errs, fmt.Errorf("%s: duplicate site name", where))
+			}
+			siteNames[rule.Name] = true
+		}
+
+		if rule.Title == "" && len(rule.Domains) == 0 {
+			errs = append(errs, fmt.Errorf("%s: at least one of title or domains is required", where))
+		}
+		if rule.Title != "" {
+			if _, err := regexp.Compile(rule.Title); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid title pattern: %w", where, err))
+			}
+		}
+		for _, domain := range rule.Domains {
+			if domain == "" || strings.ContainsAny(domain, "/: ") {
+				errs = append(errs, fmt.Errorf("%s: domain %q must be a bare host name", where, domain))
+			}
+		}
+		if !siteCategories[rule.Category] {
+			errs = append(errs, fmt.Errorf("%s: category %q must be one of docs, code_review, issue_tracker, code_hosting, social or video", where, rule.Category))
+		}
+	}
+
+	activityNames := make(map[string]bool)
+	for i, rule := range c.Activities {
+		where := fmt.Sprintf("activities[%d]", i)
+		if rule.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: name is required", where))
+		} else {
+			where = fmt.Sprintf("%s (%s)", where, rule.Name)
+			if activityNames[rule.Name] {
+				errs = append(errs, fmt.Errorf("%s: duplicate activity rule name", where))
+			}
+			activityNames[rule.Name] = true
+		}
+
+		if !activities[rule.Activity] {
+			errs = append(errs, fmt.Errorf("%s: activity %q must be one of coding, reviewing, docs, communication, meetings, entertainment, system or uncategorized", where, rule.Activity))
+		}
+		if rule.Program == "" && rule.Title == "" && rule.Project == "" && rule.SiteCategory == "" {
+			errs = append(errs, fmt.Errorf("%s: at least one of program, title, project or site_category is required", where))
+		}
+		patterns := []struct{ field, pattern string }{
+			{"program", rule.Program},
+			{"title", rule.Title},
+			{"project", rule.Project},
+		}
+		for _, p := range patterns {
+			if p.pattern == "" {
+				continue
+			}
+			if _, err := regexp.Compile(p.pattern); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid %s pattern: %w", where, p.field, err))
+			}
+		}
+		if rule.SiteCategory != "" && !siteCategories[rule.SiteCategory] {
+			errs = append(errs, fmt.Errorf("%s: unknown site_category %q", where, rule.SiteCategory))
+		}
+	}
+
+	if h := c.Heartbeat; h != nil {
+		if h.MaxAge != "" {
+			if d, err := time.ParseDuration(h.MaxAge); err != nil || d <= 0 {
+				errs = append(errs, fmt.Errorf("heartbeat: max_age %q must be a positive duration like \"2m\"", h.MaxAge))
+			}
+		}
+		for _, address := range h.Listen {
+			if err := validateHeartbeatAddress(address); err != nil {
+				errs = append(errs, fmt.Errorf("heartbeat: listen %q: %w", address, err))
+			}
+		}
+	}
+
+	if m := c.Meetings; m != nil {
+		for i, pattern := range m.Processes {
+			if _, err := regexp.Compile(pattern); err != nil {
+				errs = append(errs, fmt.Errorf("meetings: invalid processes[%d] pattern: %w", i, err))
+			}
+		}
+	}
+
+	if n := c.Notifications; n != nil {
+		if len(n.Backends) == 0 {
+			errs = append(errs, errors.New("notifications: at least one backend is required"))
+		}
+		for i, backend := range n.Backends {
+			where := fmt.Sprintf("notifications.backends[%d]", i)
+			if !notifierTypes[backend.Type] {
+				errs = append(errs, fmt.Errorf("%s: type %q must be one of dbus, log, stdout, jsonl or terminal", where, backend.Type))
+			}
+			if backend.Path != "" && !filepath.IsAbs(backend.Path) {
+				errs = append(errs, fmt.Errorf("%s: path %q must be absolute", where, backend.Path))
+			}
+			if backend.TTY != "" && !filepath.IsAbs(backend.TTY) {
+				errs = append(errs, fmt.Errorf("%s: tty %q must be absolute", where, backend.TTY))
+			}
+			if backend.Format != "" && !terminalFormats[backend.Format] {
+				errs = append(errs, fmt.Errorf("%s: format %q must be one of osc777, osc9 or bell", where, backend.Format))
+			}
+		}
+	}
+
+	if sc := c.Schedule; sc != nil {
+		for day, ranges := range sc.WorkingHours {
+			where := fmt.Sprintf("schedule.working_hours.%s", day)
+			if _, ok := scheduleDays[strings.ToLower(day)]; !ok {
+				errs = append(errs, fmt.Errorf("%s: day must be a day of the week, weekdays or weekend", where))
+			}
+			for _, r := range ranges {
+				if _, err := parseTimeRange(r); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", where, err))
+				}
+			}
+		}
+		if sc.WrapUp && len(sc.WorkingHours) == 0 {
+			errs = append(errs, errors.New("schedule: wrap_up needs working_hours"))
+		}
+		for _, r := range sc.QuietHours {
+			if _, err := parseTimeRange(r); err != nil {
+				errs = append(errs, fmt.Errorf("schedule.quiet_hours: %w", err))
+			}
+		}
+		for _, day := range sc.Holidays {
+			if _, err := time.Parse(holidayLayout, day); err != nil {
+				errs = append(errs, fmt.Errorf("schedule.holidays: %q is not a date like \"2026-12-25\"", day))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateHeartbeatAddress accepts Unix sockets and loopback TCP addresses;
+// there's no authentication, so nothing else on the network may report.
+// Web pages on this machine are kept out by the server itself.
+func validateHeartbeatAddress(address string) error {
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		if !filepath.IsAbs(path) {
+			return errors.New("socket path must be absolute")
+		}
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !isLoopbackHost(host) {
+		return errors.New("only loopback addresses are allowed")
+	}
+	return nil
+}
+
+// merge lays the user's rules over the current ones, replacing rules with
+// the same name and appending new ones.
+func (c *Config) merge(user *Config) {
+	for _, rule := range user.Programs {
+		replaced := false
+		for i := range c.Programs {
+			if c.Programs[i].Name == rule.Name {
+				c.Programs[i] = rule
+				replaced = true
+			}
+		}
+		if !replaced {
+			c.Programs = append(c.Programs, rule)
+		}
+	}
+
+	for _, rule := range user.Languages {
+		replaced := false
+		for i := range c.Languages {
+			if c.Languages[i].Name == rule.Name {
+				c.Languages[i] = rule
+				replaced = true
+			}
+		}
+		if !replaced {
+			c.Languages = append(c.Languages, rule)
+		}
+	}
+
+	// New site rules go before the built-in ones, which end with catch-alls
+	var sites []SiteRuleConfig
+	for _, rule := range user.Sites {
+		replaced := false
+		for i := range c.Sites {
+			if c.Sites[i].Name == rule.Name {
+				c.Sites[i] = rule
+				replaced = true
+			}
+		}
+		if !replaced {
+			sites = append(sites, rule)
+		}
+	}
+	c.Sites = append(sites, c.Sites...)
+
+	// New activity rules go first so they can override the built-in ones
+	var activityRules []ActivityRuleConfig
+	for _, rule := range user.Activities {
+		replaced := false
+		for i := range c.Activities {
+			if c.Activities[i].Name == rule.Name {
+				c.Activities[i] = rule
+				replaced = true
+			}
+		}
+		if !replaced {
+			activityRules = append(activityRules, rule)
+		}
+	}
+	c.Activities = append(activityRules, c.Activities...)
+
+	if user.Neovim != nil {
+		c.Neovim = user.Neovim
+	}
+	if user.Heartbeat != nil {
+		c.Heartbeat = user.Heartbeat
+		if len(c.Heartbeat.Listen) == 0 {
+			c.Heartbeat.Listen = []string{defaultHeartbeatListen}
+		}
+	}
+	if user.Meetings != nil {
+		c.Meetings = user.Meetings
+		if user.Meetings.Processes == nil {
+			c.Meetings.Processes = defaultMeetingProcesses
+		}
+	}
+	if user.Notifications != nil {
+		c.Notifications = user.Notifications
+	}
+	if user.DoNotDisturb != nil {
+		c.DoNotDisturb = user.DoNotDisturb
+	}
+	if user.Schedule != nil {
+		c.Schedule = user.Schedule
+	}
+}
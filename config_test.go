@@ -0,0 +1,183 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string // in the error, empty for a valid config
+	}{
+		{"empty", Config{}, ""},
+		{
+			"valid rules",
+			Config{
+				Programs:  []ProgramRuleConfig{{Name: "zed", Process: `(?i)^zed$`, Programming: true}},
+				Sites:     []SiteRuleConfig{{Name: "wiki", Domains: []string{"wiki.example.com"}, Category: "docs"}},
+				Heartbeat: &HeartbeatConfig{Enabled: true, Listen: []string{"127.0.0.1:9000", "[::1]:9000", "unix:/run/user/1000/es.sock"}},
+				Schedule: &ScheduleConfig{
+					WorkingHours: map[string][]string{"Weekdays": {"09:00-12:00", "13:00-17:30"}, "saturday": {"22:00-02:00"}},
+					QuietHours:   []string{"23:00-07:00"},
+					Holidays:     []string{"2026-12-25"},
+				},
+			},
+			"",
+		},
+		{
+			"bad program regex",
+			Config{Programs: []ProgramRuleConfig{{Name: "zed", Title: `(zed`}}},
+			"programs[0] (zed): invalid title pattern",
+		},
+		{
+			"bad activity regex",
+			Config{Activities: []ActivityRuleConfig{{Name: "work", Activity: "coding", Project: `[work`}}},
+			"activities[0] (work): invalid project pattern",
+		},
+		{
+			"unknown site category",
+			Config{Sites: []SiteRuleConfig{{Name: "wiki", Domains: []string{"wiki.example.com"}, Category: "wiki"}}},
+			`sites[0] (wiki): category "wiki" must be one of`,
+		},
+		{
+			"unknown activity",
+			Config{Activities: []ActivityRuleConfig{{Name: "work", Activity: "working", Program: "vim"}}},
+			`activities[0] (work): activity "working" must be one of`,
+		},
+		{
+			"bad schedule day",
+			Config{Schedule: &ScheduleConfig{WorkingHours: map[string][]string{"workdays": {"09:00-17:00"}}}},
+			"schedule.working_hours.workdays: day must be",
+		},
+		{
+			"bad working range",
+			Config{Schedule: &ScheduleConfig{WorkingHours: map[string][]string{"monday": {"9-5"}}}},
+			`schedule.working_hours.monday: "9" is not a time`,
+		},
+		{
+			"empty quiet range",
+			Config{Schedule: &ScheduleConfig{QuietHours: []string{"22:00-22:00"}}},
+			`schedule.quiet_hours: "22:00-22:00" is empty`,
+		},
+		{
+			"quiet range without a dash",
+			Config{Schedule: &ScheduleConfig{QuietHours: []string{"22:00"}}},
+			`schedule.quiet_hours: "22:00" is not a range`,
+		},
+		{
+			"bad holiday",
+			Config{Schedule: &ScheduleConfig{Holidays: []string{"25/12/2026"}}},
+			`schedule.holidays: "25/12/2026" is not a date`,
+		},
+		{
+			"wrap-up without working hours",
+			Config{Schedule: &ScheduleConfig{WrapUp: true}},
+			"schedule: wrap_up needs working_hours",
+		},
+		{
+			"heartbeat on every interface",
+			Config{Heartbeat: &HeartbeatConfig{Enabled: true, Listen: []string{"0.0.0.0:8975"}}},
+			`heartbeat: listen "0.0.0.0:8975": only loopback addresses are allowed`,
+		},
+		{
+			"heartbeat on a LAN address",
+			Config{Heartbeat: &HeartbeatConfig{Enabled: true, Listen: []string{"192.168.1.10:8975"}}},
+			"only loopback addresses are allowed",
+		},
+		{
+			"relative heartbeat socket",
+			Config{Heartbeat: &HeartbeatConfig{Enabled: true, Listen: []string{"unix:es.sock"}}},
+			"socket path must be absolute",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	config := Config{
+		Programs: []ProgramRuleConfig{{Name: "zed", Title: `(zed`}, {Name: "zed", Process: "zed"}},
+		Schedule: &ScheduleConfig{QuietHours: []string{"late"}},
+	}
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil")
+	}
+	if lines := strings.Split(err.Error(), "\n"); len(lines) != 3 {
+		t.Errorf("Validate() reported %d problems, want 3:\n%v", len(lines), err)
+	}
+}
+
+func TestMergeReplacesByName(t *testing.T) {
+	config := DefaultConfig()
+	programs, sites := len(config.Programs), len(config.Sites)
+
+	config.merge(&Config{
+		Programs: []ProgramRuleConfig{
+			{Name: "vim", Process: `(?i)^nvim$`, Category: "editor", Programming: true},
+			{Name: "zed", Process: `(?i)^zed$`, Category: "editor", Programming: true},
+		},
+		Sites: []SiteRuleConfig{
+			{Name: "github", Domains: []string{"github.example.com"}, Category: "code_hosting"},
+			{Name: "wiki", Domains: []string{"wiki.example.com"}, Category: "docs"},
+		},
+	})
+
+	if len(config.Programs) != programs+1 {
+		t.Errorf("%d program rules, want the %d built-in ones and zed", len(config.Programs), programs)
+	}
+	var vims int
+	for _, rule := range config.Programs {
+		if rule.Name == "vim" {
+			vims++
+			if rule.Process != `(?i)^nvim$` || rule.Title != "" {
+				t.Errorf("vim rule %+v, want the user's", rule)
+			}
+		}
+	}
+	if vims != 1 {
+		t.Errorf("%d vim rules, want 1", vims)
+	}
+	if last := config.Programs[len(config.Programs)-1]; last.Name != "zed" {
+		t.Errorf("last program rule %q, want the new one appended", last.Name)
+	}
+
+	// New site rules go first, ahead of the built-in catch-alls
+	if len(config.Sites) != sites+1 || config.Sites[0].Name != "wiki" {
+		t.Errorf("%d site rules starting with %q, want %d starting with wiki", len(config.Sites), config.Sites[0].Name, sites+1)
+	}
+	for _, rule := range config.Sites {
+		if rule.Name == "github" && rule.Domains[0] != "github.example.com" {
+			t.Errorf("github rule %+v, want the user's", rule)
+		}
+	}
+}
+
+func TestMergeDefaults(t *testing.T) {
+	config := DefaultConfig()
+	config.merge(&Config{
+		Heartbeat: &HeartbeatConfig{Enabled: true},
+		Meetings:  &MeetingConfig{Enabled: true},
+	})
+	if got := strings.Join(config.Heartbeat.Listen, ","); got != defaultHeartbeatListen {
+		t.Errorf("heartbeat listens on %q, want %q", got, defaultHeartbeatListen)
+	}
+	if len(config.Meetings.Processes) != len(defaultMeetingProcesses) {
+		t.Errorf("%d meeting processes, want the defaults", len(config.Meetings.Processes))
+	}
+}
@@ -78,24 +78,121 @@ func (d *Database) initSchema() error {
 		checked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 	);
 
+	CREATE TABLE IF NOT EXISTS away_periods (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		reason TEXT NOT NULL,
+		started_at TIMESTAMP NOT NULL,
+		ended_at TIMESTAMP NOT NULL,
+		duration_seconds INTEGER
+	);
+
+	CREATE TABLE IF NOT EXISTS session_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_type TEXT NOT NULL,
+		occurred_at TIMESTAMP NOT NULL
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_window_sessions_started ON window_sessions(started_at);
 	CREATE INDEX IF NOT EXISTS idx_window_sessions_program ON window_sessions(program);
 	CREATE INDEX IF NOT EXISTS idx_notifications_sent_at ON notifications(sent_at);
 	CREATE INDEX IF NOT EXISTS idx_notifications_type ON notifications(notification_type);
 	CREATE INDEX IF NOT EXISTS idx_window_checks_checked_at ON window_checks(checked_at);
+	CREATE INDEX IF NOT EXISTS idx_away_periods_started ON away_periods(started_at);
+	CREATE INDEX IF NOT EXISTS idx_session_events_occurred_at ON session_events(occurred_at);
 	`
 
-	_, err := d.db.Exec(schema)
+	if _, err := d.db.Exec(schema); err != nil {
+		return err
+	}
+	if err := d.migrate(); err != nil {
+		return err
+	}
+
+	// Indexes on migrated columns can only be created once they exist
+	_, err := d.db.Exec(`
+	CREATE INDEX IF NOT EXISTS idx_window_sessions_repo ON window_sessions(repo_name, git_branch);
+	CREATE INDEX IF NOT EXISTS idx_window_sessions_site ON window_sessions(site_category, site);
+	CREATE INDEX IF NOT EXISTS idx_window_sessions_activity ON window_sessions(activity, started_at);
+	CREATE INDEX IF NOT EXISTS idx_window_sessions_meeting ON window_sessions(is_meeting, started_at);
+	`)
 	return err
 }
 
+// Columns added after a table was first created. Databases from earlier
+// versions get them added on startup.
+var schemaMigrations = []struct {
+	table, column, definition string
+}{
+	{"window_sessions", "repo_root", "TEXT"},
+	{"window_sessions", "repo_name", "TEXT"},
+	{"window_sessions", "git_branch", "TEXT"},
+	{"window_sessions", "git_remote", "TEXT"},
+	{"window_sessions", "site", "TEXT"},
+	{"window_sessions", "site_category", "TEXT"},
+	{"window_sessions", "activity", "TEXT"},
+	{"window_sessions", "is_meeting", "INTEGER DEFAULT 0"},
+	{"notifications", "activity", "TEXT"},
+	{"notifications", "response", "TEXT"},
+	{"notifications", "responded_at", "TIMESTAMP"},
+}
+
+func (d *Database) migrate() error {
+	existing := make(map[string]map[string]bool)
+	for _, m := range schemaMigrations {
+		columns, ok := existing[m.table]
+		if !ok {
+			var err error
+			columns, err = d.tableColumns(m.table)
+			if err != nil {
+				return err
+			}
+			existing[m.table] = columns
+		}
+		if columns[m.column] {
+			continue
+		}
+		if _, err := d.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)); err != nil {
+			return fmt.Errorf("failed to add %s.%s: %w", m.table, m.column, err)
+		}
+		columns[m.column] = true
+	}
+	return nil
+}
+
+func (d *Database) tableColumns(table string) (map[string]bool, error) {
+	rows, err := d.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue interface{}
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
 func (d *Database) LogWindowSession(session *WindowSession) error {
 	query := `
 		INSERT INTO window_sessions (
 			window_key, program, window_title, process_name, pid,
 			language, is_programming, started_at, ended_at,
-			duration_seconds, project_path
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			duration_seconds, project_path, repo_root, repo_name,
+			git_branch, git_remote, site, site_category, activity,
+			is_meeting
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	var endedAt interface{}
@@ -120,6 +217,14 @@ func (d *Database) LogWindowSession(session *WindowSession) error {
 		endedAt,
 		durationSeconds,
 		session.ProjectPath,
+		session.RepoRoot,
+		session.RepoName,
+		session.GitBranch,
+		session.GitRemote,
+		session.Site,
+		session.SiteCategory,
+		session.Activity,
+		session.IsMeeting,
 	)
 
 	return err
@@ -128,27 +233,76 @@ func (d *Database) LogWindowSession(session *WindowSession) error {
 func (d *Database) LogNotification(notif *NotificationLog) error {
 	query := `
 		INSERT INTO notifications (
-			notification_type, title, message, program, language, duration_seconds
-		) VALUES (?, ?, ?, ?, ?, ?)
+			notification_type, title, message, program, language, duration_seconds,
+			activity, sent_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	var durationSeconds interface{}
 	if notif.DurationSeconds > 0 {
 		durationSeconds = notif.DurationSeconds
 	}
+	sentAt := notif.SentAt
+	if sentAt.IsZero() {
+		sentAt = time.Now()
+	}
 
-	_, err := d.db.Exec(query,
+	result, err := d.db.Exec(query,
 		notif.Type,
 		notif.Title,
 		notif.Message,
 		notif.Program,
 		notif.Language,
 		durationSeconds,
+		notif.Activity,
+		sentAt,
 	)
+	if err != nil {
+		return err
+	}
+
+	notif.ID, err = result.LastInsertId()
+	return err
+}
+
+// DeleteNotification removes a logged notification that couldn't be sent.
+func (d *Database) DeleteNotification(id int64) error {
+	_, err := d.db.Exec(`DELETE FROM notifications WHERE id = ?`, id)
 
 	return err
 }
 
+// LogNotificationResponse records what the user did with a notification.
+// Only the first response counts: pressing a button also closes it.
+func (d *Database) LogNotificationResponse(id int64, response string, at time.Time) error {
+	query := `
+		UPDATE notifications SET response = ?, responded_at = ?
+		WHERE id = ? AND response IS NULL
+	`
+
+	_, err := d.db.Exec(query, response, at, id)
+
+	return err
+}
+
+// LastNotificationTime returns when a notification of the given type was
+// last sent, or the zero time if none has been.
+func (d *Database) LastNotificationTime(notificationType string) (time.Time, error) {
+	var sentAt time.Time
+	err := d.db.QueryRow(`
+		SELECT sent_at FROM notifications
+		WHERE notification_type = ?
+		ORDER BY id DESC LIMIT 1
+	`, notificationType).Scan(&sentAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read the last %s notification: %w", notificationType, err)
+	}
+	return sentAt, nil
+}
+
 func (d *Database) LogWindowCheck(check *WindowCheck) error {
 	query := `
 		INSERT INTO window_checks (
@@ -167,32 +321,145 @@ func (d *Database) LogWindowCheck(check *WindowCheck) error {
 	return err
 }
 
+func (d *Database) LogAwayPeriod(period *AwayPeriod) error {
+	query := `
+		INSERT INTO away_periods (
+			reason, started_at, ended_at, duration_seconds
+		) VALUES (?, ?, ?, ?)
+	`
+
+	_, err := d.db.Exec(query,
+		period.Reason,
+		period.StartedAt,
+		period.EndedAt,
+		int(period.Duration.Seconds()),
+	)
+
+	return err
+}
+
+func (d *Database) LogSessionEvent(event *SessionEvent) error {
+	query := `
+		INSERT INTO session_events (event_type, occurred_at) VALUES (?, ?)
+	`
+
+	_, err := d.db.Exec(query, event.Kind, event.At)
+
+	return err
+}
+
+// Summary adds up the window sessions started between since and until, by
+// activity and language, and the breaks taken in that time.
+func (d *Database) Summary(since, until time.Time) (*DaySummary, error) {
+	summary := &DaySummary{Activities: make(map[string]time.Duration)}
+
+	rows, err := d.db.Query(`
+		SELECT
+			CASE WHEN is_meeting = 1 THEN 'meetings'
+				ELSE COALESCE(NULLIF(activity, ''), 'uncategorized') END,
+			SUM(duration_seconds)
+		FROM window_sessions
+		WHERE started_at >= ? AND started_at < ? AND duration_seconds > 0
+		GROUP BY 1
+	`, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum activities: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var activity string
+		var seconds int64
+		if err := rows.Scan(&activity, &seconds); err != nil {
+			return nil, fmt.Errorf("failed to read activities: %w", err)
+		}
+		summary.Activities[activity] = time.Duration(seconds) * time.Second
+		summary.Total += time.Duration(seconds) * time.Second
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read activities: %w", err)
+	}
+
+	rows, err = d.db.Query(`
+		SELECT language FROM window_sessions
+		WHERE started_at >= ? AND started_at < ? AND duration_seconds > 0
+			AND language IS NOT NULL AND language != ''
+		GROUP BY language
+		ORDER BY SUM(duration_seconds) DESC, language
+		LIMIT 3
+	`, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum languages: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var language string
+		if err := rows.Scan(&language); err != nil {
+			return nil, fmt.Errorf("failed to read languages: %w", err)
+		}
+		summary.Languages = append(summary.Languages, language)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read languages: %w", err)
+	}
+
+	var breakSeconds int64
+	err = d.db.QueryRow(`
+		SELECT COALESCE(SUM(duration_seconds), 0) FROM away_periods
+		WHERE reason = 'break' AND started_at >= ? AND started_at < ?
+	`, since, until).Scan(&breakSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum breaks: %w", err)
+	}
+	summary.Breaks = time.Duration(breakSeconds) * time.Second
+
+	return summary, nil
+}
+
 func (d *Database) Close() error {
 	return d.db.Close()
 }
 
 // Data structures for logging
 type WindowSession struct {
-	WindowKey      string
-	Program        string
-	WindowTitle    string
-	ProcessName    string
-	PID            string
-	Language       string
-	IsProgramming  bool
-	StartedAt      time.Time
-	EndedAt        time.Time
-	Duration       time.Duration
-	ProjectPath    string
+	WindowKey     string
+	Program       string
+	WindowTitle   string
+	ProcessName   string
+	PID           string
+	Language      string
+	IsProgramming bool
+	StartedAt     time.Time
+	EndedAt       time.Time
+	Duration      time.Duration
+	ProjectPath   string
+	RepoRoot      string
+	RepoName      string
+	GitBranch     string
+	GitRemote     string
+	Site          string
+	SiteCategory  string
+	Activity      string
+	IsMeeting     bool
 }
 
 type NotificationLog struct {
+	ID              int64 // Set once logged
 	Type            string
 	Title           string
 	Message         string
 	Program         string
 	Language        string
 	DurationSeconds int
+	Activity        string
+	SentAt          time.Time // Now if not set
+}
+
+// DaySummary is how a stretch of time was spent.
+type DaySummary struct {
+	Total      time.Duration
+	Activities map[string]time.Duration // Meetings count as "meetings" whatever the window
+	Languages  []string                 // Up to three, most used first
+	Breaks     time.Duration            // Time away after pressing "I'm on a break"
 }
 
 type WindowCheck struct {
@@ -203,3 +470,9 @@ type WindowCheck struct {
 	PID         string
 }
 
+type AwayPeriod struct {
+	Reason    string
+	StartedAt time.Time
+	EndedAt   time.Time
+	Duration  time.Duration
+}
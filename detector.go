@@ -1,66 +1,205 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
 type Context struct {
 	Program       string
 	WindowTitle   string
+	Category      string // Kind of program, from the matching rule
+	FileName      string // File being edited, as named in the title
+	FilePath      string // The same file on disk, when it could be found
 	IsProgramming bool
 	Language      string
 	IsIDE         bool
 	ProjectPath   string
+	ProjectName   string // Name given to the project in IDE project files
+	Lines         int    // Length of the file, when the editor reported it
+	Site          string // Site open in a browser tab
+	SiteCategory  string // Kind of site: docs, code_review, social, ...
+	Activity      string // Kind of work: coding, reviewing, meetings, ...
+	Terminal      string // Terminal emulator hosting Program, if any
+	Multiplexer   string // tmux or screen between the terminal and Program
+	GitRoot       string
+	RepoName      string
+	GitBranch     string
+	GitRemote     string
+
+	// Languages of the repository as a whole, by bytes of code
+	PrimaryLanguage    string
+	SecondaryLanguages []string
+}
+
+// ProgramRule recognises a program from its window. Any one of the
+// patterns matching is enough.
+type ProgramRule struct {
+	Name          string
+	Priority      int
+	Title         *regexp.Regexp
+	Process       *regexp.Regexp
+	WMClass       *regexp.Regexp
+	Category      string
+	IsProgramming bool
+	IsIDE         bool
+}
+
+// matchesProcess checks what the window says about its program directly.
+func (r *ProgramRule) matchesProcess(process, class string) bool {
+	return (r.Process != nil && process != "" && r.Process.MatchString(process)) ||
+		(r.WMClass != nil && class != "" && r.WMClass.MatchString(class))
+}
+
+func (r *ProgramRule) matchesTitle(title string) bool {
+	return r.Title != nil && title != "" && r.Title.MatchString(title)
+}
+
+// LanguageRule maps file names, extensions, shebang interpreters and project
+// marker files to a language.
+type LanguageRule struct {
+	Name         string
+	Extensions   []string
+	FileNames    []string
+	Interpreters []string
+	Markers      []string
 }
 
 type ContextDetector struct {
-	programPatterns map[string]*regexp.Regexp
-	languageExts    map[string][]string
+	programRules  []*ProgramRule  // Highest priority first
+	siteRules     []*SiteRule     // Config order
+	activityRules []*ActivityRule // Config order
+	languageRules []*LanguageRule // Config order, which is marker precedence
+	extLanguages  map[string]string
+	nameLanguages map[string]string
+	fileTypeCache map[string]fileTypeCacheEntry // Sniffed languages by path
+	workspaces    map[string]WorkspaceInfo      // IDE project files read during this DetectContext, by directory
+	scanner       *ProjectScanner
+	neovim        bool            // Ask Neovim over RPC what it's editing
+	heartbeats    *HeartbeatStore // What editor plugins have reported, if enabled
 }
 
-func NewContextDetector() *ContextDetector {
+// NewContextDetector builds the detector from a validated config.
+func NewContextDetector(config *Config) *ContextDetector {
 	cd := &ContextDetector{
-		programPatterns: make(map[string]*regexp.Regexp),
-		languageExts:    make(map[string][]string),
-	}
-
-	// Define patterns for common editors/IDEs
-	cd.programPatterns["vim"] = regexp.MustCompile(`(?i)vim|nvim|neovim`)
-	cd.programPatterns["vscode"] = regexp.MustCompile(`(?i)code|visual studio code`)
-	cd.programPatterns["emacs"] = regexp.MustCompile(`(?i)emacs`)
-	cd.programPatterns["idea"] = regexp.MustCompile(`(?i)idea|intellij`)
-	cd.programPatterns["sublime"] = regexp.MustCompile(`(?i)sublime`)
-	cd.programPatterns["gedit"] = regexp.MustCompile(`(?i)gedit`)
-	cd.programPatterns["kate"] = regexp.MustCompile(`(?i)kate`)
-	cd.programPatterns["nano"] = regexp.MustCompile(`(?i)nano`)
-
-	// Browsers
-	cd.programPatterns["firefox"] = regexp.MustCompile(`(?i)firefox`)
-	cd.programPatterns["chrome"] = regexp.MustCompile(`(?i)chrome`)
-	cd.programPatterns["chromium"] = regexp.MustCompile(`(?i)chromium`)
-
-	// Language file extensions
-	cd.languageExts["go"] = []string{".go"}
-	cd.languageExts["java"] = []string{".java"}
-	cd.languageExts["python"] = []string{".py", ".pyw"}
-	cd.languageExts["javascript"] = []string{".js", ".jsx", ".ts", ".tsx"}
-	cd.languageExts["rust"] = []string{".rs"}
-	cd.languageExts["cpp"] = []string{".cpp", ".cc", ".cxx", ".hpp", ".h", ".c"}
-	cd.languageExts["ruby"] = []string{".rb"}
-	cd.languageExts["php"] = []string{".php"}
-	cd.languageExts["kotlin"] = []string{".kt", ".kts"}
-	cd.languageExts["scala"] = []string{".scala"}
-	cd.languageExts["swift"] = []string{".swift"}
-	cd.languageExts["dart"] = []string{".dart"}
+		extLanguages:  make(map[string]string),
+		nameLanguages: make(map[string]string),
+		fileTypeCache: make(map[string]fileTypeCacheEntry),
+	}
+	// Headers both C and C++ projects have would only blur the difference
+	cd.scanner = NewProjectScanner(func(name string) string {
+		if sharedExtensions[strings.ToLower(filepath.Ext(name))] != nil {
+			return ""
+		}
+		return cd.languageForFile(name)
+	})
+	cd.neovim = config.Neovim != nil && config.Neovim.Enabled
+	if config.Heartbeat != nil && config.Heartbeat.Enabled {
+		cd.heartbeats = NewHeartbeatStore(config.Heartbeat.maxAge(), cd.programForEditor)
+	}
+
+	compile := func(pattern string) *regexp.Regexp {
+		if pattern == "" {
+			return nil
+		}
+		return regexp.MustCompile(pattern)
+	}
+	for _, rule := range config.Programs {
+		cd.programRules = append(cd.programRules, &ProgramRule{
+			Name:          rule.Name,
+			Priority:      rule.Priority,
+			Title:         compile(rule.Title),
+			Process:       compile(rule.Process),
+			WMClass:       compile(rule.WMClass),
+			Category:      rule.Category,
+			IsProgramming: rule.Programming,
+			IsIDE:         rule.IDE,
+		})
+	}
+	sort.SliceStable(cd.programRules, func(i, j int) bool {
+		return cd.programRules[i].Priority > cd.programRules[j].Priority
+	})
+
+	for _, rule := range config.Sites {
+		site := rule.Site
+		if site == "" {
+			site = rule.Name
+		}
+		cd.siteRules = append(cd.siteRules, &SiteRule{
+			Name:     rule.Name,
+			Site:     site,
+			Title:    compile(rule.Title),
+			Domains:  rule.Domains,
+			Category: rule.Category,
+			Language: rule.Language,
+		})
+	}
+
+	for _, rule := range config.Activities {
+		cd.activityRules = append(cd.activityRules, &ActivityRule{
+			Name:         rule.Name,
+			Activity:     rule.Activity,
+			Program:      compile(rule.Program),
+			Title:        compile(rule.Title),
+			Project:      compile(rule.Project),
+			SiteCategory: rule.SiteCategory,
+		})
+	}
+
+	// Later rules (the user's) take over extensions and names claimed by
+	// earlier ones
+	for _, rule := range config.Languages {
+		cd.languageRules = append(cd.languageRules, &LanguageRule{
+			Name:         rule.Name,
+			Extensions:   rule.Extensions,
+			FileNames:    rule.FileNames,
+			Interpreters: rule.Interpreters,
+			Markers:      rule.Markers,
+		})
+		for _, ext := range rule.Extensions {
+			cd.extLanguages[strings.ToLower(ext)] = rule.Name
+		}
+		for _, name := range rule.FileNames {
+			cd.nameLanguages[name] = rule.Name
+		}
+	}
 
 	return cd
 }
 
+// Terminal emulators whose windows we look inside
+var terminalPrograms = map[string]bool{
+	"alacritty": true, "kitty": true, "foot": true, "footclient": true,
+	"wezterm-gui": true, "gnome-terminal-": true, "gnome-terminal-server": true,
+	"kgx": true, "konsole": true, "xterm": true, "uxterm": true, "urxvt": true,
+	"rxvt": true, "st": true, "terminator": true, "tilix": true,
+	"xfce4-terminal": true, "lxterminal": true, "mate-terminal": true,
+	"qterminal": true, "terminology": true, "ghostty": true, "sakura": true,
+	"termite": true,
+}
+
+// Command-line tools and REPLs that count as programming, with the language
+// they imply when it can't be read from their arguments
+var terminalTools = map[string]string{
+	"python": "python", "python3": "python", "ipython": "python", "pip": "python",
+	"pytest": "python", "node": "javascript", "npm": "javascript", "npx": "javascript",
+	"yarn": "javascript", "pnpm": "javascript", "deno": "javascript", "bun": "javascript",
+	"cargo": "rust", "rustc": "rust", "go": "go", "gopls": "go", "dlv": "go",
+	"ruby": "ruby", "irb": "ruby", "bundle": "ruby", "rake": "ruby",
+	"java": "java", "jshell": "java", "mvn": "java", "gradle": "java",
+	"php": "php", "kotlin": "kotlin", "kotlinc": "kotlin", "sbt": "scala",
+	"scala": "scala", "swift": "swift", "dart": "dart", "flutter": "dart",
+	"gcc": "cpp", "g++": "cpp", "clang": "cpp", "clang++": "cpp", "cmake": "cpp",
+	"make": "", "ninja": "", "meson": "", "just": "", "gdb": "", "lldb": "",
+	"git": "", "lazygit": "", "tig": "",
+}
+
 func (cd *ContextDetector) DetectContext(windowInfo *WindowInfo) *Context {
 	ctx := &Context{
 		Program:       windowInfo.Process,
@@ -69,24 +208,24 @@ func (cd *ContextDetector) DetectContext(windowInfo *WindowInfo) *Context {
 		Language:      "",
 		IsIDE:         false,
 	}
+	cd.workspaces = make(map[string]WorkspaceInfo)
 
 	// Detect program type
 	titleLower := strings.ToLower(windowInfo.Title)
 	processLower := strings.ToLower(windowInfo.Process)
+	classLower := strings.ToLower(windowInfo.Class)
 
 	// Check for editors/IDEs and browsers
-	programmingPrograms := map[string]bool{
-		"vim": true, "vscode": true, "emacs": true, "idea": true,
-		"sublime": true, "gedit": true, "kate": true, "nano": true,
+	if rule := cd.matchProgram(titleLower, processLower, classLower); rule != nil {
+		ctx.Program = rule.Name
+		ctx.Category = rule.Category
+		ctx.IsProgramming = rule.IsProgramming
+		ctx.IsIDE = rule.IsIDE
 	}
 
-	for program, pattern := range cd.programPatterns {
-		if pattern.MatchString(titleLower) || pattern.MatchString(processLower) {
-			ctx.Program = program
-			ctx.IsProgramming = programmingPrograms[program]
-			ctx.IsIDE = (program == "vscode" || program == "idea" || program == "sublime")
-			break
-		}
+	// Browser titles say which site the tab is on
+	if ctx.Category == "browser" {
+		cd.detectSite(ctx, windowInfo.Title)
 	}
 
 	// If no pattern matched but we have a process name, use it
@@ -94,19 +233,303 @@ func (cd *ContextDetector) DetectContext(windowInfo *WindowInfo) *Context {
 		ctx.Program = windowInfo.Process
 	}
 
+	// A terminal's own name and title say little, so look at the
+	// process running in the foreground inside it
+	if terminalPrograms[processLower] {
+		if pid, err := strconv.Atoi(windowInfo.PID); err == nil {
+			if proc := foregroundProcess(pid); proc != nil {
+				ctx.Terminal = windowInfo.Process
+				cd.detectFromProcess(ctx, proc)
+			}
+		}
+	}
+
+	// GUIs like neovide run Neovim as a child process
+	if cd.neovim && ctx.Terminal == "" && ctx.Program == "vim" {
+		if pid, err := strconv.Atoi(windowInfo.PID); err == nil {
+			if proc := neovimProcess(pid); proc != nil {
+				cd.detectFromNeovim(ctx, proc)
+			}
+		}
+	}
+
+	// An editor plugin reporting heartbeats knows better than any of that
+	if cd.heartbeats != nil {
+		if hb := cd.heartbeats.Latest(ctx.Program, time.Now()); hb != nil {
+			cd.detectFromHeartbeat(ctx, hb)
+		}
+	}
+
 	// Try to extract file path from window title
-	ctx.ProjectPath = cd.extractPathFromTitle(windowInfo.Title)
+	if ctx.ProjectPath == "" && ctx.Category != "browser" {
+		ctx.ProjectPath = cd.extractPathFromTitle(windowInfo.Title)
+	}
+
+	// Find the repository the project lives in
+	branch := ctx.GitBranch
+	if repo := findGitRepo(ctx.ProjectPath); repo != nil {
+		ctx.GitRoot = repo.Root
+		ctx.RepoName = repo.Name
+		ctx.GitBranch = repo.Branch
+		ctx.GitRemote = repo.Remote
+		if ctx.GitBranch == "" {
+			ctx.GitBranch = branch
+		}
+		ctx.PrimaryLanguage, ctx.SecondaryLanguages = primaryAndSecondary(cd.scanner.Composition(repo.Root))
+	}
+
+	// IDEs keep their own name for the project
+	for _, dir := range []string{ctx.ProjectPath, ctx.GitRoot} {
+		if ctx.ProjectName == "" && dir != "" {
+			ctx.ProjectName = cd.workspace(dir).Name
+		}
+	}
 
 	// Detect language
-	ctx.Language = cd.detectLanguage(windowInfo.Title, ctx.ProjectPath, ctx.Program)
+	// Browser titles are page names; "README.md · GitHub" isn't being edited
+	if ctx.FileName == "" && ctx.Category != "browser" {
+		editor := ctx.Category == "editor" || ctx.Category == "ide" || ctx.Terminal != ""
+		ctx.FileName = cd.fileNameFromTitle(windowInfo.Title, ctx.ProjectPath, editor)
+		if ctx.FileName == "" {
+			// Scripts and the like have no extension to go by, but if the
+			// title names something that exists, its contents can tell
+			ctx.FilePath = cd.filePathFromTitle(windowInfo.Title, ctx.ProjectPath)
+			if ctx.FilePath != "" {
+				ctx.FileName = filepath.Base(ctx.FilePath)
+			}
+		}
+	}
+	if ctx.FilePath == "" && ctx.Category != "browser" {
+		ctx.FilePath = resolveFile(ctx.FileName, ctx.ProjectPath)
+	}
+	if ctx.Language == "" {
+		ctx.Language = cd.detectLanguage(ctx)
+	}
+	// Editors guess at headers too, so the project has the last word
+	ctx.Language = cd.languageInProject(ctx)
+
+	ctx.Activity = cd.classifyActivity(ctx)
 
 	return ctx
 }
 
+// matchProgram finds the rule for a window. The process name and WM_CLASS
+// identify a program reliably, so every rule gets a chance to match those
+// before any title is considered; titles often mention other programs.
+func (cd *ContextDetector) matchProgram(title, process, class string) *ProgramRule {
+	for _, rule := range cd.programRules {
+		if rule.matchesProcess(process, class) {
+			return rule
+		}
+	}
+	for _, rule := range cd.programRules {
+		if rule.matchesTitle(title) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// detectFromProcess fills in the context from a process running inside a
+// terminal, using its name, arguments and working directory.
+func (cd *ContextDetector) detectFromProcess(ctx *Context, proc *procInfo) {
+	comm := strings.ToLower(proc.Comm)
+
+	// Look through multiplexer clients at the pane they're showing
+	var pane *procInfo
+	switch {
+	case strings.HasPrefix(comm, "tmux"):
+		ctx.Multiplexer = "tmux"
+		pane = tmuxActivePane(proc)
+	case comm == "screen":
+		ctx.Multiplexer = "screen"
+		pane = screenActiveWindow(proc)
+	}
+	if pane != nil {
+		proc = pane
+		comm = strings.ToLower(proc.Comm)
+	}
+
+	ctx.ProjectPath = proc.Cwd
+
+	// A bare shell leaves the terminal as the program
+	if !shellNames[strings.TrimPrefix(comm, "-")] {
+		ctx.Program = comm
+		ctx.Category = ""
+		ctx.IsProgramming = false
+		ctx.IsIDE = false
+		if rule := cd.matchProgram("", comm, ""); rule != nil {
+			ctx.Program = rule.Name
+			ctx.Category = rule.Category
+			ctx.IsProgramming = rule.IsProgramming
+		}
+		if _, ok := terminalTools[comm]; ok {
+			ctx.IsProgramming = true
+		}
+	}
+
+	// Neovim can say exactly what it has open
+	if cd.neovim && comm == "nvim" {
+		if nvim := neovimProcess(proc.PID); nvim != nil && cd.detectFromNeovim(ctx, nvim) {
+			return
+		}
+	}
+
+	// Files named on the command line are the best hint for the language
+	for _, arg := range proc.Args[min(1, len(proc.Args)):] {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if lang := cd.languageForFile(arg); lang != "" {
+			ctx.FileName = filepath.Base(arg)
+			ctx.FilePath = resolveFile(arg, proc.Cwd)
+			ctx.Language = lang
+			return
+		}
+	}
+
+	// Otherwise an existing file may still say what it is inside
+	for _, arg := range proc.Args[min(1, len(proc.Args)):] {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if path := resolveFile(arg, proc.Cwd); path != "" {
+			ctx.FileName = filepath.Base(path)
+			ctx.FilePath = path
+			if lang := cd.languageForContent(path); lang != "" {
+				ctx.Language = lang
+				return
+			}
+			break
+		}
+	}
+}
+
+// detectFromNeovim fills in the file, language and directory from a
+// running Neovim, reporting whether it answered.
+func (cd *ContextDetector) detectFromNeovim(ctx *Context, proc *procInfo) bool {
+	state, err := queryNeovim(proc)
+	if err != nil {
+		return false
+	}
+
+	if state.Cwd != "" {
+		ctx.ProjectPath = state.Cwd
+	}
+	// Help, terminal and plugin buffers aren't files being worked on
+	if state.BufType == "" && state.File != "" {
+		ctx.FileName = filepath.Base(state.File)
+		ctx.FilePath = state.File
+	}
+	if state.BufType == "" {
+		ctx.Language = cd.languageByName(state.FileType)
+	}
+	return true
+}
+
+// detectFromHeartbeat takes the file, language and project from an editor
+// plugin's heartbeat.
+func (cd *ContextDetector) detectFromHeartbeat(ctx *Context, hb *Heartbeat) {
+	if hb.Type == "" || hb.Type == "file" {
+		ctx.FileName = filepath.Base(hb.Entity)
+		if filepath.IsAbs(hb.Entity) {
+			ctx.FilePath = hb.Entity
+			ctx.ProjectPath = filepath.Dir(hb.Entity)
+		}
+	}
+	if lang := cd.languageByName(hb.Language); lang != "" {
+		ctx.Language = lang
+	}
+	if hb.Project != "" {
+		ctx.ProjectName = hb.Project
+	}
+	ctx.GitBranch = hb.Branch
+	ctx.Lines = hb.Lines
+}
+
+// programForEditor maps an editor named in a heartbeat to the program
+// its windows are detected as.
+func (cd *ContextDetector) programForEditor(editor string) string {
+	if program, ok := heartbeatEditors[editor]; ok {
+		return program
+	}
+	if rule := cd.matchProgram(editor, editor, editor); rule != nil {
+		return rule.Name
+	}
+	return editor
+}
+
+// languageForFile maps a file name to a language by its extension, or by
+// the whole name for files like "Makefile". Variants such as
+// "Dockerfile.dev" count as the file they're named after.
+func (cd *ContextDetector) languageForFile(name string) string {
+	base := filepath.Base(name)
+	if lang := cd.extLanguages[strings.ToLower(filepath.Ext(base))]; lang != "" {
+		return lang
+	}
+	if lang := cd.nameLanguages[base]; lang != "" {
+		return lang
+	}
+	if stem, _, ok := strings.Cut(base, "."); ok && stem != "" {
+		return cd.nameLanguages[stem]
+	}
+	return ""
+}
+
+// Characters that separate file names from the rest of a window title, as
+// in "● main.go - app - Visual Studio Code" or "[+] lib.rs:42 (~/src) - NVIM"
+func isTitleSeparator(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`()[]{}<>"'`+"`"+`,;:|*●•·—–`, r)
+}
+
+// fileNameFromTitle picks the first token of the title that ends in a
+// known extension, so "foo.json" is never mistaken for ".js". Names like
+// "BUILD" or "Makefile" are ordinary words too ("BUILD FAILED - Jenkins"),
+// so they only count in an editor or terminal, or when the file exists.
+func (cd *ContextDetector) fileNameFromTitle(title, projectPath string, editor bool) string {
+	for _, token := range strings.FieldsFunc(title, isTitleSeparator) {
+		if cd.languageForFile(token) == "" {
+			continue
+		}
+		byName := cd.extLanguages[strings.ToLower(filepath.Ext(filepath.Base(token)))] == ""
+		if byName && !editor && resolveFile(token, projectPath) == "" {
+			continue
+		}
+		return filepath.Base(token)
+	}
+	return ""
+}
+
+// filePathFromTitle looks for a title token naming a file that exists,
+// either absolute or relative to the project.
+func (cd *ContextDetector) filePathFromTitle(title, projectPath string) string {
+	for _, token := range strings.FieldsFunc(title, isTitleSeparator) {
+		if path := resolveFile(token, projectPath); path != "" {
+			return path
+		}
+	}
+	return ""
+}
+
 func (cd *ContextDetector) extractPathFromTitle(title string) string {
 	// Try to extract file path from common title formats
 	// Examples: "file.py - Editor", "/path/to/file.py", "file.py (Project Name)"
 
+	// Vim's default title puts the directory in parentheses: "file.py (~/src/app) - VIM"
+	if start := strings.Index(title, "("); start >= 0 {
+		if end := strings.Index(title[start:], ")"); end > 0 {
+			dir := title[start+1 : start+end]
+			if rest, ok := strings.CutPrefix(dir, "~"); ok {
+				if home, err := os.UserHomeDir(); err == nil {
+					dir = home + rest
+				}
+			}
+			if filepath.IsAbs(dir) {
+				return filepath.Clean(dir)
+			}
+		}
+	}
+
 	// Look for absolute paths
 	if strings.HasPrefix(title, "/") {
 		parts := strings.Fields(title)
@@ -130,84 +553,73 @@ func (cd *ContextDetector) extractPathFromTitle(title string) string {
 	return ""
 }
 
-func (cd *ContextDetector) detectLanguage(title, projectPath, program string) string {
-	// First, try to detect from file extension in title
-	titleLower := strings.ToLower(title)
-	for lang, exts := range cd.languageExts {
-		for _, ext := range exts {
-			if strings.Contains(titleLower, ext) {
-				return lang
-			}
+// detectLanguage works from the most specific evidence to the least: the
+// file itself, the directory it's in, the repository as a whole and
+// finally the program.
+func (cd *ContextDetector) detectLanguage(ctx *Context) string {
+	// First, try to detect from the name of the file being edited
+	if lang := cd.languageForFile(ctx.FileName); lang != "" {
+		return lang
+	}
+
+	// Then from a shebang or modeline inside it
+	if ctx.FilePath != "" {
+		if lang := cd.languageForContent(ctx.FilePath); lang != "" {
+			return lang
 		}
 	}
 
-	// If we have a project path, try to read IDE workspace files
-	if projectPath != "" {
-		lang := cd.detectFromWorkspace(projectPath)
-		if lang != "" {
+	// A subdirectory of a repository may be its own project ("web/" with
+	// a package.json in a Go repository)
+	if filepath.IsAbs(ctx.ProjectPath) && ctx.ProjectPath != ctx.GitRoot {
+		if lang := cd.detectFromWorkspace(ctx.ProjectPath); lang != "" {
 			return lang
 		}
 	}
 
-	// Try to detect from program name (e.g., "golang" in process name)
-	processLower := strings.ToLower(program)
-	if strings.Contains(processLower, "go") {
-		return "go"
+	// What the repository is mostly written in beats whichever marker file
+	// happens to be checked first
+	if ctx.PrimaryLanguage != "" {
+		return ctx.PrimaryLanguage
+	}
+	// Until the scan is done, project files usually sit at the top
+	if ctx.GitRoot != "" {
+		if lang := cd.detectFromWorkspace(ctx.GitRoot); lang != "" {
+			return lang
+		}
 	}
 
-	return ""
+	// Compilers and REPLs imply their language
+	return terminalTools[strings.ToLower(ctx.Program)]
+}
+
+// workspace reads dir's IDE project files, once per DetectContext call.
+func (cd *ContextDetector) workspace(dir string) WorkspaceInfo {
+	if info, ok := cd.workspaces[dir]; ok {
+		return info
+	}
+	info := readWorkspace(dir)
+	if cd.workspaces != nil {
+		cd.workspaces[dir] = info
+	}
+	return info
 }
 
 func (cd *ContextDetector) detectFromWorkspace(projectPath string) string {
-	// Try to read VSCode workspace settings
-	vsCodePath := filepath.Join(projectPath, ".vscode", "settings.json")
-	if data, err := os.ReadFile(vsCodePath); err == nil {
-		var settings map[string]interface{}
-		if json.Unmarshal(data, &settings) == nil {
-			// Check for language-specific settings
-			if files, ok := settings["files.associations"].(map[string]interface{}); ok {
-				for pattern, lang := range files {
-					langStr := fmt.Sprintf("%v", lang)
-					// Map common language identifiers
-					if strings.Contains(langStr, "go") {
-						return "go"
-					} else if strings.Contains(langStr, "java") {
-						return "java"
-					} else if strings.Contains(langStr, "python") {
-						return "python"
-					} else if strings.Contains(langStr, "javascript") || strings.Contains(langStr, "typescript") {
-						return "javascript"
-					}
-					_ = pattern // Use pattern if needed
-				}
-			}
+	// IDE project files say what the project is, when there are any
+	for _, name := range cd.workspace(projectPath).Languages {
+		if lang := cd.languageByName(name); lang != "" {
+			return lang
 		}
 	}
 
 	// Try to detect from common project files
-	if _, err := os.Stat(filepath.Join(projectPath, "go.mod")); err == nil {
-		return "go"
-	}
-	if _, err := os.Stat(filepath.Join(projectPath, "package.json")); err == nil {
-		return "javascript"
-	}
-	if _, err := os.Stat(filepath.Join(projectPath, "requirements.txt")); err == nil {
-		return "python"
-	}
-	if _, err := os.Stat(filepath.Join(projectPath, "setup.py")); err == nil {
-		return "python"
-	}
-	if _, err := os.Stat(filepath.Join(projectPath, "Pipfile")); err == nil {
-		return "python"
-	}
-	if _, err := os.Stat(filepath.Join(projectPath, "pom.xml")); err == nil {
-		return "java"
-	}
-	if _, err := os.Stat(filepath.Join(projectPath, "build.gradle")); err == nil {
-		return "java"
-	}
-	if _, err := os.Stat(filepath.Join(projectPath, "Cargo.toml")); err == nil {
-		return "rust"
+	for _, rule := range cd.languageRules {
+		for _, marker := range rule.Markers {
+			if _, err := os.Stat(filepath.Join(projectPath, marker)); err == nil {
+				return rule.Name
+			}
+		}
 	}
 
 	return ""
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatchProgram(t *testing.T) {
+	config := DefaultConfig()
+	config.merge(&Config{Programs: []ProgramRuleConfig{
+		{Name: "work-browser", Priority: 10, WMClass: `(?i)^firefox-work$`, Category: "browser"},
+	}})
+	cd := NewContextDetector(config)
+
+	tests := []struct {
+		name                  string
+		title, process, class string
+		want                  string
+	}{
+		{"process beats title", "Chromium — GitHub code review", "chromium", "", "chromium"},
+		{"code in a title is not vscode", "Chromium — GitHub code review", "", "", ""},
+		{"vscode by process", "main.go - myproject - Visual Studio Code", "code", "", "vscode"},
+		{"vscode by title", "main.go - myproject - Visual Studio Code", "", "", "vscode"},
+		{"editor named in a browser tab", "vim tips and tricks - Mozilla Firefox", "firefox", "", "firefox"},
+		{"editor named in a browser tab by class", "vim tips and tricks - Mozilla Firefox", "", "firefox", "firefox"},
+		{"class beats title", "Sublime Text vs VS Code - Google Chrome", "", "google-chrome", "chrome"},
+		{"terminal falls back to title", "~/src/app - NVIM", "alacritty", "Alacritty", "vim"},
+		{"versioned emacs", "*scratch* - GNU Emacs", "emacs-29.1", "", "emacs"},
+		{"class prefix", "Project – Main.java", "", "jetbrains-idea-ce", "idea"},
+		{"title word boundary", "Invimble - Document Viewer", "", "", ""},
+		{"higher priority first", "Inbox - Mozilla Firefox", "", "firefox-work", "work-browser"},
+		{"lower priority still matches", "Inbox - Mozilla Firefox", "", "firefox", "firefox"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The same window must always give the same answer
+			for i := 0; i < 3; i++ {
+				got := ""
+				if rule := cd.matchProgram(strings.ToLower(tt.title), strings.ToLower(tt.process), strings.ToLower(tt.class)); rule != nil {
+					got = rule.Name
+				}
+				if got != tt.want {
+					t.Fatalf("matchProgram(%q, %q, %q) = %q, want %q", tt.title, tt.process, tt.class, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFileNameFromTitle(t *testing.T) {
+	cd := NewContextDetector(DefaultConfig())
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, "BUILD"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		title   string
+		project string
+		editor  bool
+		want    string
+	}{
+		{"● main.go - app - Visual Studio Code", "", false, "main.go"},
+		{"config.json - notes", "", false, "config.json"},
+		{"BUILD FAILED - Jenkins", "", false, ""},
+		{"Makefile tips - Stack Overflow", "", false, ""},
+		{"Makefile - ~/src/app - NVIM", "", true, "Makefile"},
+		{"Dockerfile.dev (~/src/app) - VIM", "", true, "Dockerfile.dev"},
+		{"BUILD - Bazel project", project, false, "BUILD"},
+	}
+	for _, tt := range tests {
+		if got := cd.fileNameFromTitle(tt.title, tt.project, tt.editor); got != tt.want {
+			t.Errorf("fileNameFromTitle(%q, editor=%v) = %q, want %q", tt.title, tt.editor, got, tt.want)
+		}
+	}
+}
+
+func TestDetectSite(t *testing.T) {
+	cd := NewContextDetector(DefaultConfig())
+
+	tests := []struct {
+		name     string
+		title    string
+		site     string
+		category string
+		project  string
+	}{
+		{"pull request", "Fix the parser · Pull Request #12 · owner/repo · GitHub - Mozilla Firefox", "GitHub", SiteCodeReview, "owner/repo"},
+		{"pull request with its url", "github.com/owner/repo/pull/12 · Pull Request #12 · owner/repo - Mozilla Firefox", "GitHub", SiteCodeReview, "owner/repo"},
+		{"issue", "Crash on start · Issue #7 · owner/repo · GitHub", "GitHub", SiteIssueTracker, "owner/repo"},
+		{"repository", "GitHub - owner/repo: A tool", "GitHub", SiteCodeHosting, "owner/repo"},
+		{"domain only", "github.com/owner/repo", "GitHub", SiteCodeHosting, ""},
+		{"subdomain", "team.atlassian.net/browse/ABC-1", "Jira", SiteIssueTracker, ""},
+		{"unknown site", "Some article - Example Blog - Google Chrome", "Example Blog", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &Context{}
+			cd.detectSite(ctx, tt.title)
+			if ctx.Site != tt.site || ctx.SiteCategory != tt.category || ctx.ProjectName != tt.project {
+				t.Errorf("detectSite(%q) = %q, %q, %q; want %q, %q, %q", tt.title,
+					ctx.Site, ctx.SiteCategory, ctx.ProjectName, tt.site, tt.category, tt.project)
+			}
+		})
+	}
+}
+
+func TestHeaderLanguage(t *testing.T) {
+	cd := NewContextDetector(DefaultConfig())
+	project := func(file, content string) string {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	cmakeC := project("CMakeLists.txt", "cmake_minimum_required(VERSION 3.16)\nproject(tool VERSION 1.0 LANGUAGES C)\n")
+	cmakeCXX := project("CMakeLists.txt", "project(app CXX)\n")
+	cmakeDefault := project("CMakeLists.txt", "project(app)\n")
+	cmakeEnabled := project("CMakeLists.txt", "project(app LANGUAGES NONE)\nenable_language(CXX)\n")
+	mesonC := project("meson.build", "project('tool', 'c', version: '1.0')\n")
+	mesonCPP := project("meson.build", "project('app', ['cpp'],\n  default_options: ['c_std=c11'])\n")
+
+	tests := []struct {
+		name string
+		ctx  Context
+		want string
+	}{
+		{"no project", Context{FileName: "util.h", Language: "c"}, "c"},
+		{"c project", Context{FileName: "util.h", Language: "c", PrimaryLanguage: "c", SecondaryLanguages: []string{"cpp"}}, "c"},
+		{"c++ project", Context{FileName: "util.h", Language: "c", PrimaryLanguage: "cpp"}, "cpp"},
+		{"c++ ahead of c", Context{FileName: "util.h", Language: "c", PrimaryLanguage: "python", SecondaryLanguages: []string{"cpp", "c"}}, "cpp"},
+		{"editor said c++", Context{FileName: "util.h", Language: "cpp", PrimaryLanguage: "c"}, "c"},
+		{"c with cmake", Context{FileName: "util.h", Language: "c", ProjectPath: cmakeC}, "c"},
+		{"editor said c++ in c with cmake", Context{FileName: "util.h", Language: "cpp", ProjectPath: cmakeC}, "c"},
+		{"c++ with cmake", Context{FileName: "util.h", Language: "c", ProjectPath: cmakeCXX}, "cpp"},
+		{"cmake's default languages", Context{FileName: "util.h", Language: "c", ProjectPath: cmakeDefault}, "c"},
+		{"cmake enable_language", Context{FileName: "util.h", Language: "c", ProjectPath: cmakeEnabled}, "cpp"},
+		{"c with meson", Context{FileName: "util.h", Language: "cpp", GitRoot: mesonC}, "c"},
+		{"c++ with meson", Context{FileName: "util.h", Language: "c", GitRoot: mesonCPP}, "cpp"},
+		{"not a header", Context{FileName: "main.c", Language: "c", PrimaryLanguage: "cpp"}, "c"},
+		{"set by modeline", Context{FileName: "util.h", Language: "objc", PrimaryLanguage: "cpp"}, "objc"},
+	}
+	for _, tt := range tests {
+		if got := cd.languageInProject(&tt.ctx); got != tt.want {
+			t.Errorf("%s: languageInProject() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Headers don't count toward either language in a scan
+	for name, content := range map[string]string{"main.cpp": strings.Repeat("int f();\n", 20), "util.h": strings.Repeat("int x;\n", 100)} {
+		if err := os.WriteFile(filepath.Join(cmakeCXX, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if languages, _ := cd.scanner.scan(cmakeCXX); len(languages) == 0 || languages[0].Language != "cpp" {
+		t.Errorf("scan found %+v, want cpp first", languages)
+	}
+}
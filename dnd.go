@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// How long a do-not-disturb answer is reused, since asking may mean
+	// running a program
+	dndCheckInterval = 10 * time.Second
+	dndQueryTimeout  = time.Second
+)
+
+// Mako has no do-not-disturb of its own; these are the mode names people
+// set up for it
+var makoDNDModes = []string{"do-not-disturb", "dnd"}
+
+// DNDChecker is implemented by notifiers whose notifications the desktop
+// can silence.
+type DNDChecker interface {
+	DoNotDisturb() bool
+}
+
+// notificationServer is who is showing our notifications and what it can
+// do, as GetServerInformation and GetCapabilities report.
+type notificationServer struct {
+	name         string
+	capabilities map[string]bool
+}
+
+// server asks the notification server about itself, once it's there to ask.
+func (n *DBusNotifier) server() *notificationServer {
+	if n.serverInfo != nil || n.conn == nil {
+		return n.serverInfo
+	}
+
+	obj := n.conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+	var name, vendor, version, specVersion string
+	if err := obj.Call("org.freedesktop.Notifications.GetServerInformation", 0).Store(&name, &vendor, &version, &specVersion); err != nil {
+		return nil
+	}
+	var capabilities []string
+	if err := obj.Call("org.freedesktop.Notifications.GetCapabilities", 0).Store(&capabilities); err != nil {
+		return nil
+	}
+
+	server := &notificationServer{name: name, capabilities: make(map[string]bool)}
+	for _, capability := range capabilities {
+		server.capabilities[capability] = true
+	}
+	log.Printf("Notification server: %s %s (%s)", name, version, strings.Join(capabilities, ", "))
+	n.serverInfo = server
+	return server
+}
+
+// supports reports whether the server has a capability, assuming it does
+// when the server can't be asked.
+func (n *DBusNotifier) supports(capability string) bool {
+	server := n.server()
+	return server == nil || server.capabilities[capability]
+}
+
+// DoNotDisturb reports whether the notification server is holding back
+// popups: KDE's Inhibited property, or asking dunst, mako, SwayNC or GNOME.
+func (n *DBusNotifier) DoNotDisturb() bool {
+	now := time.Now()
+	if !n.dndChecked.IsZero() && now.Sub(n.dndChecked) < dndCheckInterval {
+		return n.dnd
+	}
+	n.dndChecked = now
+
+	dnd := n.queryDoNotDisturb()
+	if dnd != n.dnd {
+		if dnd {
+			log.Printf("Do not disturb is on, holding notifications")
+		} else {
+			log.Printf("Do not disturb is off")
+		}
+	}
+	n.dnd = dnd
+	return dnd
+}
+
+func (n *DBusNotifier) queryDoNotDisturb() bool {
+	server := n.server()
+	if server == nil {
+		return false
+	}
+
+	obj := n.conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+	if inhibited, err := obj.GetProperty("org.freedesktop.Notifications.Inhibited"); err == nil {
+		if on, ok := inhibited.Value().(bool); ok && on {
+			return true
+		}
+	}
+
+	switch strings.ToLower(server.name) {
+	case "dunst":
+		output, err := dndCommand("dunstctl", "is-paused")
+		return err == nil && output == "true"
+	case "mako":
+		// One active mode per line
+		output, err := dndCommand("makoctl", "mode")
+		if err != nil {
+			return false
+		}
+		for _, mode := range strings.Fields(output) {
+			for _, dndMode := range makoDNDModes {
+				if mode == dndMode {
+					return true
+				}
+			}
+		}
+		return false
+	case "swaync":
+		output, err := dndCommand("swaync-client", "--get-dnd", "--skip-wait")
+		return err == nil && output == "true"
+	case "gnome shell", "gnome-shell":
+		output, err := dndCommand("gsettings", "get", "org.gnome.desktop.notifications", "show-banners")
+		return err == nil && output == "false"
+	}
+	return false
+}
+
+func dndCommand(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dndQueryTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+	return string(bytes.TrimSpace(output)), err
+}
+
+// DoNotDisturb is on if any notifier that can tell says so.
+func (n *MultiNotifier) DoNotDisturb() bool {
+	for _, notifier := range n.notifiers {
+		if checker, ok := notifier.(DNDChecker); ok && checker.DoNotDisturb() {
+			return true
+		}
+	}
+	return false
+}
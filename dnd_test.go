@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/godbus/dbus/v5/prop"
+)
+
+func TestQueryDoNotDisturb(t *testing.T) {
+	tests := []struct {
+		name      string
+		server    string
+		inhibited *bool  // KDE's property, absent when nil
+		command   string // What the server is asked with
+		output    string
+		fails     bool
+		want      bool
+	}{
+		{"kde inhibited", "Plasma", boolPtr(true), "", "", false, true},
+		{"kde not inhibited", "Plasma", boolPtr(false), "", "", false, false},
+		{"dunst paused", "dunst", nil, "dunstctl", "true", false, true},
+		{"dunst running", "dunst", nil, "dunstctl", "false", false, false},
+		{"dunst failing", "dunst", nil, "dunstctl", "true", true, false},
+		{"mako dnd mode", "mako", nil, "makoctl", "default\ndo-not-disturb", false, true},
+		{"mako short mode", "mako", nil, "makoctl", "dnd", false, true},
+		{"mako other mode", "mako", nil, "makoctl", "default\ndnd-later", false, false},
+		{"swaync dnd", "swaync", nil, "swaync-client", "true", false, true},
+		{"swaync off", "swaync", nil, "swaync-client", "false", false, false},
+		{"gnome banners off", "GNOME Shell", nil, "gsettings", "false", false, true},
+		{"gnome banners on", "gnome-shell", nil, "gsettings", "true", false, false},
+		// Inhibited wins whatever the server is called
+		{"inhibited on dunst", "dunst", boolPtr(true), "dunstctl", "false", false, true},
+		{"unknown server", "xfce4-notifyd", nil, "", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier, server := newTestDBusNotifier(t)
+			server.name = tt.server
+			if tt.inhibited != nil {
+				_, err := prop.Export(server.conn, "/org/freedesktop/Notifications", prop.Map{
+					"org.freedesktop.Notifications": {"Inhibited": {Value: *tt.inhibited}},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.command != "" {
+				script := "printf '%s\\n' '" + tt.output + "'\n"
+				if tt.fails {
+					script += "exit 1\n"
+				}
+				fakeCommand(t, tt.command, script)
+			}
+
+			if got := notifier.queryDoNotDisturb(); got != tt.want {
+				t.Errorf("queryDoNotDisturb() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// How much of each end of a file is searched for shebangs and modelines.
+// Vim looks at the first and last five lines by default.
+const (
+	fileTypeScanBytes = 4096
+	fileTypeScanLines = 5
+	fileTypeCacheSize = 1024
+)
+
+var (
+	// "vim: set ft=python:", "vi: filetype=sh", "ex: syntax=ruby"
+	vimModeline = regexp.MustCompile(`\b(?:vim?|ex):.*?\b(?:ft|filetype|syntax)=([\w+#-]+)`)
+	// "-*- mode: python -*-" or just "-*- python -*-"
+	emacsModeline = regexp.MustCompile(`-\*-\s*(?:.*?\bmode:\s*)?([\w+#-]+)\s*;?.*?-\*-`)
+)
+
+// Names people give languages in modelines, shebangs and editor settings
+// that differ from ours
+var languageAliases = map[string]string{
+	"sh":              "shell",
+	"bash":            "shell",
+	"zsh":             "shell",
+	"ksh":             "shell",
+	"dash":            "shell",
+	"fish":            "shell",
+	"shell-script":    "shell",
+	"shellscript":     "shell",
+	"py":              "python",
+	"js":              "javascript",
+	"javascriptreact": "javascript",
+	"ts":              "typescript",
+	"typescriptreact": "typescript",
+	"rb":              "ruby",
+	"c++":             "cpp",
+	"c#":              "csharp",
+	"shell script":    "shell",
+	"makefile":        "make",
+	"cs":              "csharp",
+	"rs":              "rust",
+	"golang":          "go",
+	"jsonc":           "json",
+	"scss":            "css",
+	"less":            "css",
+	"dockerfile":      "docker",
+	"bzl":             "starlark",
+	"runhaskell":      "haskell",
+	"runghc":          "haskell",
+	"escript":         "erlang",
+	"deno":            "typescript",
+	"ts-node":         "typescript",
+	"tsx":             "typescript",
+	"node":            "javascript",
+	"bun":             "javascript",
+	"elixir":          "elixir",
+	"php-cgi":         "php",
+}
+
+type fileTypeCacheEntry struct {
+	modTime  time.Time
+	size     int64
+	language string
+}
+
+// languageByName resolves a language name or alias to one of our rules.
+func (cd *ContextDetector) languageByName(name string) string {
+	name = strings.ToLower(name)
+	if alias, ok := languageAliases[name]; ok {
+		name = alias
+	}
+	for _, rule := range cd.languageRules {
+		if rule.Name == name {
+			return rule.Name
+		}
+	}
+	return ""
+}
+
+var (
+	// project(app LANGUAGES C), project(app CXX), enable_language(CXX)
+	cmakeLanguageCall = regexp.MustCompile(`(?i)\b(project|enable_language)\s*\(([^)]*)\)`)
+	// project('app', 'c'), project('app', ['c', 'cpp'], version: '1.0'),
+	// add_languages('cpp')
+	mesonLanguageCall    = regexp.MustCompile(`\b(project|add_languages)\s*\(([^)]*)\)`)
+	mesonKeywordArgument = regexp.MustCompile(`\b\w+\s*:`)
+	mesonString          = regexp.MustCompile(`'([^']*)'`)
+)
+
+// Build systems' names for the languages they compile
+var (
+	cmakeLanguages = map[string]string{"C": "c", "CXX": "cpp"}
+	mesonLanguages = map[string]string{"c": "c", "cpp": "cpp"}
+)
+
+var cmakeProjectKeywords = map[string]bool{
+	"LANGUAGES": true, "VERSION": true, "DESCRIPTION": true, "HOMEPAGE_URL": true,
+}
+
+// Extensions more than one language uses, which only the project can
+// decide between. Languages rules map them to are the default.
+var sharedExtensions = map[string]map[string]bool{
+	".h": {"c": true, "cpp": true},
+}
+
+// languageInProject settles the language of a file with a shared extension,
+// like a .h header in a C or C++ project, by which of the candidates the
+// project has more of or, until it's been scanned, by their marker files.
+func (cd *ContextDetector) languageInProject(ctx *Context) string {
+	candidates := sharedExtensions[strings.ToLower(filepath.Ext(ctx.FileName))]
+	if !candidates[ctx.Language] {
+		return ctx.Language
+	}
+	for _, lang := range append([]string{ctx.PrimaryLanguage}, ctx.SecondaryLanguages...) {
+		if candidates[lang] {
+			return lang
+		}
+	}
+
+	// Having a CMakeLists.txt or meson.build says nothing about C versus
+	// C++, but the languages they enable do
+	for _, dir := range []string{ctx.ProjectPath, ctx.GitRoot} {
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		var declared []string
+		for lang := range buildLanguages(dir) {
+			if candidates[lang] {
+				declared = append(declared, lang)
+			}
+		}
+		if len(declared) == 1 {
+			return declared[0]
+		}
+	}
+	return ctx.Language
+}
+
+// buildLanguages lists the languages a CMake or meson project enables in
+// project(), enable_language() or add_languages(). CMake enables C and C++
+// when project() names none.
+func buildLanguages(dir string) map[string]bool {
+	languages := make(map[string]bool)
+
+	if data, err := os.ReadFile(filepath.Join(dir, "CMakeLists.txt")); err == nil {
+		for _, call := range cmakeLanguageCall.FindAllStringSubmatch(string(data), -1) {
+			args := strings.Fields(call[2])
+			if strings.EqualFold(call[1], "project") {
+				args = cmakeProjectLanguages(args)
+			}
+			for _, arg := range args {
+				if lang := cmakeLanguages[strings.ToUpper(strings.Trim(arg, `"`))]; lang != "" {
+					languages[lang] = true
+				}
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "meson.build")); err == nil {
+		for _, call := range mesonLanguageCall.FindAllStringSubmatch(string(data), -1) {
+			// Languages come before the keyword arguments
+			args := call[2]
+			if loc := mesonKeywordArgument.FindStringIndex(args); loc != nil {
+				args = args[:loc[0]]
+			}
+			names := mesonString.FindAllStringSubmatch(args, -1)
+			if call[1] == "project" && len(names) > 0 {
+				names = names[1:]
+			}
+			for _, name := range names {
+				if lang := mesonLanguages[strings.ToLower(name[1])]; lang != "" {
+					languages[lang] = true
+				}
+			}
+		}
+	}
+	return languages
+}
+
+// cmakeProjectLanguages picks the languages out of project()'s arguments:
+// those after LANGUAGES, or straight after the name in the short form.
+// Without either CMake's defaults apply.
+func cmakeProjectLanguages(args []string) []string {
+	defaults := []string{"C", "CXX"}
+	if len(args) < 2 {
+		return defaults
+	}
+	args = args[1:]
+	if !cmakeProjectKeywords[strings.ToUpper(args[0])] {
+		return args
+	}
+	for i, arg := range args {
+		if strings.ToUpper(arg) != "LANGUAGES" {
+			continue
+		}
+		var languages []string
+		for _, lang := range args[i+1:] {
+			if cmakeProjectKeywords[strings.ToUpper(lang)] {
+				break
+			}
+			languages = append(languages, lang)
+		}
+		return languages
+	}
+	return defaults
+}
+
+// languageForContent sniffs a file's language from a shebang or a modeline.
+// Results are cached by path until the file's size or mtime changes, so an
+// open file isn't reread on every check.
+func (cd *ContextDetector) languageForContent(path string) string {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+	if entry, ok := cd.fileTypeCache[path]; ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.language
+	}
+
+	language := cd.sniffLanguage(path, info.Size())
+
+	if len(cd.fileTypeCache) >= fileTypeCacheSize {
+		cd.fileTypeCache = make(map[string]fileTypeCacheEntry)
+	}
+	cd.fileTypeCache[path] = fileTypeCacheEntry{
+		modTime:  info.ModTime(),
+		size:     info.Size(),
+		language: language,
+	}
+	return language
+}
+
+func (cd *ContextDetector) sniffLanguage(path string, size int64) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	head := make([]byte, fileTypeScanBytes)
+	n, _ := io.ReadFull(file, head)
+	head = head[:n]
+	if bytes.IndexByte(head, 0) >= 0 {
+		// Binary file
+		return ""
+	}
+
+	headLines := strings.Split(strings.TrimRight(string(head), "\n"), "\n")
+	if strings.HasPrefix(headLines[0], "#!") {
+		if lang := cd.languageForShebang(headLines[0]); lang != "" {
+			return lang
+		}
+	}
+
+	// Modelines may sit at either end of the file
+	lines := headLines[:min(fileTypeScanLines, len(headLines))]
+	tailLines := headLines[len(lines):]
+	if size > fileTypeScanBytes {
+		tail := make([]byte, fileTypeScanBytes)
+		if n, err := file.ReadAt(tail, size-fileTypeScanBytes); err == nil || err == io.EOF {
+			tailLines = strings.Split(strings.TrimRight(string(tail[:n]), "\n"), "\n")
+		}
+	}
+	lines = append(lines, tailLines[max(0, len(tailLines)-fileTypeScanLines):]...)
+
+	for _, line := range lines {
+		for _, modeline := range []*regexp.Regexp{vimModeline, emacsModeline} {
+			if match := modeline.FindStringSubmatch(line); match != nil {
+				if lang := cd.languageByName(match[1]); lang != "" {
+					return lang
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// languageForShebang reads the interpreter from a "#!" line, looking past
+// env and its flags, and drops version suffixes ("python3.12").
+func (cd *ContextDetector) languageForShebang(line string) string {
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "-") || strings.Contains(field, "=") {
+				continue
+			}
+			interpreter = filepath.Base(field)
+			break
+		}
+	}
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+
+	for _, rule := range cd.languageRules {
+		for _, name := range rule.Interpreters {
+			if name == interpreter {
+				return rule.Name
+			}
+		}
+	}
+	return cd.languageByName(interpreter)
+}
+
+// resolveFile finds the file being edited on disk, trying a name from the
+// title or command line relative to the directory it was found in.
+func resolveFile(name, dir string) string {
+	if name == "" {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(name, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			name = filepath.Join(home, rest)
+		}
+	}
+	if !filepath.IsAbs(name) {
+		if dir == "" || !filepath.IsAbs(dir) {
+			return ""
+		}
+		name = filepath.Join(dir, name)
+	}
+	if info, err := os.Stat(name); err == nil && info.Mode().IsRegular() {
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLanguageForShebang(t *testing.T) {
+	cd := NewContextDetector(DefaultConfig())
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"#!/bin/sh", "shell"},
+		{"#!/bin/bash -e", "shell"},
+		{"#! /usr/bin/python3", "python"},
+		{"#!/usr/bin/python3.12", "python"},
+		{"#!/usr/bin/env python3", "python"},
+		{"#!/usr/bin/env -S python3 -u", "python"},
+		{"#!/usr/bin/env -i PATH=/usr/bin node", "javascript"},
+		{"#!/usr/bin/env -S deno run --allow-net", "typescript"},
+		{"#!/usr/local/bin/ruby", "ruby"},
+		{"#!/usr/bin/env runghc", "haskell"},
+		{"#!/usr/bin/env", ""},
+		{"#!", ""},
+		{"#!/usr/bin/frobnicate", ""},
+	}
+	for _, tt := range tests {
+		if got := cd.languageForShebang(tt.line); got != tt.want {
+			t.Errorf("languageForShebang(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSniffLanguage(t *testing.T) {
+	lines := func(n int) string { return strings.Repeat("x = 1\n", n) }
+	padding := strings.Repeat("# padding\n", fileTypeScanBytes/10+1)
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"shebang", "#!/usr/bin/env python3\nprint('hi')\n", "python"},
+		{"shebang over modeline", "#!/bin/sh\n# vim: ft=ruby\n", "shell"},
+		{"unknown shebang, modeline", "#!/opt/thing\n# vim: ft=ruby\n", "ruby"},
+		{"vim modeline", "# vim: set ft=python:\n" + lines(3), "python"},
+		{"vi modeline", lines(2) + "# vi: filetype=sh\n", "shell"},
+		{"syntax modeline", "// ex: syntax=javascriptreact\n", "javascript"},
+		{"emacs modeline", "# -*- mode: sh -*-\n" + lines(3), "shell"},
+		{"short emacs modeline", ";; -*- python -*-\n", "python"},
+		{"emacs modeline with variables", "# -*- mode: ruby; coding: utf-8 -*-\n", "ruby"},
+		{"modeline at the end", lines(20) + "# vim: ft=go\n", "go"},
+		{"modeline past the first lines", lines(5) + "# vim: ft=go\n" + lines(5), ""},
+		{"modeline at the end of a large file", padding + lines(2) + "# vim: ft=rust\n", "rust"},
+		{"modeline at the start of a large file", "# vim: ft=ruby\n" + padding, "ruby"},
+		{"unknown filetype", "# vim: ft=frobnicate\n", ""},
+		{"no hints", lines(3), ""},
+		{"binary", "#!/bin/sh\x00\x01", ""},
+		{"empty", "", ""},
+	}
+	cd := NewContextDetector(DefaultConfig())
+	dir := t.TempDir()
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strconv.Itoa(i))
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got := cd.sniffLanguage(path, int64(len(tt.content))); got != tt.want {
+				t.Errorf("sniffLanguage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLanguageForContentCache(t *testing.T) {
+	cd := NewContextDetector(DefaultConfig())
+	path := filepath.Join(t.TempDir(), "build")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := cd.languageForContent(path); got != "shell" {
+		t.Fatalf("languageForContent() = %q, want shell", got)
+	}
+
+	// A changed file is sniffed again
+	if err := os.WriteFile(path, []byte("#!/usr/bin/env python3\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got := cd.languageForContent(path); got != "python" {
+		t.Errorf("languageForContent() after a change = %q, want python", got)
+	}
+
+	if got := cd.languageForContent(filepath.Dir(path)); got != "" {
+		t.Errorf("languageForContent() of a directory = %q", got)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GitInfo describes the repository enclosing a path.
+type GitInfo struct {
+	Root      string
+	Name      string
+	Branch    string // Short commit hash when HEAD is detached
+	Remote    string // Name of the remote the branch tracks, or origin
+	RemoteURL string
+}
+
+// findGitRepo walks up from path to the nearest repository, reading HEAD
+// and the config directly so no git binary is needed.
+func findGitRepo(path string) *GitInfo {
+	if path == "" || !filepath.IsAbs(path) {
+		return nil
+	}
+
+	dir := filepath.Clean(path)
+	for {
+		if gitDir := resolveGitDir(dir); gitDir != "" {
+			return readGitInfo(dir, gitDir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// resolveGitDir returns the git directory for a worktree root candidate.
+// Worktrees and submodules have a .git file pointing elsewhere.
+func resolveGitDir(dir string) string {
+	dotGit := filepath.Join(dir, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return ""
+	}
+	if info.IsDir() {
+		return dotGit
+	}
+
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return ""
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return ""
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+	return gitDir
+}
+
+func readGitInfo(root, gitDir string) *GitInfo {
+	info := &GitInfo{Root: root}
+
+	if data, err := os.ReadFile(filepath.Join(gitDir, "HEAD")); err == nil {
+		head := strings.TrimSpace(string(data))
+		if ref, ok := strings.CutPrefix(head, "ref:"); ok {
+			info.Branch = strings.TrimPrefix(strings.TrimSpace(ref), "refs/heads/")
+		} else if len(head) >= 7 {
+			info.Branch = head[:7]
+		}
+	}
+
+	// Linked worktrees keep their config in the main repository
+	commonDir := gitDir
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+
+	remotes, branchRemotes := readGitConfig(filepath.Join(commonDir, "config"))
+	if remote, ok := branchRemotes[info.Branch]; ok {
+		info.Remote = remote
+	} else if _, ok := remotes["origin"]; ok {
+		info.Remote = "origin"
+	} else {
+		for name := range remotes {
+			if info.Remote == "" || name < info.Remote {
+				info.Remote = name
+			}
+		}
+	}
+	info.RemoteURL = remotes[info.Remote]
+
+	// The remote's repository name survives renamed or oddly named checkouts
+	info.Name = filepath.Base(root)
+	if info.RemoteURL != "" {
+		name := strings.TrimSuffix(strings.TrimRight(info.RemoteURL, "/"), ".git")
+		if idx := strings.LastIndexAny(name, "/:"); idx >= 0 {
+			name = name[idx+1:]
+		}
+		if name != "" {
+			info.Name = name
+		}
+	}
+
+	return info
+}
+
+// readGitConfig extracts remote URLs and the remote each branch tracks.
+func readGitConfig(path string) (map[string]string, map[string]string) {
+	remotes := make(map[string]string)
+	branchRemotes := make(map[string]string)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return remotes, branchRemotes
+	}
+	defer file.Close()
+
+	section, subsection := "", ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		// Section headers look like [remote "origin"]
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			header := strings.TrimSpace(line[1 : len(line)-1])
+			section, subsection = header, ""
+			if idx := strings.Index(header, " "); idx >= 0 {
+				section = header[:idx]
+				subsection = strings.Trim(strings.TrimSpace(header[idx+1:]), `"`)
+			}
+			section = strings.ToLower(section)
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+
+		switch {
+		case section == "remote" && key == "url":
+			remotes[subsection] = value
+		case section == "branch" && key == "remote":
+			branchRemotes[subsection] = value
+		}
+	}
+	return remotes, branchRemotes
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates the given files under root, with their directories.
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadGitConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	writeFiles(t, filepath.Dir(path), map[string]string{"config": `[core]
+	bare = false
+; a comment
+# another
+[remote "origin"]
+	url = git@github.com:wolandark/emotional-support.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+[Remote "upstream"]
+	URL = "https://example.com/upstream/repo"
+[branch "main"]
+	remote = origin
+[branch "feature/x"]
+	remote = upstream
+	merge = refs/heads/x
+[url "https://example.com/"]
+	insteadOf = gh:
+`})
+
+	remotes, branchRemotes := readGitConfig(path)
+	wantRemotes := map[string]string{
+		"origin":   "git@github.com:wolandark/emotional-support.git",
+		"upstream": "https://example.com/upstream/repo",
+	}
+	if !reflect.DeepEqual(remotes, wantRemotes) {
+		t.Errorf("remotes = %v, want %v", remotes, wantRemotes)
+	}
+	wantBranches := map[string]string{"main": "origin", "feature/x": "upstream"}
+	if !reflect.DeepEqual(branchRemotes, wantBranches) {
+		t.Errorf("branch remotes = %v, want %v", branchRemotes, wantBranches)
+	}
+
+	remotes, branchRemotes = readGitConfig(filepath.Join(t.TempDir(), "missing"))
+	if len(remotes) != 0 || len(branchRemotes) != 0 {
+		t.Errorf("readGitConfig() of a missing file = %v, %v", remotes, branchRemotes)
+	}
+}
+
+func TestFindGitRepo(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		// A branch tracking a remote other than origin
+		"app/.git/HEAD":   "ref: refs/heads/feature/x\n",
+		"app/.git/config": "[remote \"origin\"]\n\turl = https://example.com/me/app.git\n[remote \"fork\"]\n\turl = git@example.com:me/app-fork.git\n[branch \"feature/x\"]\n\tremote = fork\n",
+		"app/src/main.go": "package main\n",
+
+		// Detached, with only a remote that isn't origin
+		"detached/.git/HEAD":   "0123456789abcdef0123456789abcdef01234567\n",
+		"detached/.git/config": "[remote \"zeta\"]\n\turl = https://example.com/z/zeta/\n[remote \"alpha\"]\n\turl = https://example.com/a/alpha\n",
+
+		// No remotes, so the directory names it
+		"local/.git/HEAD": "ref: refs/heads/main\n",
+
+		// A linked worktree sharing the main repository's config
+		"main/.git/HEAD":                   "ref: refs/heads/main\n",
+		"main/.git/config":                 "[remote \"origin\"]\n\turl = https://example.com/me/main-repo.git\n",
+		"main/.git/worktrees/wt/HEAD":      "ref: refs/heads/topic\n",
+		"main/.git/worktrees/wt/commondir": "../..\n",
+		"wt/.git":                          "gitdir: ../main/.git/worktrees/wt\n",
+		"wt/docs/README":                   "",
+		"broken/.git":                      "not a gitdir\n",
+		"broken/nested/.keep":              "",
+		"outside/.keep":                    "",
+	})
+
+	tests := []struct {
+		name string
+		path string
+		want *GitInfo
+	}{
+		{"nested path", "app/src", &GitInfo{Root: "app", Name: "app-fork", Branch: "feature/x", Remote: "fork", RemoteURL: "git@example.com:me/app-fork.git"}},
+		{"detached", "detached", &GitInfo{Root: "detached", Name: "alpha", Branch: "0123456", Remote: "alpha", RemoteURL: "https://example.com/a/alpha"}},
+		{"no remote", "local", &GitInfo{Root: "local", Name: "local", Branch: "main"}},
+		{"worktree", "wt/docs", &GitInfo{Root: "wt", Name: "main-repo", Branch: "topic", Remote: "origin", RemoteURL: "https://example.com/me/main-repo.git"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findGitRepo(filepath.Join(root, tt.path))
+			if got == nil {
+				t.Fatalf("findGitRepo(%s) = nil", tt.path)
+			}
+			tt.want.Root = filepath.Join(root, tt.want.Root)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findGitRepo(%s) = %+v, want %+v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	// A .git file that isn't a gitdir link doesn't count, nor does a
+	// relative path. The temporary directory may itself be in a repository.
+	for _, path := range []string{filepath.Join(root, "broken/nested"), filepath.Join(root, "outside"), "app/src", ""} {
+		if got := findGitRepo(path); got != nil && strings.HasPrefix(got.Root, root) {
+			t.Errorf("findGitRepo(%q) = %+v, want nil", path, got)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is one pattern from a .gitignore file.
+type ignoreRule struct {
+	base     string // Directory of the .gitignore, relative to the repository root
+	pattern  *regexp.Regexp
+	negate   bool
+	dirOnly  bool
+	baseName bool // No slash in the pattern, so it matches names at any depth
+}
+
+// readIgnoreFile parses a gitignore-style file whose patterns are relative
+// to base. A missing file has no rules.
+func readIgnoreFile(file, base string) []ignoreRule {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnorePattern(scanner.Text(), base); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// parseIgnorePattern follows gitignore(5): "!" negates, a trailing slash
+// matches only directories, and a slash anywhere else anchors the pattern
+// to the .gitignore's directory.
+func parseIgnorePattern(line, base string) (ignoreRule, bool) {
+	rule := ignoreRule{base: base}
+
+	// Trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+	if rest, ok := strings.CutPrefix(line, "!"); ok {
+		rule.negate = true
+		line = rest
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if rest, ok := strings.CutSuffix(line, "/"); ok {
+		rule.dirOnly = true
+		line = rest
+	}
+	if line == "" {
+		return rule, false
+	}
+
+	rule.baseName = !strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	re, err := regexp.Compile("^" + globToRegexp(line) + "$")
+	if err != nil {
+		return rule, false
+	}
+	rule.pattern = re
+	return rule, true
+}
+
+// globToRegexp translates a gitignore glob, where "*" stays within a path
+// component and "**" crosses them.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if rest, ok := strings.CutPrefix(class, "!"); ok {
+				class = "^" + rest
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// ignored reports whether a path relative to the repository root is
+// excluded. The last matching rule wins, so deeper .gitignore files and
+// later lines override earlier ones.
+func ignored(rules []ignoreRule, rel string, isDir bool) bool {
+	result := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		sub := rel
+		if rule.base != "" {
+			var ok bool
+			if sub, ok = strings.CutPrefix(rel, rule.base+"/"); !ok {
+				continue
+			}
+		}
+		if rule.baseName {
+			sub = path.Base(sub)
+		}
+		if rule.pattern.MatchString(sub) {
+			result = !rule.negate
+		}
+	}
+	return result
+}
+
+// repoIgnoreRules collects the rules that apply across a whole repository:
+// the user's global excludes, .git/info/exclude and the top-level .gitignore.
+func repoIgnoreRules(root string) []ignoreRule {
+	var rules []ignoreRule
+	if configDir, err := os.UserConfigDir(); err == nil {
+		rules = append(rules, readIgnoreFile(filepath.Join(configDir, "git", "ignore"), "")...)
+	}
+	if gitDir := resolveGitDir(root); gitDir != "" {
+		rules = append(rules, readIgnoreFile(filepath.Join(gitDir, "info", "exclude"), "")...)
+	}
+	return append(rules, readIgnoreFile(filepath.Join(root, ".gitignore"), "")...)
+}
@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestIgnored(t *testing.T) {
+	// Rules from a top-level .gitignore, then one in src/
+	rulesFrom := func(top []string, src []string) []ignoreRule {
+		var rules []ignoreRule
+		for _, line := range top {
+			if rule, ok := parseIgnorePattern(line, ""); ok {
+				rules = append(rules, rule)
+			}
+		}
+		for _, line := range src {
+			if rule, ok := parseIgnorePattern(line, "src"); ok {
+				rules = append(rules, rule)
+			}
+		}
+		return rules
+	}
+
+	tests := []struct {
+		name  string
+		top   []string
+		src   []string
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"name at any depth", []string{"*.log"}, nil, "a/b/debug.log", false, true},
+		{"name not matching", []string{"*.log"}, nil, "a/b/debug.txt", false, false},
+		{"star within a component", []string{"a/*.go"}, nil, "a/b/c.go", false, false},
+		{"comment", []string{"# *.go"}, nil, "main.go", false, false},
+		{"escaped hash", []string{`\#notes`}, nil, "#notes", false, true},
+		{"trailing spaces", []string{"*.tmp   "}, nil, "x.tmp", false, true},
+		{"escaped trailing space", []string{`name\ `}, nil, "name ", false, true},
+
+		{"anchored", []string{"/build"}, nil, "build", true, true},
+		{"anchored not deeper", []string{"/build"}, nil, "src/build", true, false},
+		{"slash in the middle anchors", []string{"docs/gen"}, nil, "docs/gen", true, true},
+		{"slash in the middle not deeper", []string{"docs/gen"}, nil, "x/docs/gen", true, false},
+		{"unanchored at any depth", []string{"build"}, nil, "src/build", true, true},
+
+		{"directory only", []string{"out/"}, nil, "out", true, true},
+		{"directory only skips files", []string{"out/"}, nil, "out", false, false},
+
+		{"leading double star", []string{"**/testdata"}, nil, "a/b/testdata", true, true},
+		{"leading double star at the top", []string{"**/testdata"}, nil, "testdata", true, true},
+		{"trailing double star", []string{"logs/**"}, nil, "logs/a/b.txt", false, true},
+		{"middle double star", []string{"a/**/z"}, nil, "a/b/c/z", false, true},
+		{"middle double star with nothing between", []string{"a/**/z"}, nil, "a/z", false, true},
+		{"character class", []string{"*.[oa]"}, nil, "lib.a", false, true},
+		{"negated character class", []string{"*.[!oa]"}, nil, "lib.a", false, false},
+		{"question mark", []string{"?.txt"}, nil, "ab.txt", false, false},
+
+		{"negated", []string{"*.log", "!keep.log"}, nil, "keep.log", false, false},
+		{"negation before the rule it undoes", []string{"!keep.log", "*.log"}, nil, "keep.log", false, true},
+		{"escaped bang", []string{`\!important`}, nil, "!important", false, true},
+
+		{"nested", nil, []string{"*.gen.go"}, "src/api.gen.go", false, true},
+		{"nested doesn't reach outside", nil, []string{"*.gen.go"}, "api.gen.go", false, false},
+		{"nested anchored", nil, []string{"/tmp"}, "src/tmp", true, true},
+		{"nested anchored not deeper", nil, []string{"/tmp"}, "src/x/tmp", true, false},
+		{"nested negation overrides the top", []string{"*.gen.go"}, []string{"!api.gen.go"}, "src/api.gen.go", false, false},
+		{"top negation doesn't override nested", []string{"!api.gen.go"}, []string{"*.gen.go"}, "src/api.gen.go", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ignored(rulesFrom(tt.top, tt.src), tt.path, tt.isDir); got != tt.want {
+				t.Errorf("ignored(%q) with %q and src/%q = %v, want %v", tt.path, tt.top, tt.src, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const heartbeatMaxBody = 1 << 20
+
+// Heartbeat is an editor plugin reporting what it's doing, in the shape
+// WakaTime's API accepts, so existing plugins (through wakatime-cli with
+// api_url pointed here) can feed the detector.
+type Heartbeat struct {
+	Entity    string  `json:"entity"`             // File path, or app or domain name
+	Type      string  `json:"type,omitempty"`     // file, app or domain
+	Category  string  `json:"category,omitempty"` // coding, debugging, code reviewing, ...
+	Time      float64 `json:"time"`               // Unix seconds
+	Project   string  `json:"project,omitempty"`
+	Branch    string  `json:"branch,omitempty"`
+	Language  string  `json:"language,omitempty"`
+	IsWrite   bool    `json:"is_write,omitempty"`
+	Lines     int     `json:"lines,omitempty"`
+	LineNo    int     `json:"lineno,omitempty"`
+	CursorPos int     `json:"cursorpos,omitempty"`
+	UserAgent string  `json:"user_agent,omitempty"`
+
+	// Not part of WakaTime's API; plugins written for us can name the
+	// editor instead of building a user agent
+	Editor string `json:"editor,omitempty"`
+}
+
+func (hb *Heartbeat) at() time.Time {
+	sec := int64(hb.Time)
+	return time.Unix(sec, int64((hb.Time-float64(sec))*1e9))
+}
+
+// editor finds the editor in a WakaTime user agent, such as
+// "wakatime/v1.90.0 (linux-6.6-x86_64) go1.22 vim/9.1 vim-wakatime/11.2.0",
+// skipping the CLI's own parts and the plugin's.
+func (hb *Heartbeat) editor() string {
+	if hb.Editor != "" {
+		return strings.ToLower(hb.Editor)
+	}
+	for _, token := range strings.Fields(hb.UserAgent) {
+		name, _, ok := strings.Cut(token, "/")
+		if !ok || name == "" || strings.HasPrefix(token, "(") {
+			continue
+		}
+		name = strings.ToLower(name)
+		if name == "wakatime" || strings.Contains(name, "wakatime") {
+			continue
+		}
+		return name
+	}
+	return ""
+}
+
+// Editor names in user agents that differ from our program names
+var heartbeatEditors = map[string]string{
+	"code":         "vscode",
+	"neovim":       "vim",
+	"nvim":         "vim",
+	"intellij":     "idea",
+	"sublime_text": "sublime",
+	"sublime":      "sublime",
+}
+
+// HeartbeatStore keeps the latest heartbeat from each editor.
+type HeartbeatStore struct {
+	maxAge  time.Duration
+	program func(editor string) string
+
+	mu     sync.Mutex
+	latest map[string]*Heartbeat // By program name
+}
+
+// NewHeartbeatStore takes how long a heartbeat stays trustworthy and a
+// way to turn an editor name into a program name.
+func NewHeartbeatStore(maxAge time.Duration, program func(editor string) string) *HeartbeatStore {
+	return &HeartbeatStore{
+		maxAge:  maxAge,
+		program: program,
+		latest:  make(map[string]*Heartbeat),
+	}
+}
+
+// Add stores a heartbeat received at now. One without a time, or stamped
+// in the future by a plugin with a wrong clock, is taken to be from now;
+// otherwise it would beat every real heartbeat and never go stale.
+func (s *HeartbeatStore) Add(hb *Heartbeat, now time.Time) {
+	if hb.Time == 0 || hb.at().After(now) {
+		hb.Time = float64(now.UnixNano()) / 1e9
+	}
+	program := s.program(hb.editor())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Plugins replay queued heartbeats after being offline
+	if last, ok := s.latest[program]; ok && last.Time > hb.Time {
+		return
+	}
+	s.latest[program] = hb
+}
+
+// Latest returns the program's most recent heartbeat if it's fresh.
+func (s *HeartbeatStore) Latest(program string, now time.Time) *Heartbeat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hb, ok := s.latest[program]
+	if !ok || now.Sub(hb.at()) > s.maxAge {
+		return nil
+	}
+	return hb
+}
+
+// HeartbeatServer accepts heartbeats over HTTP on loopback TCP addresses
+// and Unix sockets.
+type HeartbeatServer struct {
+	store  *HeartbeatStore
+	listen []string
+}
+
+func NewHeartbeatServer(store *HeartbeatStore, listen []string) *HeartbeatServer {
+	return &HeartbeatServer{store: store, listen: listen}
+}
+
+// Start listens on every address, logging the ones that fail.
+func (s *HeartbeatServer) Start() {
+	for _, address := range s.listen {
+		listener, err := listenHeartbeat(address)
+		if err != nil {
+			log.Printf("Warning: Could not listen for heartbeats on %s: %v", address, err)
+			continue
+		}
+		log.Printf("Listening for editor heartbeats on %s", address)
+
+		// Web pages can only reach TCP listeners, through DNS rebinding
+		_, unix := listener.(*net.UnixListener)
+		server := &http.Server{
+			Handler:           s.handler(!unix),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		go func(address string, listener net.Listener) {
+			if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+				log.Printf("Warning: Heartbeat server on %s stopped: %v", address, err)
+			}
+		}(address, listener)
+	}
+}
+
+func (s *HeartbeatServer) handler(checkHost bool) http.Handler {
+	handle := func(w http.ResponseWriter, r *http.Request) {
+		if status, reason := checkHeartbeatRequest(r, checkHost); status != 0 {
+			http.Error(w, reason, status)
+			return
+		}
+		s.handleHeartbeats(w, r)
+	}
+
+	mux := http.NewServeMux()
+	// wakatime-cli appends these to api_url, with or without /api/v1
+	for _, prefix := range []string{"", "/api/v1"} {
+		mux.HandleFunc(prefix+"/users/current/heartbeats", handle)
+		mux.HandleFunc(prefix+"/users/current/heartbeats.bulk", handle)
+	}
+	return mux
+}
+
+// checkHeartbeatRequest turns away what a web page could send: browsers
+// add an Origin to cross-site requests and can't send JSON without one,
+// and a page on a rebound domain names that domain as the Host. It returns
+// the status to refuse the request with, or 0.
+func checkHeartbeatRequest(r *http.Request, checkHost bool) (int, string) {
+	if r.Header.Get("Origin") != "" {
+		return http.StatusForbidden, "requests from web pages are not accepted"
+	}
+	if checkHost {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if !isLoopbackHost(strings.Trim(host, "[]")) {
+			return http.StatusForbidden, "host must be a loopback address"
+		}
+	}
+	if r.Method == http.MethodPost {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "application/json" {
+			return http.StatusUnsupportedMediaType, "content type must be application/json"
+		}
+	}
+	return 0, ""
+}
+
+// isLoopbackHost accepts localhost and loopback IP addresses.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// listenHeartbeat opens "unix:/path" as a socket only the user can use,
+// and anything else as TCP. The socket is created in a private directory
+// and moved into place once restricted, so nobody else can connect in the
+// meantime.
+func listenHeartbeat(address string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(address, "unix:")
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+
+	// Clear out a socket left behind by a previous run, but not one
+	// another instance is still serving
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is already in use", path)
+		}
+		if !errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, fmt.Errorf("failed to check socket %s: %w", path, err)
+		}
+		os.Remove(path)
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".emotional-support-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "socket")
+
+	listener, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	// Closing would otherwise remove the name it was created under
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to move socket into place: %w", err)
+	}
+	return listener, nil
+}
+
+// handleHeartbeats takes one heartbeat or an array of them, and answers
+// the way WakaTime does so plugins don't retry.
+func (s *HeartbeatServer) handleHeartbeats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, heartbeatMaxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var heartbeats []*Heartbeat
+	bulk := bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+	if bulk {
+		err = json.Unmarshal(body, &heartbeats)
+	} else {
+		var hb Heartbeat
+		err = json.Unmarshal(body, &hb)
+		heartbeats = []*Heartbeat{&hb}
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	responses := make([][]interface{}, 0, len(heartbeats))
+	for _, hb := range heartbeats {
+		if hb == nil || hb.Entity == "" {
+			responses = append(responses, []interface{}{map[string]string{"error": "entity is required"}, http.StatusBadRequest})
+			continue
+		}
+		if hb.UserAgent == "" {
+			hb.UserAgent = r.UserAgent()
+		}
+		s.store.Add(hb, now)
+		responses = append(responses, []interface{}{map[string]interface{}{"data": hb}, http.StatusCreated})
+	}
+
+	if bulk {
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"responses": responses})
+	} else {
+		writeJSON(w, responses[0][1].(int), responses[0][0])
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
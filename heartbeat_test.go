@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatRequestChecks(t *testing.T) {
+	const body = `{"entity": "/src/main.go", "language": "Go", "editor": "vim"}`
+	tests := []struct {
+		name        string
+		host        string
+		contentType string
+		origin      string
+		checkHost   bool
+		want        int
+	}{
+		{"plugin", "127.0.0.1:8975", "application/json", "", true, http.StatusCreated},
+		{"localhost with charset", "localhost:8975", "application/json; charset=utf-8", "", true, http.StatusCreated},
+		{"ipv6 loopback", "[::1]:8975", "application/json", "", true, http.StatusCreated},
+		{"web page", "127.0.0.1:8975", "application/json", "https://example.com", true, http.StatusForbidden},
+		{"simple request", "127.0.0.1:8975", "text/plain", "", true, http.StatusUnsupportedMediaType},
+		{"form", "127.0.0.1:8975", "application/x-www-form-urlencoded", "", true, http.StatusUnsupportedMediaType},
+		{"dns rebinding", "evil.example.com:8975", "application/json", "", true, http.StatusForbidden},
+		{"unix socket", "unix", "application/json", "", false, http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewHeartbeatServer(NewHeartbeatStore(time.Minute, func(editor string) string { return editor }), nil)
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/users/current/heartbeats", strings.NewReader(body))
+			r.Host = tt.host
+			r.Header.Set("Content-Type", tt.contentType)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			server.handler(tt.checkHost).ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			stored := server.store.Latest("vim", time.Now()) != nil
+			if stored != (tt.want == http.StatusCreated) {
+				t.Errorf("heartbeat stored = %v with status %d", stored, w.Code)
+			}
+		})
+	}
+}
+
+func TestListenHeartbeatSocket(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "heartbeat.sock")
+
+	listener, err := listenHeartbeat("unix:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode %v, want a socket with 0600", info.Mode())
+	}
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("socket moved into place doesn't accept connections: %v", err)
+	}
+	conn.Close()
+
+	// Nothing is left behind but the socket
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d entries next to the socket, want just the socket", len(entries))
+	}
+}
+
+func TestHeartbeatStoreFutureTime(t *testing.T) {
+	store := NewHeartbeatStore(time.Minute, func(editor string) string { return editor })
+	now := time.Now()
+	unix := func(t time.Time) float64 { return float64(t.UnixNano()) / 1e9 }
+
+	// A plugin whose clock is hours ahead
+	store.Add(&Heartbeat{Entity: "/src/stuck.go", Editor: "vim", Time: unix(now.Add(3 * time.Hour))}, now)
+	store.Add(&Heartbeat{Entity: "/src/main.go", Editor: "vim", Time: unix(now.Add(time.Second))}, now.Add(time.Second))
+	if hb := store.Latest("vim", now.Add(time.Second)); hb == nil || hb.Entity != "/src/main.go" {
+		t.Errorf("Latest() = %+v, want the real heartbeat to replace the future one", hb)
+	}
+
+	store.Add(&Heartbeat{Entity: "/src/stuck.go", Editor: "vim", Time: unix(now.Add(3 * time.Hour))}, now.Add(2*time.Second))
+	if hb := store.Latest("vim", now.Add(2*time.Second+2*time.Minute)); hb != nil {
+		t.Errorf("Latest() = %+v two minutes on, want nothing fresh", hb)
+	}
+}
+
+func TestListenHeartbeatSocketInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "heartbeat.sock")
+
+	first, err := listenHeartbeat("unix:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := first.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	if second, err := listenHeartbeat("unix:" + path); err == nil {
+		second.Close()
+		t.Fatal("took over a socket another instance is serving")
+	}
+
+	// Once that instance is gone its socket is stale and can be replaced
+	first.Close()
+	second, err := listenHeartbeat("unix:" + path)
+	if err != nil {
+		t.Fatalf("stale socket not replaced: %v", err)
+	}
+	second.Close()
+}
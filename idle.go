@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// IdleDetector reports how long the user has gone without touching the
+// keyboard or mouse.
+type IdleDetector interface {
+	IdleTime() (time.Duration, error)
+}
+
+// NewIdleDetector prefers the X11 screensaver extension, which tracks input
+// directly, and falls back to the idle hint logind keeps for the session.
+// Under sway the X server is only Xwayland and never sees most input, so
+// logind is used there.
+func NewIdleDetector() IdleDetector {
+	if display := os.Getenv("DISPLAY"); display != "" && os.Getenv("SWAYSOCK") == "" {
+		detector := NewX11IdleDetector(display)
+		if _, err := detector.IdleTime(); err == nil {
+			return detector
+		}
+	}
+	return NewLogindIdleDetector()
+}
+
+// X11IdleDetector asks the MIT-SCREEN-SAVER extension for the time since
+// the last user input.
+type X11IdleDetector struct {
+	display string
+
+	mu     sync.Mutex
+	conn   *x11Conn
+	opcode byte
+}
+
+func NewX11IdleDetector(display string) *X11IdleDetector {
+	return &X11IdleDetector{display: display}
+}
+
+func (d *X11IdleDetector) IdleTime() (time.Duration, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.conn == nil {
+		conn, err := dialX11(d.display)
+		if err != nil {
+			return 0, err
+		}
+		opcode, ok, err := conn.queryExtension("MIT-SCREEN-SAVER")
+		if err != nil {
+			conn.Close()
+			return 0, err
+		}
+		if !ok {
+			conn.Close()
+			return 0, errors.New("X server has no MIT-SCREEN-SAVER extension")
+		}
+		d.conn = conn
+		d.opcode = opcode
+	}
+
+	// ScreenSaverQueryInfo is minor opcode 1 and takes a drawable
+	req := make([]byte, 8)
+	req[0] = d.opcode
+	req[1] = 1
+	binary.LittleEndian.PutUint32(req[4:], d.conn.root)
+	reply, err := d.conn.roundTrip(req)
+	if err != nil {
+		d.conn.Close()
+		d.conn = nil
+		return 0, fmt.Errorf("failed to query screensaver info: %w", err)
+	}
+
+	msSinceInput := binary.LittleEndian.Uint32(reply[16:])
+	return time.Duration(msSinceInput) * time.Millisecond, nil
+}
+
+// LogindIdleDetector reads IdleHint/IdleSinceHint from the current logind
+// session. Desktop environments (or swayidle with idlehint) set the hint.
+type LogindIdleDetector struct {
+	conn *dbus.Conn
+}
+
+func NewLogindIdleDetector() *LogindIdleDetector {
+	return &LogindIdleDetector{}
+}
+
+func (d *LogindIdleDetector) IdleTime() (time.Duration, error) {
+	if d.conn == nil {
+		conn, err := dbus.SystemBus()
+		if err != nil {
+			return 0, err
+		}
+		d.conn = conn
+	}
+
+	session := d.conn.Object("org.freedesktop.login1", "/org/freedesktop/login1/session/auto")
+	idleHint, err := session.GetProperty("org.freedesktop.login1.Session.IdleHint")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read IdleHint: %w", err)
+	}
+	if idle, ok := idleHint.Value().(bool); !ok || !idle {
+		return 0, nil
+	}
+
+	// IdleSinceHint is a CLOCK_REALTIME timestamp in microseconds
+	sinceHint, err := session.GetProperty("org.freedesktop.login1.Session.IdleSinceHint")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read IdleSinceHint: %w", err)
+	}
+	usec, ok := sinceHint.Value().(uint64)
+	if !ok || usec == 0 {
+		return 0, nil
+	}
+	return time.Since(time.UnixMicro(int64(usec))), nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// fakeIdle is an idle detector that reports what the test sets.
+type fakeIdle struct {
+	idle time.Duration
+	err  error
+}
+
+func (d *fakeIdle) IdleTime() (time.Duration, error) { return d.idle, d.err }
+
+func TestCheckIdle(t *testing.T) {
+	start := time.Date(2026, time.March, 2, 10, 0, 0, 0, time.Local)
+	now := start.Add(30 * time.Minute)
+
+	tests := []struct {
+		name     string
+		away     string
+		awayAt   time.Time
+		meeting  string
+		idle     time.Duration
+		err      error
+		wantAway string
+		sessions string
+	}{
+		{"typing", "", time.Time{}, "", 10 * time.Second, nil, "", ""},
+		{"just under the threshold", "", time.Time{}, "", 5*time.Minute - time.Second, nil, "", ""},
+		// The session ends when the user last touched anything
+		{"gone idle", "", time.Time{}, "", 10 * time.Minute, nil, "idle", "vim 20m0s"},
+		{"still in a call", "", time.Time{}, "window zoom", 10 * time.Minute, nil, "", ""},
+		{"still idle", "idle", start.Add(20 * time.Minute), "", 10 * time.Minute, nil, "idle", ""},
+		{"back from idle", "idle", start.Add(20 * time.Minute), "", time.Second, nil, "", ""},
+		{"idle time unknown", "", time.Time{}, "", 0, errors.New("no X server"), "", ""},
+		{"idle time unknown while away", "idle", start.Add(20 * time.Minute), "", 0, errors.New("no X server"), "idle", ""},
+		// Input right after pressing the button doesn't end a break
+		{"leaving for a break", "break", now.Add(-30 * time.Second), "", 0, nil, "break", ""},
+		{"back from a break", "break", start.Add(20 * time.Minute), "", time.Second, nil, "", ""},
+		{"still on a break", "break", start.Add(20 * time.Minute), "", 9*time.Minute + 30*time.Second, nil, "break", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApp()
+			app.database = newTestDatabase(t)
+			app.idle = &fakeIdle{idle: tt.idle, err: tt.err}
+			app.handleWindow(testEditorWindow, start)
+			app.meeting = tt.meeting
+			if tt.away != "" {
+				app.startAway(tt.away, tt.awayAt)
+			}
+			before := len(loggedSessions(t, app.database))
+
+			if away := app.checkIdle(now); away != (tt.wantAway != "") || app.awayReason != tt.wantAway {
+				t.Errorf("checkIdle() = %v away for %q, want %q", away, app.awayReason, tt.wantAway)
+			}
+			if got := strings.Join(loggedSessions(t, app.database)[before:], ","); got != tt.sessions {
+				t.Errorf("sessions %s, want %s", got, tt.sessions)
+			}
+		})
+	}
+}
+
+func TestCheckIdleWhileLockedOrAsleep(t *testing.T) {
+	app, _ := newTestApp()
+	app.idle = &fakeIdle{}
+	for _, set := range []func(){func() { app.locked = true }, func() { app.locked, app.asleep = false, true }} {
+		set()
+		if !app.checkIdle(time.Now()) {
+			t.Errorf("checkIdle() = false while locked %v and asleep %v", app.locked, app.asleep)
+		}
+	}
+}
+
+// newTestLogind puts a logind session with the given idle hints on a
+// private bus and returns a detector connected to it.
+func newTestLogind(t *testing.T, idle bool, since time.Time) *LogindIdleDetector {
+	t.Helper()
+	address := privateSessionBus(t)
+	connect := func() *dbus.Conn {
+		conn, err := dbus.Connect(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+
+	server := connect()
+	var usec uint64
+	if !since.IsZero() {
+		usec = uint64(since.UnixMicro())
+	}
+	_, err := prop.Export(server, "/org/freedesktop/login1/session/auto", prop.Map{
+		"org.freedesktop.login1.Session": {
+			"IdleHint":      {Value: idle},
+			"IdleSinceHint": {Value: usec},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := server.RequestName("org.freedesktop.login1", dbus.NameFlagDoNotQueue); err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("could not own org.freedesktop.login1: %v", err)
+	}
+	return &LogindIdleDetector{conn: connect()}
+}
+
+func TestLogindIdleDetector(t *testing.T) {
+	tests := []struct {
+		name     string
+		idle     bool
+		since    time.Duration // Before now, or no hint when zero
+		min, max time.Duration
+	}{
+		{"active", false, 10 * time.Minute, 0, 0},
+		{"idle", true, 10 * time.Minute, 10 * time.Minute, 11 * time.Minute},
+		{"idle without a time", true, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var since time.Time
+			if tt.since > 0 {
+				since = time.Now().Add(-tt.since)
+			}
+			idle, err := newTestLogind(t, tt.idle, since).IdleTime()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if idle < tt.min || idle > tt.max {
+				t.Errorf("IdleTime() = %s, want between %s and %s", idle, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestX11IdleDetector(t *testing.T) {
+	detector := NewX11IdleDetector(startXvfb(t))
+	// Asked twice, to go through the kept connection as well
+	for i := 0; i < 2; i++ {
+		idle, err := detector.IdleTime()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if idle < 0 || idle > time.Minute {
+			t.Errorf("IdleTime() = %s on a new display", idle)
+		}
+	}
+}
@@ -5,7 +5,10 @@ import (
 )
 
 func main() {
-	app := NewEmotionalSupportApp()
+	app, err := NewEmotionalSupportApp()
+	if err != nil {
+		log.Fatalf("Error starting app: %v", err)
+	}
 	if err := app.Run(); err != nil {
 		log.Fatalf("Error running app: %v", err)
 	}
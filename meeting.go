@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// How often pactl is asked about microphone use
+	meetingCheckInterval = 15 * time.Second
+	pactlTimeout         = 2 * time.Second
+)
+
+// captureStream is a recording stream as PulseAudio (or PipeWire's
+// PulseAudio server) reports it.
+type captureStream struct {
+	Application string // application.name, e.g. "Firefox"
+	Binary      string // application.process.binary, e.g. "firefox"
+	Corked      bool   // Paused by the application
+	Monitor     bool   // Recording what's played rather than the microphone
+}
+
+// MeetingDetector decides whether the user is in a call: the focused window
+// is a meeting, or a meeting app or browser has the microphone open. Meeting
+// apps merely running doesn't count, since they tend to sit in the tray.
+type MeetingDetector struct {
+	processes []*regexp.Regexp
+
+	checked   time.Time
+	capturing string // Who had the microphone at the last check
+	noPactl   bool
+	failing   bool // Only the first of a run of failures is logged
+}
+
+func NewMeetingDetector(config *MeetingConfig) *MeetingDetector {
+	md := &MeetingDetector{}
+	for _, pattern := range config.Processes {
+		md.processes = append(md.processes, regexp.MustCompile(pattern))
+	}
+	return md
+}
+
+// Detect returns what gives the meeting away, or "" when there's none.
+func (md *MeetingDetector) Detect(ctx *Context, now time.Time) string {
+	if ctx != nil && ctx.Activity == ActivityMeetings {
+		return "window " + ctx.Program
+	}
+	if app := md.microphoneUser(now); app != "" {
+		return "microphone in use by " + app
+	}
+	return ""
+}
+
+// microphoneUser names the meeting app recording from a microphone. Results
+// are reused for meetingCheckInterval so pactl isn't run on every tick.
+func (md *MeetingDetector) microphoneUser(now time.Time) string {
+	if md.noPactl || len(md.processes) == 0 {
+		return ""
+	}
+	if !md.checked.IsZero() && now.Sub(md.checked) < meetingCheckInterval {
+		return md.capturing
+	}
+	md.checked = now
+
+	streams, err := captureStreams()
+	if err != nil {
+		if _, lookErr := exec.LookPath("pactl"); lookErr != nil {
+			log.Printf("Warning: pactl not found, meetings are only detected from windows")
+			md.noPactl = true
+		} else if !md.failing {
+			log.Printf("Warning: Could not list capture streams: %v", err)
+		}
+		md.failing = true
+		md.capturing = ""
+		return ""
+	}
+
+	md.failing = false
+	md.capturing = ""
+	for _, stream := range streams {
+		if stream.Corked || stream.Monitor {
+			continue
+		}
+		name := stream.Binary
+		if name == "" {
+			name = stream.Application
+		}
+		for _, pattern := range md.processes {
+			if pattern.MatchString(name) {
+				md.capturing = name
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func runPactl(args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pactlTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "pactl", args...)
+	// The long listing is translated, so ask for the untranslated one
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	return cmd.Output()
+}
+
+// captureStreams lists the source outputs, marking those recording from a
+// monitor source.
+func captureStreams() ([]captureStream, error) {
+	sources, err := runPactl("list", "short", "sources")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sources: %w", err)
+	}
+	monitors := monitorSources(sources)
+
+	outputs, err := runPactl("list", "source-outputs")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source outputs: %w", err)
+	}
+	return parseSourceOutputs(outputs, monitors), nil
+}
+
+// monitorSources picks the monitor sources' indexes out of "pactl list short
+// sources", whose lines are index, name, driver, format and state.
+func monitorSources(output []byte) map[string]bool {
+	monitors := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) >= 2 && strings.HasSuffix(fields[1], ".monitor") {
+			monitors[fields[0]] = true
+		}
+	}
+	return monitors
+}
+
+// parseSourceOutputs reads the long "pactl list source-outputs" format:
+//
+//	Source Output #84
+//		Source: 56
+//		Corked: no
+//		Properties:
+//			application.name = "Firefox"
+//			application.process.binary = "firefox"
+func parseSourceOutputs(output []byte, monitors map[string]bool) []captureStream {
+	var streams []captureStream
+	var current *captureStream
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "Source Output #") {
+			streams = append(streams, captureStream{})
+			current = &streams[len(streams)-1]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		line = strings.TrimSpace(line)
+		if key, value, ok := strings.Cut(line, " = "); ok {
+			if value, err := strconv.Unquote(value); err == nil {
+				switch key {
+				case "application.name":
+					current.Application = value
+				case "application.process.binary":
+					current.Binary = value
+				case "stream.capture.sink":
+					// PipeWire's own marker for recording a sink
+					if value == "true" {
+						current.Monitor = true
+					}
+				}
+				continue
+			}
+		}
+		if key, value, ok := strings.Cut(line, ": "); ok {
+			switch key {
+			case "Source":
+				current.Monitor = current.Monitor || monitors[value]
+			case "Corked":
+				current.Corked = value == "yes"
+			}
+		}
+	}
+	return streams
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testSources = "55\talsa_output.pci-0000_00_1f.3.analog-stereo.monitor\tPipeWire\ts32le 2ch 48000Hz\tSUSPENDED\n" +
+	"56\talsa_input.pci-0000_00_1f.3.analog-stereo\tPipeWire\ts32le 2ch 48000Hz\tRUNNING\n" +
+	"57\tbluez_output.00_11_22.1.monitor\tPipeWire\ts16le 2ch 48000Hz\tIDLE\n"
+
+const testSourceOutputs = `Source Output #80
+	Driver: PipeWire
+	Source: 56
+	Corked: no
+	Properties:
+		application.name = "Firefox"
+		application.process.binary = "firefox"
+		media.name = "AudioCallbackDriver"
+
+Source Output #81
+	Source: 55
+	Corked: no
+	Properties:
+		application.name = "OBS"
+		application.process.binary = "obs"
+
+Source Output #82
+	Source: 56
+	Corked: yes
+	Properties:
+		application.name = "ZOOM VoiceEngine"
+
+Source Output #83
+	Source: 56
+	Corked: no
+	Properties:
+		application.name = "Recorder"
+		stream.capture.sink = "true"
+		media.name = "a = b"
+`
+
+func TestMonitorSources(t *testing.T) {
+	want := map[string]bool{"55": true, "57": true}
+	if got := monitorSources([]byte(testSources)); !reflect.DeepEqual(got, want) {
+		t.Errorf("monitorSources() = %v, want %v", got, want)
+	}
+	if got := monitorSources(nil); len(got) != 0 {
+		t.Errorf("monitorSources(nil) = %v", got)
+	}
+}
+
+func TestParseSourceOutputs(t *testing.T) {
+	monitors := map[string]bool{"55": true, "57": true}
+	tests := []struct {
+		name   string
+		output string
+		want   []captureStream
+	}{
+		{
+			"streams",
+			testSourceOutputs,
+			[]captureStream{
+				{Application: "Firefox", Binary: "firefox"},
+				{Application: "OBS", Binary: "obs", Monitor: true},
+				{Application: "ZOOM VoiceEngine", Corked: true},
+				{Application: "Recorder", Monitor: true},
+			},
+		},
+		{"nothing recording", "", nil},
+		{
+			"lines before the first stream",
+			"Corked: yes\napplication.name = \"stray\"\nSource Output #1\n\tSource: 56\n\tCorked: no\n",
+			[]captureStream{{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSourceOutputs([]byte(tt.output), monitors); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSourceOutputs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeCommand puts a shell script by the given name first on the PATH.
+func fakeCommand(t *testing.T, name, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestMeetingDetectorMicrophone(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"sources": testSources, "outputs": testSourceOutputs})
+	fakeCommand(t, "pactl", `case "$2" in
+short) cat `+filepath.Join(dir, "sources")+` ;;
+*) cat `+filepath.Join(dir, "outputs")+` ;;
+esac
+`)
+
+	now := time.Date(2026, time.March, 2, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		name      string
+		processes []string
+		want      string
+	}{
+		{"browser", []string{`^firefox$`}, "microphone in use by firefox"},
+		// Paused, and only known by its application name
+		{"corked", []string{`(?i)^zoom voiceengine$`}, ""},
+		{"monitor", []string{`^obs$`, `^Recorder$`}, ""},
+		{"no processes", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := NewMeetingDetector(&MeetingConfig{Enabled: true, Processes: tt.processes})
+			if got := md.Detect(nil, now); got != tt.want {
+				t.Errorf("Detect() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	md := NewMeetingDetector(&MeetingConfig{Enabled: true, Processes: []string{`^firefox$`}})
+	if got := md.Detect(&Context{Program: "zoom", Activity: ActivityMeetings}, now); got != "window zoom" {
+		t.Errorf("Detect() of a meeting window = %q", got)
+	}
+	md.Detect(nil, now)
+
+	// The answer is kept for a while rather than asking pactl every tick
+	writeFiles(t, dir, map[string]string{"outputs": ""})
+	if got := md.Detect(nil, now.Add(meetingCheckInterval-time.Second)); got == "" {
+		t.Error("Detect() asked pactl again before the interval")
+	}
+	if got := md.Detect(nil, now.Add(meetingCheckInterval)); got != "" {
+		t.Errorf("Detect() after the call = %q", got)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"math/rand"
+	"sort"
 	"strings"
 	"time"
 )
@@ -26,13 +27,22 @@ func (mg *MessageGenerator) GetTimeBasedMessage(ctx *Context, duration time.Dura
 	programName := mg.formatProgramName(ctx.Program)
 
 	// Extract meaningful info from window title
-	fileInfo := mg.extractFileInfo(ctx.WindowTitle)
-	projectInfo := mg.extractProjectInfo(ctx.WindowTitle, ctx.ProjectPath)
+	fileInfo := ctx.FileName
+	projectInfo := ctx.ProjectName
+	if projectInfo == "" {
+		projectInfo = ctx.RepoName
+	}
+	if projectInfo == "" {
+		projectInfo = mg.extractProjectInfo(ctx.WindowTitle, ctx.ProjectPath)
+	}
 
 	messages := []string{}
 
-	// Program-specific messages with window title context
-	if ctx.Program == "vim" || ctx.Program == "nvim" {
+	// Some kinds of work get their own messages whatever the program,
+	// otherwise program-specific messages with window title context
+	if activityMessages := mg.activityMessages(ctx, programName, timeStr); activityMessages != nil {
+		messages = activityMessages
+	} else if ctx.Program == "vim" || ctx.Program == "nvim" {
 		if fileInfo != "" {
 			messages = []string{
 				fmt.Sprintf("Wow, you've been editing %s in %s for %s! I'm so proud of you! 🎉", fileInfo, programName, timeStr),
@@ -82,7 +92,9 @@ func (mg *MessageGenerator) GetTimeBasedMessage(ctx *Context, duration time.Dura
 		}
 	} else {
 		// Non-programming apps - use window title if available
-		if ctx.Program == "firefox" || ctx.Program == "chrome" || ctx.Program == "chromium" {
+		if ctx.SiteCategory != "" {
+			messages = mg.siteMessages(ctx, timeStr)
+		} else if ctx.Program == "firefox" || ctx.Program == "chrome" || ctx.Program == "chromium" {
 			if ctx.WindowTitle != "" && len(ctx.WindowTitle) < 50 {
 				messages = []string{
 					fmt.Sprintf("%s is truly the best! You've been on '%s' for %s! 🌐", programName, mg.truncateTitle(ctx.WindowTitle, 40), timeStr),
@@ -119,40 +131,6 @@ func (mg *MessageGenerator) GetTimeBasedMessage(ctx *Context, duration time.Dura
 	return ""
 }
 
-func (mg *MessageGenerator) extractFileInfo(windowTitle string) string {
-	if windowTitle == "" {
-		return ""
-	}
-
-	// Try to extract filename from common patterns
-	// Examples: "main.go - Editor", "/path/to/file.py", "file.py (Project Name)"
-
-	// Look for file extensions
-	extensions := []string{".go", ".py", ".js", ".ts", ".java", ".rs", ".cpp", ".c", ".h", ".rb", ".php", ".kt", ".swift", ".dart", ".scala"}
-	for _, ext := range extensions {
-		if idx := strings.Index(windowTitle, ext); idx > 0 {
-			// Extract filename
-			start := strings.LastIndex(windowTitle[:idx], "/")
-			if start == -1 {
-				start = strings.LastIndex(windowTitle[:idx], " ")
-			}
-			if start >= 0 {
-				filename := strings.TrimSpace(windowTitle[start+1 : idx+len(ext)])
-				if len(filename) > 0 && len(filename) < 50 {
-					return filename
-				}
-			} else {
-				filename := strings.TrimSpace(windowTitle[:idx+len(ext)])
-				if len(filename) > 0 && len(filename) < 50 {
-					return filename
-				}
-			}
-		}
-	}
-
-	return ""
-}
-
 func (mg *MessageGenerator) extractProjectInfo(windowTitle, projectPath string) string {
 	// First try project path
 	if projectPath != "" {
@@ -180,6 +158,110 @@ func (mg *MessageGenerator) extractProjectInfo(windowTitle, projectPath string)
 	return ""
 }
 
+// activityMessages covers the activities that aren't about code: talking
+// to people, meetings, downtime and looking after the computer. Browser
+// tabs on a known site get site messages instead.
+func (mg *MessageGenerator) activityMessages(ctx *Context, programName, timeStr string) []string {
+	if ctx.SiteCategory != "" {
+		return nil
+	}
+
+	switch ctx.Activity {
+	case ActivityCommunication:
+		return []string{
+			fmt.Sprintf("%s catching up on messages in %s! Staying connected matters 💬", timeStr, programName),
+			fmt.Sprintf("You've been in %s for %s. Inbox zero is in sight! 📬", programName, timeStr),
+			fmt.Sprintf("%s of keeping everyone in the loop! Maybe a short break before diving back in? 💚", timeStr),
+		}
+	case ActivityMeetings:
+		return []string{
+			fmt.Sprintf("%s of meetings! Grab some water when it's over 💧", timeStr),
+			fmt.Sprintf("You've been in a meeting for %s. You're doing great! 💚", timeStr),
+		}
+	case ActivityEntertainment:
+		return []string{
+			fmt.Sprintf("%s in %s! Enjoy the downtime, you've earned it 🎮", timeStr, programName),
+			fmt.Sprintf("%s of %s. Remember to stretch when you're done! 🌱", timeStr, programName),
+		}
+	case ActivitySystem:
+		return []string{
+			fmt.Sprintf("%s of tidying up in %s! A well-kept system is a happy system 🧹", timeStr, programName),
+			fmt.Sprintf("You've been looking after your computer in %s for %s! 🛠️", programName, timeStr),
+		}
+	}
+	return nil
+}
+
+// siteMessages celebrates time on programming-adjacent sites and gently
+// nudges away from social media and video.
+func (mg *MessageGenerator) siteMessages(ctx *Context, timeStr string) []string {
+	site := ctx.Site
+	project := ctx.ProjectName
+	if project == "" {
+		project = ctx.RepoName
+	}
+
+	switch ctx.SiteCategory {
+	case SiteDocs:
+		if ctx.Language != "" {
+			lang := formatLanguageName(ctx.Language)
+			return []string{
+				fmt.Sprintf("%s reading up on %s! Learning is real work too 📚", timeStr, lang),
+				fmt.Sprintf("Deep in the %s docs for %s! Future you says thanks 💚", lang, timeStr),
+				fmt.Sprintf("%s of %s research! Reading the manual is a superpower 🦸", timeStr, lang),
+			}
+		}
+		return []string{
+			fmt.Sprintf("%s reading %s! Learning is real work too 📚", timeStr, site),
+			fmt.Sprintf("%s of research on %s! Future you says thanks 💚", timeStr, site),
+			fmt.Sprintf("Reading the manual for %s? That's a superpower 🦸", timeStr),
+		}
+	case SiteCodeReview:
+		if project != "" {
+			return []string{
+				fmt.Sprintf("%s reviewing code on %s! The %s team is lucky to have you 🔍", timeStr, project, project),
+				fmt.Sprintf("Careful reviews make great code! %s on %s so far 💚", timeStr, project),
+				fmt.Sprintf("%s of code review on %s! Don't forget to leave some kind words too 🌟", timeStr, project),
+			}
+		}
+		return []string{
+			fmt.Sprintf("%s reviewing code on %s! Your teammates are lucky to have you 🔍", timeStr, site),
+			fmt.Sprintf("Careful reviews make great code! %s so far 💚", timeStr),
+			fmt.Sprintf("%s of code review! Don't forget to leave some kind words too 🌟", timeStr),
+		}
+	case SiteIssueTracker:
+		return []string{
+			fmt.Sprintf("%s in %s untangling issues! Every ticket counts 🎯", timeStr, site),
+			fmt.Sprintf("Triage is important work! %s on %s so far 💚", timeStr, site),
+			fmt.Sprintf("%s of planning and triage! The backlog fears you 💪", timeStr),
+		}
+	case SiteCodeHosting:
+		if project != "" {
+			return []string{
+				fmt.Sprintf("%s exploring %s on %s! Reading code is how you get good 🚀", timeStr, project, site),
+				fmt.Sprintf("Digging through %s for %s! You're doing great 💚", project, timeStr),
+			}
+		}
+		return []string{
+			fmt.Sprintf("%s reading code on %s! That's how you get good 🚀", timeStr, site),
+			fmt.Sprintf("Digging through code on %s for %s! You're doing great 💚", site, timeStr),
+		}
+	case SiteSocial:
+		return []string{
+			fmt.Sprintf("You've been on %s for %s. Maybe time for a stretch, or back to what you were doing? 💚", site, timeStr),
+			fmt.Sprintf("%s of %s! No judgement, just a gentle nudge 🌱", timeStr, site),
+			fmt.Sprintf("%s on %s. Your project misses you a little 💚", timeStr, site),
+		}
+	case SiteVideo:
+		return []string{
+			fmt.Sprintf("%s on %s! Hope it's a good one. Maybe a break after this? 🍿", timeStr, site),
+			fmt.Sprintf("You've been watching %s for %s. Remember to rest your eyes 👀", site, timeStr),
+			fmt.Sprintf("%s of %s! Enjoy it, then maybe a quick walk? 🌱", timeStr, site),
+		}
+	}
+	return nil
+}
+
 func (mg *MessageGenerator) truncateTitle(title string, maxLen int) string {
 	if len(title) <= maxLen {
 		return title
@@ -259,8 +341,117 @@ func (mg *MessageGenerator) GetLanguageMessage(language string) string {
 		},
 		"javascript": {
 			"JavaScript can be wild, but you're taming it! 🚀",
-			"Keep up the great work with JavaScript/TypeScript! 💪",
-			"You're doing amazing with JS/TS! 🌟",
+			"Keep up the great work with JavaScript! 💪",
+			"You're doing amazing with JS! 🌟",
+		},
+		"typescript": {
+			"Those types are going to save you so much pain later! 🛡️",
+			"TypeScript and you are a great team! 💙",
+			"Keep making the compiler happy! You're doing great with TypeScript! 🌟",
+		},
+		"c": {
+			"C is close to the metal and you're handling it! 🔩",
+			"Pointers don't scare you! Keep going with C! 💪",
+			"Writing C takes real care, and you've got it! 🌟",
+		},
+		"csharp": {
+			"C# is looking sharp in your hands! 🎵",
+			"Keep up the great work with C#! 💪",
+		},
+		"kotlin": {
+			"Kotlin is such a joy, and so are you! 💜",
+			"Keep up the great work with Kotlin! 🌟",
+		},
+		"scala": {
+			"Scala's type system is no joke, but neither are you! 💪",
+			"Keep climbing that Scala staircase! 🌟",
+		},
+		"ruby": {
+			"Your Ruby is a gem! 💎",
+			"Keep making Ruby sparkle! ✨",
+		},
+		"php": {
+			"PHP runs half the web, and you're keeping it running! 🐘",
+			"Keep up the great work with PHP! 💪",
+		},
+		"swift": {
+			"Your Swift code is flying! 🐦",
+			"Keep up the great work with Swift! 🌟",
+		},
+		"dart": {
+			"Dart is right on target with you! 🎯",
+			"Keep up the great work with Dart! 💙",
+		},
+		"elixir": {
+			"Let it crash, but not you! Keep going with Elixir! 💧",
+			"Your Elixir pipelines are flowing beautifully! ✨",
+		},
+		"erlang": {
+			"Nine nines of uptime, and you're on it! Keep going with Erlang! 📞",
+			"Keep up the great work with Erlang! 💪",
+		},
+		"haskell": {
+			"A monad is just a monoid in the category of endofunctors, and you've got this! λ",
+			"Haskell is hard, but your types are lovely! 💜",
+			"Keep composing those functions! You're doing great! ✨",
+		},
+		"ocaml": {
+			"OCaml and you make a great pair! 🐫",
+			"Keep pattern matching your way through it! 💪",
+		},
+		"clojure": {
+			"So many parentheses, so much progress! Keep going with Clojure! 🌀",
+			"Your Clojure is elegantly simple! ✨",
+		},
+		"zig": {
+			"Zig is zigging and you're zagging! Keep going! ⚡",
+			"Explicit allocators hold no fear for you! 💪",
+		},
+		"lua": {
+			"Your Lua is shining bright! 🌙",
+			"Keep up the great work with Lua! ✨",
+		},
+		"perl": {
+			"There's more than one way to do it, and you're finding them all! 🐪",
+			"Keep up the great work with Perl! 💪",
+		},
+		"julia": {
+			"Your Julia code is crunching along beautifully! 📈",
+			"Keep up the great work with Julia! 🌟",
+		},
+		"shell": {
+			"Shell scripting wizardry in progress! Quote those variables! 🐚",
+			"Your scripts are going to save so much time! 💪",
+		},
+		"nix": {
+			"Nix is hard, but your builds will be reproducible forever! ❄️",
+			"Keep going with Nix! Purity is worth it! 💙",
+		},
+		"sql": {
+			"Your queries are looking sharp! 🗃️",
+			"Joins and all, you're doing great with SQL! 💪",
+		},
+		"html": {
+			"Building the web, one tag at a time! 🌐",
+			"Your markup is looking great! ✨",
+		},
+		"css": {
+			"Centering that div like a pro! 🎨",
+			"CSS is trickier than it looks, and you're nailing it! 💅",
+		},
+		"markdown": {
+			"Writing docs is a gift to everyone! Thank you! 📝",
+			"Your future readers will love this! 💚",
+		},
+		"yaml": {
+			"Careful with that indentation! You're doing great! 📐",
+			"Config wrangling is real work too! 💪",
+		},
+		"toml": {
+			"Tidy config makes for a happy project! 🧹",
+		},
+		"json": {
+			"No trailing commas! You've got this! 🧾",
 		},
 	}
 
@@ -269,9 +460,10 @@ func (mg *MessageGenerator) GetLanguageMessage(language string) string {
 	}
 
 	// Generic programming message
+	name := formatLanguageName(language)
 	generic := []string{
-		fmt.Sprintf("You're doing great with %s! Keep it up! 💚", language),
-		fmt.Sprintf("Keep pushing forward with %s! You've got this! 💪", language),
+		fmt.Sprintf("You're doing great with %s! Keep it up! 💚", name),
+		fmt.Sprintf("Keep pushing forward with %s! You've got this! 💪", name),
 	}
 	return generic[mg.rng.Intn(len(generic))]
 }
@@ -290,6 +482,111 @@ func (mg *MessageGenerator) GetHealthReminder() string {
 	return messages[mg.rng.Intn(len(messages))]
 }
 
+// How activities are named in the wrap-up
+var wrapUpActivityNames = map[string]string{
+	ActivityCoding:        "coding",
+	ActivityReviewing:     "reviewing",
+	ActivityDocs:          "reading docs",
+	ActivityCommunication: "messages",
+	ActivityMeetings:      "meetings",
+	ActivityEntertainment: "downtime",
+	ActivitySystem:        "system upkeep",
+	ActivityUncategorized: "everything else",
+}
+
+// GetWrapUpMessage sums up a working day: the time put in, the three
+// biggest activities, the languages used and the breaks taken.
+func (mg *MessageGenerator) GetWrapUpMessage(summary *DaySummary) string {
+	if summary.Total < time.Minute {
+		return "That's the end of the workday! Nothing tracked today, so enjoy the evening 🌙"
+	}
+
+	activities := make([]string, 0, len(summary.Activities))
+	for activity := range summary.Activities {
+		activities = append(activities, activity)
+	}
+	// Ties go by name, so the same day always reads the same
+	sort.SliceStable(activities, func(i, j int) bool {
+		a, b := summary.Activities[activities[i]], summary.Activities[activities[j]]
+		if a != b {
+			return a > b
+		}
+		return activities[i] < activities[j]
+	})
+	var parts []string
+	for _, activity := range activities {
+		duration := summary.Activities[activity]
+		if len(parts) == 3 || duration < time.Minute {
+			break
+		}
+		name, ok := wrapUpActivityNames[activity]
+		if !ok {
+			name = activity
+		}
+		parts = append(parts, fmt.Sprintf("%s of %s", mg.formatTotal(duration), name))
+	}
+
+	message := fmt.Sprintf("You put in %s today", mg.formatTotal(summary.Total))
+	if len(parts) > 0 {
+		message += ": " + joinList(parts)
+	}
+	if len(summary.Languages) > 0 {
+		names := make([]string, len(summary.Languages))
+		for i, language := range summary.Languages {
+			names[i] = formatLanguageName(language)
+		}
+		message += ", mostly in " + joinList(names)
+	}
+	message += "."
+	if summary.Breaks >= time.Minute {
+		message += fmt.Sprintf(" You took %s of breaks, too.", mg.formatTotal(summary.Breaks))
+	}
+
+	closings := []string{
+		" Time to rest! 🌙",
+		" Great work, see you tomorrow! 💚",
+		" Log off and enjoy the evening! 🌅",
+	}
+	return message + closings[mg.rng.Intn(len(closings))]
+}
+
+func (mg *MessageGenerator) formatTotal(d time.Duration) string {
+	return mg.formatDuration(int(d.Hours()), int(d.Minutes())%60)
+}
+
+// joinList writes "a", "a and b" or "a, b and c".
+func joinList(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
+
+// formatLanguageName turns a language key into how people write it
+func formatLanguageName(language string) string {
+	names := map[string]string{
+		"javascript": "JavaScript",
+		"typescript": "TypeScript",
+		"cpp":        "C++",
+		"csharp":     "C#",
+		"ocaml":      "OCaml",
+		"php":        "PHP",
+		"sql":        "SQL",
+		"html":       "HTML",
+		"css":        "CSS",
+		"yaml":       "YAML",
+		"toml":       "TOML",
+		"json":       "JSON",
+	}
+	if niceName, ok := names[language]; ok {
+		return niceName
+	}
+	if len(language) > 0 {
+		return strings.ToUpper(language[:1]) + language[1:]
+	}
+	return language
+}
+
 func plural(n int) string {
 	if n == 1 {
 		return ""
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Just enough MessagePack for Neovim's RPC: requests are built from
+// arrays, integers and strings, and replies are decoded into plain Go
+// values (nil, bool, int64, uint64, float64, string, []byte,
+// []interface{}, map[interface{}]interface{} and msgpackExt).
+
+// msgpackExt is an extension value. Neovim uses these for buffer, window
+// and tabpage handles.
+type msgpackExt struct {
+	Type int8
+	Data []byte
+}
+
+func msgpackAppend(buf []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, 0xc0), nil
+	case bool:
+		if v {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case int:
+		return msgpackAppendInt(buf, int64(v)), nil
+	case int64:
+		return msgpackAppendInt(buf, v), nil
+	case uint32:
+		return msgpackAppendInt(buf, int64(v)), nil
+	case string:
+		n := len(v)
+		switch {
+		case n < 32:
+			buf = append(buf, 0xa0|byte(n))
+		case n <= math.MaxUint8:
+			buf = append(buf, 0xd9, byte(n))
+		case n <= math.MaxUint16:
+			buf = binary.BigEndian.AppendUint16(append(buf, 0xda), uint16(n))
+		default:
+			buf = binary.BigEndian.AppendUint32(append(buf, 0xdb), uint32(n))
+		}
+		return append(buf, v...), nil
+	case []interface{}:
+		n := len(v)
+		switch {
+		case n < 16:
+			buf = append(buf, 0x90|byte(n))
+		case n <= math.MaxUint16:
+			buf = binary.BigEndian.AppendUint16(append(buf, 0xdc), uint16(n))
+		default:
+			buf = binary.BigEndian.AppendUint32(append(buf, 0xdd), uint32(n))
+		}
+		for _, item := range v {
+			var err error
+			if buf, err = msgpackAppend(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type %T", v)
+}
+
+func msgpackAppendInt(buf []byte, n int64) []byte {
+	switch {
+	case n >= 0 && n <= 0x7f:
+		return append(buf, byte(n))
+	case n < 0 && n >= -32:
+		return append(buf, byte(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(n))
+	}
+}
+
+// msgpackDecode reads one value.
+func msgpackDecode(r *bufio.Reader) (interface{}, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return msgpackDecodeMap(r, int(b&0x0f))
+	case b&0xf0 == 0x90:
+		return msgpackDecodeArray(r, int(b&0x0f))
+	case b&0xe0 == 0xa0:
+		data, err := msgpackRead(r, int(b&0x1f))
+		return string(data), err
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := msgpackLength(r, b-0xc4)
+		if err != nil {
+			return nil, err
+		}
+		return msgpackRead(r, n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := msgpackLength(r, b-0xc7)
+		if err != nil {
+			return nil, err
+		}
+		return msgpackDecodeExt(r, n)
+	case 0xca:
+		data, err := msgpackRead(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), nil
+	case 0xcb:
+		data, err := msgpackRead(r, 8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		data, err := msgpackRead(r, 1<<(b-0xcc))
+		if err != nil {
+			return nil, err
+		}
+		var n uint64
+		for _, c := range data {
+			n = n<<8 | uint64(c)
+		}
+		return n, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		data, err := msgpackRead(r, size)
+		if err != nil {
+			return nil, err
+		}
+		var n uint64
+		for _, c := range data {
+			n = n<<8 | uint64(c)
+		}
+		// Sign-extend from the encoded width
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return msgpackDecodeExt(r, 1<<(b-0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := msgpackLength(r, b-0xd9)
+		if err != nil {
+			return nil, err
+		}
+		data, err := msgpackRead(r, n)
+		return string(data), err
+	case 0xdc, 0xdd:
+		n, err := msgpackLength(r, b-0xdc+1)
+		if err != nil {
+			return nil, err
+		}
+		return msgpackDecodeArray(r, n)
+	case 0xde, 0xdf:
+		n, err := msgpackLength(r, b-0xde+1)
+		if err != nil {
+			return nil, err
+		}
+		return msgpackDecodeMap(r, n)
+	}
+	return nil, fmt.Errorf("msgpack: invalid type byte 0x%02x", b)
+}
+
+// msgpackLength reads a big-endian length of 1, 2 or 4 bytes (width 0, 1
+// or 2).
+func msgpackLength(r *bufio.Reader, width byte) (int, error) {
+	data, err := msgpackRead(r, 1<<width)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, c := range data {
+		n = n<<8 | int(c)
+	}
+	return n, nil
+}
+
+func msgpackRead(r *bufio.Reader, n int) ([]byte, error) {
+	if n < 0 || n > 64<<20 {
+		return nil, fmt.Errorf("msgpack: unreasonable length %d", n)
+	}
+	data := make([]byte, n)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
+
+func msgpackDecodeArray(r *bufio.Reader, n int) ([]interface{}, error) {
+	items := make([]interface{}, 0, min(n, 1024))
+	for i := 0; i < n; i++ {
+		item, err := msgpackDecode(r)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func msgpackDecodeMap(r *bufio.Reader, n int) (map[interface{}]interface{}, error) {
+	m := make(map[interface{}]interface{}, min(n, 1024))
+	for i := 0; i < n; i++ {
+		key, err := msgpackDecode(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := msgpackDecode(r)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case []byte, []interface{}, map[interface{}]interface{}, msgpackExt:
+			// Not comparable, and never used as keys by Neovim
+			continue
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+func msgpackDecodeExt(r *bufio.Reader, n int) (msgpackExt, error) {
+	t, err := r.ReadByte()
+	if err != nil {
+		return msgpackExt{}, err
+	}
+	data, err := msgpackRead(r, n)
+	return msgpackExt{Type: int8(t), Data: data}, err
+}
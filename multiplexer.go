@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Inside tmux or screen the terminal's foreground process is just the
+// multiplexer client. These helpers ask the multiplexer which pane or
+// window the client is showing and return the process running there.
+
+const multiplexerQueryTimeout = time.Second
+
+func runMultiplexer(name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), multiplexerQueryTimeout)
+	defer cancel()
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+// tmuxSocketArgs carries the -L/-S server selection of a tmux client over
+// to our own queries, so private servers are asked rather than the default.
+func tmuxSocketArgs(clientArgs []string) []string {
+	var args []string
+	for i := 1; i < len(clientArgs); i++ {
+		arg := clientArgs[i]
+		switch {
+		case (arg == "-L" || arg == "-S") && i+1 < len(clientArgs):
+			args = append(args, arg, clientArgs[i+1])
+			i++
+		case strings.HasPrefix(arg, "-L") || strings.HasPrefix(arg, "-S"):
+			if len(arg) > 2 {
+				args = append(args, arg[:2], arg[2:])
+			}
+		}
+	}
+	return args
+}
+
+// tmuxActivePane returns the process in the pane a tmux client is showing,
+// with the command and path as tmux reports them.
+func tmuxActivePane(client *procInfo) *procInfo {
+	args := append(tmuxSocketArgs(client.Args), "list-clients", "-F",
+		"#{client_pid}\t#{pane_pid}\t#{pane_current_command}\t#{pane_current_path}")
+	output, err := runMultiplexer("tmux", args...)
+	if err != nil {
+		return nil
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) != 4 || fields[0] != strconv.Itoa(client.PID) {
+			continue
+		}
+		panePID, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil
+		}
+		return paneProcess(panePID, fields[2], fields[3])
+	}
+	return nil
+}
+
+// paneProcess combines what the multiplexer says about a pane with the
+// details /proc has on its foreground process.
+func paneProcess(panePID int, command, path string) *procInfo {
+	proc := foregroundProcess(panePID)
+	if proc == nil {
+		proc, _ = readProc(panePID)
+	}
+	if proc == nil {
+		proc = &procInfo{PID: panePID}
+	}
+	if command != "" && proc.Comm != command {
+		// The process we found isn't the one the multiplexer sees, so its
+		// arguments would be misleading
+		proc.Comm = command
+		proc.Args = nil
+	}
+	if path != "" {
+		proc.Cwd = path
+	}
+	return proc
+}
+
+// screenActiveWindow returns the process in the window an attached GNU
+// screen client is showing. Screen has no client listing, so the session
+// comes from the client's -r/-x argument or is the only attached one.
+func screenActiveWindow(client *procInfo) *procInfo {
+	output, _ := runMultiplexer("screen", "-ls")
+
+	wanted := ""
+	for i, arg := range client.Args {
+		if (arg == "-r" || arg == "-x" || arg == "-S") && i+1 < len(client.Args) {
+			wanted = client.Args[i+1]
+		}
+	}
+
+	// Lines look like "\t12345.pts-0.host\t(Attached)"
+	session := ""
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.Contains(line, "(Attached)") {
+			continue
+		}
+		name := fields[0]
+		if wanted != "" && name != wanted && !strings.HasSuffix(name, "."+wanted) && !strings.HasPrefix(name, wanted+".") {
+			continue
+		}
+		if session != "" && wanted == "" {
+			// Several attached sessions and nothing to tell them apart
+			return nil
+		}
+		session = name
+	}
+	if session == "" {
+		return nil
+	}
+
+	serverPID, err := strconv.Atoi(strings.SplitN(session, ".", 2)[0])
+	if err != nil {
+		return nil
+	}
+
+	// Prints the current window as "<number> <title>"
+	output, err = runMultiplexer("screen", "-S", session, "-Q", "number")
+	if err != nil {
+		return nil
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return nil
+	}
+	window := fields[0]
+
+	// Screen tells each window's shell its number through $WINDOW
+	for _, child := range procChildren(serverPID) {
+		if procEnv(child, "WINDOW") == window {
+			return paneProcess(child, "", "")
+		}
+	}
+	return nil
+}
+
+// procEnv reads one variable from a process's initial environment.
+func procEnv(pid int, name string) string {
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "environ"))
+	if err != nil {
+		return ""
+	}
+	prefix := []byte(name + "=")
+	for _, entry := range bytes.Split(data, []byte{0}) {
+		if bytes.HasPrefix(entry, prefix) {
+			return string(entry[len(prefix):])
+		}
+	}
+	return ""
+}
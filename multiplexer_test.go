@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTmuxSocketArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"tmux"}, ""},
+		{[]string{"tmux", "attach", "-t", "work"}, ""},
+		{[]string{"tmux", "-L", "work", "attach"}, "-L work"},
+		{[]string{"tmux", "-Lwork", "new"}, "-L work"},
+		{[]string{"tmux", "-S", "/tmp/sock", "-u", "attach"}, "-S /tmp/sock"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(tmuxSocketArgs(tt.args), " "); got != tt.want {
+			t.Errorf("tmuxSocketArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+// TestTmuxActivePane attaches a client to a private tmux server through
+// script(1), which gives it the terminal it needs, and looks through it.
+func TestTmuxActivePane(t *testing.T) {
+	for _, name := range []string{"tmux", "script"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("%s not found", name)
+		}
+	}
+
+	// Keep the server's socket out of the shared /tmp/tmux-UID
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	socket := fmt.Sprintf("emotional-support-test-%d", os.Getpid())
+	tmux := func(args ...string) (string, error) {
+		output, err := exec.Command("tmux", append([]string{"-L", socket, "-f", "/dev/null"}, args...)...).Output()
+		return strings.TrimSpace(string(output)), err
+	}
+	t.Cleanup(func() { tmux("kill-server") })
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmux("new-session", "-d", "-s", "test", "-c", dir, "sleep 300"); err != nil {
+		t.Skipf("could not start a tmux server: %v", err)
+	}
+
+	client := exec.Command("script", "-qfc", "tmux -L "+socket+" attach -t test", "/dev/null")
+	client.Env = append(os.Environ(), "TERM=xterm")
+	if err := client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Process.Kill()
+		client.Wait()
+	})
+
+	// A client is listed before it's attached to a pane
+	deadline := time.Now().Add(5 * time.Second)
+	var clientPID int
+	for clientPID == 0 {
+		if output, err := tmux("list-clients", "-F", "#{client_pid} #{pane_pid}"); err == nil {
+			if fields := strings.Fields(output); len(fields) >= 2 {
+				clientPID, _ = strconv.Atoi(fields[0])
+			}
+		}
+		if clientPID == 0 {
+			if time.Now().After(deadline) {
+				t.Skip("the tmux client never attached")
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	proc, err := readProc(clientPID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Even then, the server can be slow to answer for a new client
+	var pane *procInfo
+	for pane == nil {
+		if pane = tmuxActivePane(proc); pane == nil {
+			if time.Now().After(deadline) {
+				t.Skip("tmuxActivePane() never resolved the client's pane")
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	if pane.Comm != "sleep" || pane.Cwd != dir {
+		t.Errorf("pane runs %q in %q, want sleep in %q", pane.Comm, pane.Cwd, dir)
+	}
+}
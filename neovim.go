@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const neovimTimeout = 500 * time.Millisecond
+
+// NeovimState is what a running Neovim says it's doing.
+type NeovimState struct {
+	File     string // Full path of the current buffer, empty if unnamed
+	FileType string // &filetype
+	BufType  string // &buftype; empty for normal file buffers
+	Cwd      string // getcwd() for the current window
+}
+
+// Evaluated in one round trip. Lists come back as msgpack arrays.
+const neovimStateExpr = `[expand('%:p'), &filetype, &buftype, getcwd()]`
+
+// neovimProcess finds the Neovim server behind a window's process: the
+// process itself, or the child a GUI like neovide or nvim-qt started.
+// Newer versions run the editor as a child of the TUI process, and it's
+// the editor that listens.
+func neovimProcess(pid int) *procInfo {
+	var found *procInfo
+	if proc, err := readProc(pid); err == nil && proc.Comm == "nvim" {
+		found = proc
+	} else if found = childNamed(pid, "nvim"); found == nil {
+		return nil
+	}
+	if server := childNamed(found.PID, "nvim"); server != nil {
+		return server
+	}
+	return found
+}
+
+func childNamed(pid int, comm string) *procInfo {
+	for _, child := range procChildren(pid) {
+		if proc, err := readProc(child); err == nil && proc.Comm == comm {
+			return proc
+		}
+	}
+	return nil
+}
+
+// neovimSockets lists where the Neovim process may be listening: an
+// explicit --listen address, $NVIM_LISTEN_ADDRESS, or the default
+// v:servername under $XDG_RUNTIME_DIR (or the temp directory without one).
+// Processes started inside Neovim see the same address as $NVIM.
+func neovimSockets(proc *procInfo) []string {
+	var sockets []string
+	for i, arg := range proc.Args {
+		if arg == "--listen" && i+1 < len(proc.Args) {
+			sockets = append(sockets, proc.Args[i+1])
+		}
+	}
+	if addr := procEnv(proc.PID, "NVIM_LISTEN_ADDRESS"); addr != "" {
+		sockets = append(sockets, addr)
+	}
+
+	runtimeDir := procEnv(proc.PID, "XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = os.Getenv("XDG_RUNTIME_DIR")
+	}
+	pattern := "nvim." + strconv.Itoa(proc.PID) + ".*"
+	if runtimeDir != "" {
+		matches, _ := filepath.Glob(filepath.Join(runtimeDir, pattern))
+		sockets = append(sockets, matches...)
+	}
+	matches, _ := filepath.Glob(filepath.Join(os.TempDir(), "nvim.*", "*", pattern))
+	return append(sockets, matches...)
+}
+
+// queryNeovim asks a Neovim process for its current buffer over
+// msgpack-RPC.
+func queryNeovim(proc *procInfo) (*NeovimState, error) {
+	sockets := neovimSockets(proc)
+	if len(sockets) == 0 {
+		return nil, fmt.Errorf("no RPC socket found for nvim pid %d", proc.PID)
+	}
+
+	var lastErr error
+	for _, socket := range sockets {
+		result, err := neovimCall(socket, "nvim_eval", neovimStateExpr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		values, ok := result.([]interface{})
+		if !ok || len(values) != 4 {
+			return nil, fmt.Errorf("unexpected nvim_eval result: %v", result)
+		}
+		str := func(v interface{}) string {
+			s, _ := v.(string)
+			return s
+		}
+		return &NeovimState{
+			File:     str(values[0]),
+			FileType: str(values[1]),
+			BufType:  str(values[2]),
+			Cwd:      str(values[3]),
+		}, nil
+	}
+	return nil, lastErr
+}
+
+// neovimCall makes one RPC request on a fresh connection. Addresses
+// with a port are TCP, anything else a Unix socket.
+func neovimCall(address, method string, args ...interface{}) (interface{}, error) {
+	network := "unix"
+	if _, port, err := net.SplitHostPort(address); err == nil && port != "" && !strings.Contains(address, "/") {
+		network = "tcp"
+	}
+	conn, err := net.DialTimeout(network, address, neovimTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nvim: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(neovimTimeout))
+
+	const msgid = 1
+	request, err := msgpackAppend(nil, []interface{}{0, msgid, method, args})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(request); err != nil {
+		return nil, fmt.Errorf("failed to send nvim request: %w", err)
+	}
+
+	r := bufio.NewReader(conn)
+	for {
+		message, err := msgpackDecode(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read nvim response: %w", err)
+		}
+		// [1, msgid, error, result]; notifications ([2, ...]) are skipped
+		response, ok := message.([]interface{})
+		if !ok || len(response) != 4 || response[0] != int64(1) || response[1] != int64(msgid) {
+			continue
+		}
+		if response[2] != nil {
+			return nil, fmt.Errorf("nvim error: %v", neovimErrorMessage(response[2]))
+		}
+		return response[3], nil
+	}
+}
+
+// Errors come back as [type, message]
+func neovimErrorMessage(v interface{}) interface{} {
+	if e, ok := v.([]interface{}); ok && len(e) == 2 {
+		return e[1]
+	}
+	return v
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestNeovimCallFakeServer answers one request the way Neovim does, after
+// a notification the client has to skip.
+func TestNeovimCallFakeServer(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "nvim.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	requests := make(chan []interface{}, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		request, err := msgpackDecode(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		requests <- request.([]interface{})
+		notification, _ := msgpackAppend(nil, []interface{}{2, "nvim_buf_changedtick_event", []interface{}{}})
+		response, _ := msgpackAppend(notification, []interface{}{1, 1, nil, []interface{}{"/src/main.go", "go", "", "/src"}})
+		conn.Write(response)
+	}()
+
+	state, err := queryNeovim(&procInfo{PID: os.Getpid(), Args: []string{"nvim", "--listen", socket}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NeovimState{File: "/src/main.go", FileType: "go", Cwd: "/src"}
+	if *state != want {
+		t.Errorf("queryNeovim() = %+v, want %+v", *state, want)
+	}
+
+	request := <-requests
+	if len(request) != 4 || request[0] != int64(0) || request[2] != "nvim_eval" {
+		t.Errorf("request = %v, want an nvim_eval request", request)
+	}
+}
+
+func TestNeovimCallError(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "nvim.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		msgpackDecode(bufio.NewReader(conn))
+		response, _ := msgpackAppend(nil, []interface{}{1, 1, []interface{}{0, "Vim:E15: Invalid expression"}, nil})
+		conn.Write(response)
+	}()
+
+	if _, err := neovimCall(socket, "nvim_eval", "["); err == nil {
+		t.Error("neovimCall() succeeded on an error response")
+	}
+}
+
+// TestQueryNeovimEmbedded asks a real headless Neovim about the file it
+// has open.
+func TestQueryNeovimEmbedded(t *testing.T) {
+	path, err := exec.LookPath("nvim")
+	if err != nil {
+		t.Skip("nvim not found")
+	}
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(file, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(dir, "nvim.sock")
+
+	// --embed keeps Neovim on the pipe rather than a terminal, and
+	// --headless stops it waiting for a UI to attach
+	cmd := exec.Command(path, "--embed", "--headless", "--clean", "-n", "--listen", socket, file)
+	cmd.Dir = dir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		stdin.Close()
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	proc := &procInfo{PID: cmd.Process.Pid, Args: cmd.Args}
+	var state *NeovimState
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if state, err = queryNeovim(proc); err == nil && state.File != "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Neovim never answered: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if state.File != file || state.FileType != "go" || state.BufType != "" || state.Cwd != dir {
+		t.Errorf("queryNeovim() = %+v, want %s with filetype go in %s", *state, file, dir)
+	}
+}
@@ -1,30 +1,149 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
 	"github.com/godbus/dbus/v5"
 )
 
-type Notifier struct {
+// Notification is a message for the user, whatever ends up showing it.
+type Notification struct {
+	Type    string // time_based, language, health, welcome, summary or wrap_up
+	Title   string
+	Message string
+	Icon    string // Icon name or path, may be empty
+
+	// Presentation hints, for notifiers that understand them
+	Urgency   Urgency
+	Category  string // e.g. "x-emotional-support.health"
+	Sound     string // Name from the freedesktop sound theme
+	Transient bool   // Skip the notification history
+
+	// Buttons, for notifiers that can show them
+	Actions []NotificationAction
+
+	// Row in the notifications table, once logged
+	LogID int64
+}
+
+// Urgency levels, as in the desktop notification spec
+type Urgency byte
+
+const (
+	UrgencyLow Urgency = iota
+	UrgencyNormal
+	UrgencyCritical
+)
+
+// NotificationAction is a button on a notification. The key comes back in
+// the response when it's pressed.
+type NotificationAction struct {
+	Key   string
+	Label string
+}
+
+// NotificationResponse is what the user did with a notification: pressed
+// one of its buttons, or let it go.
+type NotificationResponse struct {
+	Notification *Notification
+	Action       string // An action key, or "expired", "dismissed" or "closed"
+	At           time.Time
+}
+
+// Notifier delivers notifications: as desktop popups, to a log, a file or
+// the terminal.
+type Notifier interface {
+	Send(n *Notification) error
+}
+
+// ResponseWatcher is implemented by notifiers that hear back about their
+// notifications. The returned channel is closed when the source goes away
+// or done is closed.
+type ResponseWatcher interface {
+	WatchResponses(done <-chan struct{}) (<-chan NotificationResponse, error)
+}
+
+// NewNotifier builds the notifiers named in the config, fanning out to all
+// of them when there's more than one.
+func NewNotifier(config *NotificationsConfig) (Notifier, error) {
+	var notifiers []Notifier
+	for _, backend := range config.Backends {
+		switch backend.Type {
+		case "dbus":
+			notifiers = append(notifiers, NewDBusNotifier())
+		case "log":
+			notifiers = append(notifiers, NewLogNotifier(log.Default()))
+		case "stdout":
+			notifiers = append(notifiers, NewLogNotifier(log.New(os.Stdout, "", log.LstdFlags)))
+		case "jsonl":
+			path := backend.Path
+			if path == "" {
+				dir, err := getStateDir()
+				if err != nil {
+					return nil, fmt.Errorf("failed to get state directory: %w", err)
+				}
+				path = filepath.Join(dir, "notifications.jsonl")
+			}
+			notifiers = append(notifiers, NewFileNotifier(path))
+		case "terminal":
+			notifiers = append(notifiers, NewTerminalNotifier(backend.TTY, backend.Format))
+		default:
+			return nil, fmt.Errorf("unknown notifier %q", backend.Type)
+		}
+	}
+
+	switch len(notifiers) {
+	case 0:
+		return nil, errors.New("no notifiers configured")
+	case 1:
+		return notifiers[0], nil
+	}
+	return NewMultiNotifier(notifiers...), nil
+}
+
+// DBusNotifier shows popups through org.freedesktop.Notifications. While
+// responses are watched, a notification replaces the previous one of its
+// type if that's still on screen, so reminders don't stack up.
+type DBusNotifier struct {
 	conn           *dbus.Conn
 	timeoutSeconds int32 // Notification timeout in seconds (0 = server default, -1 = never expire)
+
+	// Notifications still on screen, by the server's ID, so responses
+	// can be matched up. Only NotificationClosed says when they're gone,
+	// so they're only kept while it's being listened for.
+	mu       sync.Mutex
+	watching bool
+	open     map[uint32]*Notification
+	latest   map[string]uint32 // ID of the last notification of each type
+
+	// What the server said about itself, and its last do-not-disturb state
+	serverInfo *notificationServer
+	dndChecked time.Time
+	dnd        bool
 }
 
-func NewNotifier() *Notifier {
+func NewDBusNotifier() *DBusNotifier {
 	conn, err := dbus.SessionBus()
 	if err != nil {
 		// Return a notifier that will fail gracefully
-		return &Notifier{conn: nil, timeoutSeconds: 5}
+		return &DBusNotifier{conn: nil, timeoutSeconds: 5, open: make(map[uint32]*Notification), latest: make(map[string]uint32)}
 	}
-	return &Notifier{conn: conn, timeoutSeconds: 15} // Default: 5 seconds
+	return &DBusNotifier{conn: conn, timeoutSeconds: 15, open: make(map[uint32]*Notification), latest: make(map[string]uint32)} // Default: 5 seconds
 }
 
 // SetTimeout sets the notification timeout in seconds
 // 0 = use server default, -1 = never expire
-func (n *Notifier) SetTimeout(seconds int32) {
+func (n *DBusNotifier) SetTimeout(seconds int32) {
 	n.timeoutSeconds = seconds
 }
 
-func (n *Notifier) Send(title, message, iconPath string) error {
+func (n *DBusNotifier) Send(notification *Notification) error {
 	if n.conn == nil {
 		// Try to reconnect
 		conn, err := dbus.SessionBus()
@@ -34,18 +153,174 @@ func (n *Notifier) Send(title, message, iconPath string) error {
 		n.conn = conn
 	}
 
+	// Actions are a flat list of key and label pairs. Servers without
+	// buttons may show them as text, or not at all.
+	actions := []string{}
+	if n.supports("actions") {
+		for _, action := range notification.Actions {
+			actions = append(actions, action.Key, action.Label)
+		}
+	}
+
+	hints := map[string]dbus.Variant{
+		"urgency":       dbus.MakeVariant(byte(notification.Urgency)),
+		"desktop-entry": dbus.MakeVariant("emotional-support"),
+	}
+	if notification.Category != "" {
+		hints["category"] = dbus.MakeVariant(notification.Category)
+	}
+	if notification.Sound != "" {
+		hints["sound-name"] = dbus.MakeVariant(notification.Sound)
+	}
+	if notification.Transient {
+		hints["transient"] = dbus.MakeVariant(true)
+	}
+
+	// Only replace a notification that's still showing; the server would
+	// otherwise be free to reuse its ID for something else
+	var replacesID uint32
+	n.mu.Lock()
+	if id, ok := n.latest[notification.Type]; ok && n.watching && n.open[id] != nil {
+		replacesID = id
+	}
+	n.mu.Unlock()
+
 	obj := n.conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
 	// expire_timeout: milliseconds (0 = server default, -1 = never expire)
 	expireTimeout := n.timeoutSeconds * 1000
 	call := obj.Call("org.freedesktop.Notifications.Notify", 0,
-		"Emotional Support",       // app_name
-		uint32(0),                 // replaces_id
-		iconPath,                  // app_icon
-		title,                     // summary
-		message,                   // body
-		[]string{},                // actions
-		map[string]dbus.Variant{}, // hints
-		expireTimeout)             // expire_timeout in ms
-
-	return call.Err
+		"Emotional Support",  // app_name
+		replacesID,           // replaces_id
+		notification.Icon,    // app_icon
+		notification.Title,   // summary
+		notification.Message, // body
+		actions,              // actions
+		hints,                // hints
+		expireTimeout)        // expire_timeout in ms
+
+	var id uint32
+	if err := call.Store(&id); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	if n.watching {
+		delete(n.open, replacesID)
+		n.open[id] = notification
+		if notification.Type != "" {
+			n.latest[notification.Type] = id
+		}
+	}
+	n.mu.Unlock()
+	return nil
+}
+
+// Reasons given by NotificationClosed
+var notificationCloseReasons = map[uint32]string{
+	1: "expired",
+	2: "dismissed",
+	3: "closed",
+}
+
+// WatchResponses subscribes to the ActionInvoked and NotificationClosed
+// signals for our notifications. A button press is followed by the
+// notification closing, which is reported too.
+func (n *DBusNotifier) WatchResponses(done <-chan struct{}) (<-chan NotificationResponse, error) {
+	if n.conn == nil {
+		conn, err := dbus.SessionBus()
+		if err != nil {
+			return nil, err
+		}
+		n.conn = conn
+	}
+
+	for _, member := range []string{"ActionInvoked", "NotificationClosed"} {
+		err := n.conn.AddMatchSignal(
+			dbus.WithMatchInterface("org.freedesktop.Notifications"),
+			dbus.WithMatchMember(member),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to subscribe to %s: %w", member, err)
+		}
+	}
+	signals := make(chan *dbus.Signal, 16)
+	n.conn.Signal(signals)
+	n.mu.Lock()
+	n.watching = true
+	n.mu.Unlock()
+
+	responses := make(chan NotificationResponse)
+	go func() {
+		defer close(responses)
+		defer n.conn.RemoveSignal(signals)
+		defer n.stopWatching()
+
+		for {
+			select {
+			case <-done:
+				return
+			case signal, ok := <-signals:
+				if !ok {
+					return
+				}
+				response, ok := n.responseFromSignal(signal)
+				if !ok {
+					continue
+				}
+				select {
+				case responses <- response:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+
+	return responses, nil
+}
+
+// stopWatching forgets the open notifications, whose closing would no
+// longer be heard about.
+func (n *DBusNotifier) stopWatching() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.watching = false
+	n.open = make(map[uint32]*Notification)
+	n.latest = make(map[string]uint32)
+}
+
+// responseFromSignal matches a signal to one of our notifications. Other
+// programs' notifications share the signals, and other subscriptions on
+// the session bus connection share the channel.
+func (n *DBusNotifier) responseFromSignal(signal *dbus.Signal) (NotificationResponse, bool) {
+	if len(signal.Body) != 2 {
+		return NotificationResponse{}, false
+	}
+	id, ok := signal.Body[0].(uint32)
+	if !ok {
+		return NotificationResponse{}, false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	notification, ok := n.open[id]
+	if !ok {
+		return NotificationResponse{}, false
+	}
+
+	response := NotificationResponse{Notification: notification, At: time.Now()}
+	switch signal.Name {
+	case "org.freedesktop.Notifications.ActionInvoked":
+		if response.Action, ok = signal.Body[1].(string); !ok {
+			return NotificationResponse{}, false
+		}
+	case "org.freedesktop.Notifications.NotificationClosed":
+		reason, _ := signal.Body[1].(uint32)
+		if response.Action, ok = notificationCloseReasons[reason]; !ok {
+			response.Action = "closed"
+		}
+		delete(n.open, id)
+	default:
+		return NotificationResponse{}, false
+	}
+	return response, true
 }
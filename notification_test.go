@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// privateSessionBus starts a dbus-daemon of the test's own and returns its
+// address, skipping the test when there's no dbus-daemon to run.
+func privateSessionBus(t *testing.T) string {
+	t.Helper()
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+	cmd := exec.Command(path, "--session", "--nofork", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("could not start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("dbus-daemon printed no address: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// notifyCall is a Notify call as the fake server received it.
+type notifyCall struct {
+	ReplacesID uint32
+	Summary    string
+	Body       string
+	Actions    []string
+	Hints      map[string]dbus.Variant
+}
+
+// fakeNotificationServer records the calls made to it, as a notification
+// daemon on the bus would receive them.
+type fakeNotificationServer struct {
+	conn         *dbus.Conn
+	name         string
+	capabilities []string
+
+	mu     sync.Mutex
+	calls  []notifyCall
+	nextID uint32
+}
+
+func (s *fakeNotificationServer) Notify(appName string, replacesID uint32, appIcon, summary, body string, actions []string, hints map[string]dbus.Variant, timeout int32) (uint32, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, notifyCall{replacesID, summary, body, actions, hints})
+	if replacesID != 0 {
+		return replacesID, nil
+	}
+	s.nextID++
+	return s.nextID, nil
+}
+
+func (s *fakeNotificationServer) GetCapabilities() ([]string, *dbus.Error) {
+	return s.capabilities, nil
+}
+
+func (s *fakeNotificationServer) GetServerInformation() (string, string, string, string, *dbus.Error) {
+	return s.name, "test", "1.0", "1.2", nil
+}
+
+func (s *fakeNotificationServer) CloseNotification(id uint32) *dbus.Error {
+	return nil
+}
+
+func (s *fakeNotificationServer) lastCall(t *testing.T) notifyCall {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.calls) == 0 {
+		t.Fatal("no Notify calls")
+	}
+	return s.calls[len(s.calls)-1]
+}
+
+// emit sends one of the server's signals about notification id.
+func (s *fakeNotificationServer) emit(t *testing.T, member string, id uint32, arg interface{}) {
+	t.Helper()
+	if err := s.conn.Emit("/org/freedesktop/Notifications", "org.freedesktop.Notifications."+member, id, arg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestDBusNotifier connects a notifier and a fake server to a private
+// session bus.
+func newTestDBusNotifier(t *testing.T, capabilities ...string) (*DBusNotifier, *fakeNotificationServer) {
+	t.Helper()
+	address := privateSessionBus(t)
+
+	connect := func() *dbus.Conn {
+		conn, err := dbus.Connect(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+
+	server := &fakeNotificationServer{conn: connect(), name: "fake", capabilities: capabilities}
+	if err := server.conn.Export(server, "/org/freedesktop/Notifications", "org.freedesktop.Notifications"); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := server.conn.RequestName("org.freedesktop.Notifications", dbus.NameFlagDoNotQueue); err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("could not own org.freedesktop.Notifications: %v", err)
+	}
+
+	notifier := &DBusNotifier{
+		conn:           connect(),
+		timeoutSeconds: 15,
+		open:           make(map[uint32]*Notification),
+		latest:         make(map[string]uint32),
+	}
+	return notifier, server
+}
+
+func TestDBusNotifierHints(t *testing.T) {
+	notifier, server := newTestDBusNotifier(t, "body", "actions")
+
+	err := notifier.Send(&Notification{
+		Type:      "health",
+		Title:     "Emotional Support",
+		Message:   "Drink some water",
+		Urgency:   UrgencyNormal,
+		Category:  "x-emotional-support.health",
+		Sound:     "bell",
+		Transient: true,
+		Actions:   []NotificationAction{{Key: "dismiss", Label: "Dismiss"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := server.lastCall(t)
+	if call.Summary != "Emotional Support" || call.Body != "Drink some water" {
+		t.Errorf("sent %q: %q", call.Summary, call.Body)
+	}
+	if strings.Join(call.Actions, ",") != "dismiss,Dismiss" {
+		t.Errorf("actions = %v", call.Actions)
+	}
+	want := map[string]interface{}{
+		"urgency":       byte(UrgencyNormal),
+		"category":      "x-emotional-support.health",
+		"sound-name":    "bell",
+		"transient":     true,
+		"desktop-entry": "emotional-support",
+	}
+	for key, value := range want {
+		if got, ok := call.Hints[key]; !ok || got.Value() != value {
+			t.Errorf("hint %s = %v, want %v", key, got, value)
+		}
+	}
+}
+
+func TestDBusNotifierNoActionsWithoutSupport(t *testing.T) {
+	notifier, server := newTestDBusNotifier(t, "body")
+
+	notifier.Send(&Notification{Type: "health", Actions: []NotificationAction{{Key: "dismiss", Label: "Dismiss"}}})
+	if actions := server.lastCall(t).Actions; len(actions) != 0 {
+		t.Errorf("actions sent to a server without buttons: %v", actions)
+	}
+}
+
+func TestDBusNotifierReplacesWhileOpen(t *testing.T) {
+	notifier, server := newTestDBusNotifier(t, "body", "actions")
+
+	done := make(chan struct{})
+	defer close(done)
+	responses, err := notifier.WatchResponses(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifier.Send(&Notification{Type: "health", Message: "one"})
+	first := server.lastCall(t)
+	notifier.Send(&Notification{Type: "health", Message: "two"})
+	if got := server.lastCall(t).ReplacesID; got != 1 {
+		t.Errorf("second health reminder replaces %d, want 1", got)
+	}
+	notifier.Send(&Notification{Type: "language", Message: "three"})
+	if got := server.lastCall(t).ReplacesID; got != 0 {
+		t.Errorf("language message replaces %d, want 0", got)
+	}
+	if first.ReplacesID != 0 {
+		t.Errorf("first notification replaces %d", first.ReplacesID)
+	}
+
+	// A button press, then the notification closing
+	server.emit(t, "ActionInvoked", 1, "snooze:health")
+	server.emit(t, "NotificationClosed", 1, uint32(2))
+	for _, want := range []string{"snooze:health", "dismissed"} {
+		select {
+		case response := <-responses:
+			if response.Action != want || response.Notification.Message != "two" {
+				t.Errorf("response %q to %q, want %q to \"two\"", response.Action, response.Notification.Message, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no %q response", want)
+		}
+	}
+
+	// Once closed it isn't replaced
+	notifier.Send(&Notification{Type: "health", Message: "four"})
+	if got := server.lastCall(t).ReplacesID; got != 0 {
+		t.Errorf("health reminder after closing replaces %d, want 0", got)
+	}
+}
+
+func TestDBusNotifierUnwatched(t *testing.T) {
+	notifier, server := newTestDBusNotifier(t, "body")
+
+	for i := 0; i < 3; i++ {
+		notifier.Send(&Notification{Type: "health", Message: "again"})
+		if got := server.lastCall(t).ReplacesID; got != 0 {
+			t.Errorf("replaces %d without knowing whether it's still open", got)
+		}
+	}
+	if len(notifier.open) != 0 || len(notifier.latest) != 0 {
+		t.Errorf("%d open notifications kept without watching for them closing", len(notifier.open))
+	}
+}
+
+func TestNewNotifier(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		backends []NotifierConfig
+		want     string
+	}{
+		{"log", []NotifierConfig{{Type: "log"}}, "*main.LogNotifier"},
+		{"stdout", []NotifierConfig{{Type: "stdout"}}, "*main.LogNotifier"},
+		{"jsonl", []NotifierConfig{{Type: "jsonl", Path: filepath.Join(dir, "n.jsonl")}}, "*main.FileNotifier"},
+		{"terminal", []NotifierConfig{{Type: "terminal", Format: TerminalOSC9}}, "*main.TerminalNotifier"},
+		{"several", []NotifierConfig{{Type: "log"}, {Type: "terminal"}}, "*main.MultiNotifier"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier, err := NewNotifier(&NotificationsConfig{Backends: tt.backends})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprintf("%T", notifier); got != tt.want {
+				t.Errorf("NewNotifier() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// The terminal defaults to the controlling one and OSC 777
+	notifier, err := NewNotifier(&NotificationsConfig{Backends: []NotifierConfig{{Type: "terminal"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if terminal := notifier.(*TerminalNotifier); terminal.tty != "/dev/tty" || terminal.format != TerminalOSC777 {
+		t.Errorf("terminal notifier %+v, want /dev/tty with OSC 777", terminal)
+	}
+
+	if _, err := NewNotifier(&NotificationsConfig{Backends: []NotifierConfig{{Type: "log"}, {Type: "pager"}}}); err == nil || !strings.Contains(err.Error(), `"pager"`) {
+		t.Errorf("NewNotifier() with an unknown backend = %v, want an error naming it", err)
+	}
+	if _, err := NewNotifier(&NotificationsConfig{}); err == nil {
+		t.Error("NewNotifier() without backends succeeded")
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogNotifier writes notifications as log lines, for headless machines and
+// running in a terminal.
+type LogNotifier struct {
+	logger *log.Logger
+}
+
+func NewLogNotifier(logger *log.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) Send(notification *Notification) error {
+	// One line per notification, with nothing that could upset a terminal
+	n.logger.Printf("[%s] %s: %s", notification.Type, terminalText(notification.Title), terminalText(notification.Message))
+	return nil
+}
+
+// FileNotifier appends each notification to a file as a line of JSON.
+type FileNotifier struct {
+	path string
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+// Send opens the file for every notification, so it can be rotated or
+// removed at any time.
+func (n *FileNotifier) Send(notification *Notification) error {
+	line, err := json.Marshal(struct {
+		Time    time.Time `json:"time"`
+		Type    string    `json:"type"`
+		Title   string    `json:"title"`
+		Message string    `json:"message"`
+		Icon    string    `json:"icon,omitempty"`
+	}{time.Now(), notification.Type, notification.Title, notification.Message, notification.Icon})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(n.path), 0755); err != nil {
+		return fmt.Errorf("failed to create notification log directory: %w", err)
+	}
+	f, err := os.OpenFile(n.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open notification log: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write notification log: %w", err)
+	}
+	return f.Close()
+}
+
+// Escape sequence formats the terminal notifier can write
+const (
+	TerminalBell   = "bell"   // Just ring the bell
+	TerminalOSC9   = "osc9"   // iTerm2, Windows Terminal, ConEmu, kitty, WezTerm
+	TerminalOSC777 = "osc777" // urxvt, foot, Ghostty, VTE-based terminals with the patch
+)
+
+var terminalFormats = map[string]bool{
+	TerminalBell: true, TerminalOSC9: true, TerminalOSC777: true,
+}
+
+// TerminalNotifier rings the bell in a terminal and, if it understands
+// them, asks it to show a desktop notification with an OSC escape sequence.
+type TerminalNotifier struct {
+	tty    string
+	format string
+}
+
+// NewTerminalNotifier writes to tty, the controlling terminal when empty,
+// in one of the terminal formats, OSC 777 when empty.
+func NewTerminalNotifier(tty, format string) *TerminalNotifier {
+	if tty == "" {
+		tty = "/dev/tty"
+	}
+	if format == "" {
+		format = TerminalOSC777
+	}
+	return &TerminalNotifier{tty: tty, format: format}
+}
+
+func (n *TerminalNotifier) Send(notification *Notification) error {
+	title := terminalText(notification.Title)
+	message := terminalText(notification.Message)
+
+	sequence := "\a"
+	switch n.format {
+	case TerminalOSC9:
+		sequence += "\x1b]9;" + title + ": " + message + "\a"
+	case TerminalOSC777:
+		// Fields are separated by semicolons, and the body is the last one
+		sequence += "\x1b]777;notify;" + strings.ReplaceAll(title, ";", ",") + ";" + message + "\a"
+	}
+
+	f, err := os.OpenFile(n.tty, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open terminal: %w", err)
+	}
+	if _, err := f.WriteString(sequence); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write to terminal: %w", err)
+	}
+	return f.Close()
+}
+
+// terminalText keeps control characters, which would end or break the
+// escape sequence, out of the text.
+func terminalText(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			return ' '
+		case r < 0x20 || (r >= 0x7f && r < 0xa0):
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// MultiNotifier sends every notification through several notifiers. It
+// only fails when all of them do.
+type MultiNotifier struct {
+	notifiers []Notifier
+}
+
+func NewMultiNotifier(notifiers ...Notifier) *MultiNotifier {
+	return &MultiNotifier{notifiers: notifiers}
+}
+
+// WatchResponses merges the responses of every notifier that has them.
+func (n *MultiNotifier) WatchResponses(done <-chan struct{}) (<-chan NotificationResponse, error) {
+	var sources []<-chan NotificationResponse
+	var errs []error
+	for _, notifier := range n.notifiers {
+		watcher, ok := notifier.(ResponseWatcher)
+		if !ok {
+			continue
+		}
+		source, err := watcher.WatchResponses(done)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%T: %w", notifier, err))
+			continue
+		}
+		sources = append(sources, source)
+	}
+	if len(sources) == 0 {
+		if len(errs) == 0 {
+			return nil, errors.New("no notifier can report responses")
+		}
+		return nil, errors.Join(errs...)
+	}
+
+	responses := make(chan NotificationResponse)
+	var wg sync.WaitGroup
+	for _, source := range sources {
+		wg.Add(1)
+		go func(source <-chan NotificationResponse) {
+			defer wg.Done()
+			for response := range source {
+				select {
+				case responses <- response:
+				case <-done:
+					return
+				}
+			}
+		}(source)
+	}
+	go func() {
+		wg.Wait()
+		close(responses)
+	}()
+	return responses, nil
+}
+
+func (n *MultiNotifier) Send(notification *Notification) error {
+	var errs []error
+	for _, notifier := range n.notifiers {
+		if err := notifier.Send(notification); err != nil {
+			errs = append(errs, fmt.Errorf("%T: %w", notifier, err))
+		}
+	}
+	if len(errs) == len(n.notifiers) {
+		return errors.Join(errs...)
+	}
+	for _, err := range errs {
+		log.Printf("Warning: Could not send notification: %v", err)
+	}
+	return nil
+}
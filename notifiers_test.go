@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// RecordingNotifier keeps what it's sent in memory, so tests can check
+// what was sent.
+type RecordingNotifier struct {
+	mu   sync.Mutex
+	sent []Notification
+}
+
+func NewRecordingNotifier() *RecordingNotifier {
+	return &RecordingNotifier{}
+}
+
+func (n *RecordingNotifier) Send(notification *Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, *notification)
+	return nil
+}
+
+// Sent returns everything sent so far, oldest first.
+func (n *RecordingNotifier) Sent() []Notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Notification(nil), n.sent...)
+}
+
+func TestLogNotifier(t *testing.T) {
+	var buf bytes.Buffer
+	notifier := NewLogNotifier(log.New(&buf, "", 0))
+	notifier.Send(&Notification{Type: "health", Title: "Emotional\x1b]0;pwned\a", Message: "Drink water\nand stretch"})
+	if want := "[health] Emotional]0;pwned: Drink water and stretch\n"; buf.String() != want {
+		t.Errorf("logged %q, want %q", buf.String(), want)
+	}
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "notifications.jsonl")
+	notifier := NewFileNotifier(path)
+	for _, notification := range []*Notification{
+		{Type: "health", Title: "Emotional Support", Message: "Drink water"},
+		{Type: "summary", Title: "While you were fullscreen", Message: "Stretch\nGo go go", Icon: "face-smile"},
+	} {
+		if err := notifier.Send(notification); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("%d lines, want one per notification:\n%s", len(lines), data)
+	}
+	var got struct {
+		Time    time.Time `json:"time"`
+		Type    string    `json:"type"`
+		Title   string    `json:"title"`
+		Message string    `json:"message"`
+		Icon    string    `json:"icon"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != "summary" || got.Title != "While you were fullscreen" || got.Message != "Stretch\nGo go go" || got.Icon != "face-smile" || got.Time.IsZero() {
+		t.Errorf("second line %+v", got)
+	}
+	if strings.Contains(lines[0], `"icon"`) {
+		t.Errorf("first line %s has an empty icon", lines[0])
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("notification log mode %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+}
+
+func TestTerminalNotifier(t *testing.T) {
+	notification := &Notification{Type: "health", Title: "Break; now", Message: "Drink\x1b]0;x\a water;\tplease\n"}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{TerminalBell, "\a"},
+		{TerminalOSC9, "\a\x1b]9;Break; now: Drink]0;x water; please \a"},
+		{TerminalOSC777, "\a\x1b]777;notify;Break, now;Drink]0;x water; please \a"},
+		{"", "\a\x1b]777;notify;Break, now;Drink]0;x water; please \a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			// A plain file stands in for the terminal
+			tty := filepath.Join(t.TempDir(), "tty")
+			if err := os.WriteFile(tty, nil, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := NewTerminalNotifier(tty, tt.format).Send(notification); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := os.ReadFile(tty); string(got) != tt.want {
+				t.Errorf("wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if err := NewTerminalNotifier(filepath.Join(t.TempDir(), "missing"), "").Send(notification); err == nil {
+		t.Error("Send() to a missing terminal succeeded")
+	}
+}
+
+func TestTerminalText(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain", "plain"},
+		{"two\nlines\tand a tab", "two lines and a tab"},
+		{"bell\a and escape\x1b[31m", "bell and escape[31m"},
+		{"del\x7f and c1\u009c", "del and c1"},
+		{"emoji 💚 stays", "emoji 💚 stays"},
+	}
+	for _, tt := range tests {
+		if got := terminalText(tt.in); got != tt.want {
+			t.Errorf("terminalText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMultiNotifierSend(t *testing.T) {
+	notification := &Notification{Type: "health", Message: "Drink water"}
+
+	recorder := NewRecordingNotifier()
+	if err := NewMultiNotifier(failingNotifier{}, recorder).Send(notification); err != nil {
+		t.Errorf("Send() = %v with one notifier working, want nil", err)
+	}
+	if n := len(recorder.Sent()); n != 1 {
+		t.Errorf("working notifier got %d notifications, want 1", n)
+	}
+
+	err := NewMultiNotifier(failingNotifier{}, failingNotifier{}).Send(notification)
+	if err == nil {
+		t.Fatal("Send() = nil with every notifier failing")
+	}
+	if lines := strings.Split(err.Error(), "\n"); len(lines) != 2 || !strings.Contains(lines[1], "no notification server") {
+		t.Errorf("Send() = %q, want both errors", err)
+	}
+}
+
+// watchingNotifier hands back responses the test sends it.
+type watchingNotifier struct {
+	RecordingNotifier
+	responses chan NotificationResponse
+	err       error
+}
+
+func (n *watchingNotifier) WatchResponses(done <-chan struct{}) (<-chan NotificationResponse, error) {
+	return n.responses, n.err
+}
+
+func TestMultiNotifierWatchResponses(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	if _, err := NewMultiNotifier(NewRecordingNotifier()).WatchResponses(done); err == nil {
+		t.Error("WatchResponses() without a watcher succeeded")
+	}
+	broken := &watchingNotifier{err: errors.New("no session bus")}
+	if _, err := NewMultiNotifier(broken).WatchResponses(done); err == nil || !strings.Contains(err.Error(), "no session bus") {
+		t.Errorf("WatchResponses() = %v, want the watcher's error", err)
+	}
+
+	first := &watchingNotifier{responses: make(chan NotificationResponse)}
+	second := &watchingNotifier{responses: make(chan NotificationResponse)}
+	responses, err := NewMultiNotifier(first, broken, NewRecordingNotifier(), second).WatchResponses(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		first.responses <- NotificationResponse{Action: "dismiss"}
+		close(first.responses)
+		second.responses <- NotificationResponse{Action: "break"}
+		close(second.responses)
+	}()
+
+	var actions []string
+	for response := range responses {
+		actions = append(actions, response.Action)
+	}
+	if got := strings.Join(actions, ","); got != "dismiss,break" {
+		t.Errorf("responses %s, want dismiss,break", got)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procInfo is a snapshot of a process as seen through /proc.
+type procInfo struct {
+	PID       int
+	Comm      string
+	Args      []string
+	Cwd       string
+	PGRP      int
+	TTY       int
+	TPGID     int
+	StartTime uint64
+}
+
+// procRoot is where procfs is mounted, swapped out by tests.
+var procRoot = "/proc"
+
+// readProc gathers what we need to know about a process. The cwd may be
+// empty for processes we aren't allowed to inspect.
+func readProc(pid int) (*procInfo, error) {
+	dir := filepath.Join(procRoot, strconv.Itoa(pid))
+
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	// The command name is wrapped in parentheses and may itself contain
+	// spaces or parentheses, so split on the last closing one
+	open := bytes.IndexByte(stat, '(')
+	end := bytes.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return nil, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 20 {
+		return nil, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+
+	info := &procInfo{
+		PID:  pid,
+		Comm: string(stat[open+1 : end]),
+	}
+	// Fields after the name: state ppid pgrp session tty_nr tpgid ... starttime
+	info.PGRP, _ = strconv.Atoi(fields[2])
+	info.TTY, _ = strconv.Atoi(fields[4])
+	info.TPGID, _ = strconv.Atoi(fields[5])
+	info.StartTime, _ = strconv.ParseUint(fields[19], 10, 64)
+
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		for _, arg := range bytes.Split(bytes.TrimRight(cmdline, "\x00"), []byte{0}) {
+			info.Args = append(info.Args, string(arg))
+		}
+	}
+	info.Cwd, _ = os.Readlink(filepath.Join(dir, "cwd"))
+
+	return info, nil
+}
+
+// procChildren lists the direct children of a process across all of its
+// threads.
+func procChildren(pid int) []int {
+	tasks, err := filepath.Glob(filepath.Join(procRoot, strconv.Itoa(pid), "task", "*", "children"))
+	if err != nil {
+		return nil
+	}
+
+	var children []int
+	for _, task := range tasks {
+		data, err := os.ReadFile(task)
+		if err != nil {
+			continue
+		}
+		for _, field := range strings.Fields(string(data)) {
+			if child, err := strconv.Atoi(field); err == nil {
+				children = append(children, child)
+			}
+		}
+	}
+	return children
+}
+
+// Shells are only interesting when nothing else is running in the terminal
+var shellNames = map[string]bool{
+	"bash": true, "zsh": true, "fish": true, "sh": true, "dash": true,
+	"ksh": true, "tcsh": true, "csh": true, "nu": true, "elvish": true, "xonsh": true,
+}
+
+// foregroundProcess finds what the user is running inside a terminal
+// emulator. Every tty under the terminal has a foreground process group,
+// and its leader is what's in front of the user on that tab; the others in
+// the group are its children or the rest of a pipeline. With several tabs
+// we can't tell which is visible, so the most recently started leader
+// wins. Without a leader, say when it has exited and left the rest of a
+// pipeline running, the most recently started member wins, and a shell
+// only when nothing else is running.
+func foregroundProcess(terminalPID int) *procInfo {
+	var best *procInfo
+
+	queue := procChildren(terminalPID)
+	seen := make(map[int]bool)
+	for len(queue) > 0 && len(seen) < 512 {
+		pid := queue[0]
+		queue = queue[1:]
+		if seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		queue = append(queue, procChildren(pid)...)
+
+		info, err := readProc(pid)
+		if err != nil || info.TTY == 0 || info.PGRP != info.TPGID {
+			continue
+		}
+		if best == nil || foregroundRank(info) > foregroundRank(best) ||
+			foregroundRank(info) == foregroundRank(best) && info.StartTime > best.StartTime {
+			best = info
+		}
+	}
+	return best
+}
+
+// foregroundRank orders members of a foreground process group: the group
+// leader, then anything else, then shells.
+func foregroundRank(info *procInfo) int {
+	switch {
+	case shellNames[strings.TrimPrefix(info.Comm, "-")]:
+		return 0
+	case info.PID == info.PGRP:
+		return 2
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeProc is a process to lay out under a fake /proc.
+type fakeProc struct {
+	pid, pgrp, tpgid int
+	comm             string
+	start            uint64
+	children         []int
+}
+
+// withFakeProc points procRoot at a directory holding the given processes,
+// all on the same tty, until the test ends.
+func withFakeProc(t *testing.T, procs []fakeProc) {
+	t.Helper()
+	root := t.TempDir()
+	for _, p := range procs {
+		dir := filepath.Join(root, strconv.Itoa(p.pid))
+		task := filepath.Join(dir, "task", strconv.Itoa(p.pid))
+		if err := os.MkdirAll(task, 0755); err != nil {
+			t.Fatal(err)
+		}
+		// pid (comm) state ppid pgrp session tty_nr tpgid, then starttime 20 fields on
+		stat := fmt.Sprintf("%d (%s) S 1 %d %d 34817 %d%s %d 0\n",
+			p.pid, p.comm, p.pgrp, p.pgrp, p.tpgid, strings.Repeat(" 0", 13), p.start)
+		var children []string
+		for _, child := range p.children {
+			children = append(children, strconv.Itoa(child))
+		}
+		files := map[string]string{
+			filepath.Join(dir, "stat"):      stat,
+			filepath.Join(dir, "cmdline"):   p.comm + "\x00",
+			filepath.Join(task, "children"): strings.Join(children, " "),
+		}
+		for name, content := range files {
+			if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	old := procRoot
+	procRoot = root
+	t.Cleanup(func() { procRoot = old })
+}
+
+func TestReadProc(t *testing.T) {
+	withFakeProc(t, []fakeProc{{pid: 200, pgrp: 200, tpgid: 200, comm: "tmux: client (1) ", start: 4242}})
+
+	info, err := readProc(200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Comm != "tmux: client (1) " || info.PGRP != 200 || info.TPGID != 200 || info.TTY != 34817 || info.StartTime != 4242 {
+		t.Errorf("readProc() = %+v", info)
+	}
+	if _, err := readProc(201); err == nil {
+		t.Error("readProc() of a missing process succeeded")
+	}
+}
+
+func TestForegroundProcess(t *testing.T) {
+	tests := []struct {
+		name  string
+		procs []fakeProc
+		want  int // 0 for none
+	}{
+		{
+			"shell at the prompt",
+			[]fakeProc{
+				{pid: 100, comm: "kitty", children: []int{101}},
+				{pid: 101, pgrp: 101, tpgid: 101, comm: "bash", start: 10},
+			},
+			101,
+		},
+		{
+			"login shell",
+			[]fakeProc{
+				{pid: 100, comm: "kitty", children: []int{101}},
+				{pid: 101, pgrp: 101, tpgid: 101, comm: "-zsh", start: 10},
+			},
+			101,
+		},
+		{
+			// The language server starts after the editor but isn't what's in front
+			"editor with a child",
+			[]fakeProc{
+				{pid: 100, comm: "kitty", children: []int{101}},
+				{pid: 101, pgrp: 101, tpgid: 102, comm: "bash", start: 10, children: []int{102}},
+				{pid: 102, pgrp: 102, tpgid: 102, comm: "nvim", start: 20, children: []int{103}},
+				{pid: 103, pgrp: 102, tpgid: 102, comm: "gopls", start: 30},
+			},
+			102,
+		},
+		{
+			"pipeline",
+			[]fakeProc{
+				{pid: 100, comm: "kitty", children: []int{101}},
+				{pid: 101, pgrp: 101, tpgid: 102, comm: "bash", start: 10, children: []int{102, 103}},
+				{pid: 102, pgrp: 102, tpgid: 102, comm: "git", start: 20},
+				{pid: 103, pgrp: 102, tpgid: 102, comm: "less", start: 21},
+			},
+			102,
+		},
+		{
+			"leader gone",
+			[]fakeProc{
+				{pid: 100, comm: "kitty", children: []int{101}},
+				{pid: 101, pgrp: 101, tpgid: 102, comm: "bash", start: 10, children: []int{103, 104}},
+				{pid: 103, pgrp: 102, tpgid: 102, comm: "grep", start: 21},
+				{pid: 104, pgrp: 102, tpgid: 102, comm: "less", start: 22},
+			},
+			104,
+		},
+		{
+			"latest tab",
+			[]fakeProc{
+				{pid: 100, comm: "kitty", children: []int{101, 111}},
+				{pid: 101, pgrp: 101, tpgid: 102, comm: "bash", start: 10, children: []int{102}},
+				{pid: 102, pgrp: 102, tpgid: 102, comm: "nvim", start: 20, children: []int{103}},
+				{pid: 103, pgrp: 102, tpgid: 102, comm: "gopls", start: 40},
+				{pid: 111, pgrp: 111, tpgid: 112, comm: "bash", start: 11, children: []int{112}},
+				{pid: 112, pgrp: 112, tpgid: 112, comm: "htop", start: 30},
+			},
+			112,
+		},
+		{
+			"background job",
+			[]fakeProc{
+				{pid: 100, comm: "kitty", children: []int{101}},
+				{pid: 101, pgrp: 101, tpgid: 101, comm: "bash", start: 10, children: []int{102}},
+				{pid: 102, pgrp: 102, tpgid: 101, comm: "make", start: 20},
+			},
+			101,
+		},
+		{
+			"nothing on a tty",
+			[]fakeProc{{pid: 100, comm: "kitty"}},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withFakeProc(t, tt.procs)
+			got := foregroundProcess(100)
+			if tt.want == 0 {
+				if got != nil {
+					t.Errorf("foregroundProcess() = %d %s, want none", got.PID, got.Comm)
+				}
+				return
+			}
+			if got == nil || got.PID != tt.want {
+				t.Errorf("foregroundProcess() = %+v, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	scanRecheckInterval = 2 * time.Minute // How often a cached scan is checked against the tree
+	scanMaxFiles        = 100000          // Stop tallying huge trees here
+	scanMaxFileSize     = 1 << 20         // Bigger files are assumed to be generated
+	scanQueueSize       = 16
+
+	secondaryLanguageShare = 0.1 // Smallest share of a project reported as secondary
+	maxSecondaryLanguages  = 3
+)
+
+// Prose and data formats that would swamp the code in a byte count
+var nonCodeLanguages = map[string]bool{
+	"markdown": true, "json": true, "yaml": true, "toml": true,
+}
+
+// LanguageShare is how much of a project is written in one language.
+type LanguageShare struct {
+	Language string
+	Bytes    int64
+	Share    float64 // Fraction of the project's code, 0 to 1
+}
+
+type projectScan struct {
+	languages []LanguageShare      // Largest first
+	dirs      map[string]time.Time // Modification times of every directory walked
+	checkedAt time.Time
+	pending   bool
+}
+
+// ProjectScanner tallies the languages of whole repositories in the
+// background, so the detector never blocks on a walk. Results are cached
+// per root and rescanned when a directory in the tree changes.
+type ProjectScanner struct {
+	languageForFile func(name string) string
+
+	mu    sync.Mutex
+	scans map[string]*projectScan
+	queue chan string
+	start sync.Once
+}
+
+func NewProjectScanner(languageForFile func(name string) string) *ProjectScanner {
+	return &ProjectScanner{
+		languageForFile: languageForFile,
+		scans:           make(map[string]*projectScan),
+		queue:           make(chan string, scanQueueSize),
+	}
+}
+
+// Composition returns the languages of the repository at root, largest
+// first. The first call for a root queues a scan and returns nil; later
+// calls return the cached result and queue a recheck now and then.
+func (ps *ProjectScanner) Composition(root string) []LanguageShare {
+	ps.start.Do(func() { go ps.run() })
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	scan, ok := ps.scans[root]
+	if !ok {
+		scan = &projectScan{}
+		ps.scans[root] = scan
+	}
+	if !scan.pending && time.Since(scan.checkedAt) >= scanRecheckInterval {
+		select {
+		case ps.queue <- root:
+			scan.pending = true
+		default:
+			// Busy; try again on a later call
+		}
+	}
+	return scan.languages
+}
+
+func (ps *ProjectScanner) run() {
+	for root := range ps.queue {
+		ps.mu.Lock()
+		dirs := ps.scans[root].dirs
+		ps.mu.Unlock()
+
+		var languages []LanguageShare
+		if dirs != nil && !treeChanged(dirs) {
+			dirs = nil
+		} else {
+			languages, dirs = ps.scan(root)
+		}
+
+		ps.mu.Lock()
+		scan := ps.scans[root]
+		if dirs != nil {
+			scan.languages = languages
+			scan.dirs = dirs
+		}
+		scan.checkedAt = time.Now()
+		scan.pending = false
+		ps.mu.Unlock()
+	}
+}
+
+// treeChanged checks whether any directory has gained, lost or renamed an
+// entry since it was scanned. Edits to existing files are too small to
+// shift a project's languages and aren't worth a walk to find.
+func treeChanged(dirs map[string]time.Time) bool {
+	for dir, modTime := range dirs {
+		info, err := os.Stat(dir)
+		if err != nil || !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// scan walks the repository, skipping whatever git ignores, and tallies
+// the bytes of each language's files.
+func (ps *ProjectScanner) scan(root string) ([]LanguageShare, map[string]time.Time) {
+	bytes := make(map[string]int64)
+	dirs := make(map[string]time.Time)
+	rules := map[string][]ignoreRule{".": repoIgnoreRules(root)}
+	files := 0
+
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return fs.SkipDir
+			}
+			parent := rules[filepath.ToSlash(filepath.Dir(rel))]
+			if rel != "." {
+				if ignored(parent, rel, true) {
+					return fs.SkipDir
+				}
+				// Copy so sibling directories don't share appended rules
+				dirRules := append([]ignoreRule(nil), parent...)
+				rules[rel] = append(dirRules, readIgnoreFile(filepath.Join(path, ".gitignore"), rel)...)
+			}
+			if info, err := d.Info(); err == nil {
+				dirs[path] = info.ModTime()
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() || ignored(rules[filepath.ToSlash(filepath.Dir(rel))], rel, false) {
+			return nil
+		}
+		files++
+		if files > scanMaxFiles {
+			return fs.SkipAll
+		}
+
+		lang := ps.languageForFile(d.Name())
+		if lang == "" || nonCodeLanguages[lang] {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Size() <= scanMaxFileSize {
+			bytes[lang] += info.Size()
+		}
+		return nil
+	})
+
+	var total int64
+	for _, n := range bytes {
+		total += n
+	}
+	languages := make([]LanguageShare, 0, len(bytes))
+	for lang, n := range bytes {
+		if n == 0 {
+			continue
+		}
+		languages = append(languages, LanguageShare{
+			Language: lang,
+			Bytes:    n,
+			Share:    float64(n) / float64(total),
+		})
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if languages[i].Bytes != languages[j].Bytes {
+			return languages[i].Bytes > languages[j].Bytes
+		}
+		return languages[i].Language < languages[j].Language
+	})
+	return languages, dirs
+}
+
+// primaryAndSecondary splits a composition into the main language and
+// any others that make up a meaningful part of the project.
+func primaryAndSecondary(languages []LanguageShare) (string, []string) {
+	if len(languages) == 0 {
+		return "", nil
+	}
+	var secondary []string
+	for _, share := range languages[1:] {
+		if share.Share < secondaryLanguageShare || len(secondary) == maxSecondaryLanguages {
+			break
+		}
+		secondary = append(secondary, share.Language)
+	}
+	return languages[0].Language, secondary
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProjectScan(t *testing.T) {
+	// Keep the user's global excludes out of it
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	writeFiles(t, config, map[string]string{"git/ignore": "*.swp\n"})
+
+	root := t.TempDir()
+	code := func(n int) string { return strings.Repeat("x", n) }
+	writeFiles(t, root, map[string]string{
+		".git/HEAD":         "ref: refs/heads/main\n",
+		".git/info/exclude": "scratch/\n",
+		".git/objects/x.go": code(5000),
+		".gitignore":        "/vendor/\n*.gen.go\nbuild\n!tools/build\n",
+
+		"main.go":            code(600),
+		"cmd/tool/tool.go":   code(400),
+		"web/app.ts":         code(250),
+		"scripts/deploy.py":  code(50),
+		"README.md":          code(9000),
+		"config.yaml":        code(9000),
+		"include/lib.h":      code(9000),
+		"main.go.swp":        code(9000),
+		"scratch/try.go":     code(9000),
+		"vendor/dep/dep.go":  code(9000),
+		"api/api.gen.go":     code(9000),
+		"build/out.js":       code(9000),
+		"cmd/build/main.go":  code(9000),
+		"tools/build/gen.go": code(100),
+
+		// Rules in a nested .gitignore only apply below it, and can undo
+		// the top-level ones
+		"web/.gitignore":     "dist/\n!*.gen.go\n",
+		"web/dist/bundle.ts": code(9000),
+		"web/client.gen.go":  code(100),
+		"dist/keep.ts":       code(50),
+	})
+
+	cd := NewContextDetector(DefaultConfig())
+	languages, dirs := cd.scanner.scan(root)
+
+	// dist/keep.ts isn't under web/, so web's dist/ rule leaves it be
+	want := []LanguageShare{
+		{Language: "go", Bytes: 1200, Share: 1200.0 / 1550},
+		{Language: "typescript", Bytes: 300, Share: 300.0 / 1550},
+		{Language: "python", Bytes: 50, Share: 50.0 / 1550},
+	}
+	if !reflect.DeepEqual(languages, want) {
+		t.Errorf("scan() = %+v, want %+v", languages, want)
+	}
+
+	for _, dir := range []string{"vendor", "scratch", "build", "web/dist", ".git"} {
+		if _, ok := dirs[filepath.Join(root, dir)]; ok {
+			t.Errorf("scan() walked ignored directory %s", dir)
+		}
+	}
+	if _, ok := dirs[filepath.Join(root, "tools/build")]; !ok {
+		t.Error("scan() skipped tools/build, which is negated")
+	}
+}
+
+func TestPrimaryAndSecondary(t *testing.T) {
+	tests := []struct {
+		name      string
+		shares    []float64
+		primary   string
+		secondary []string
+	}{
+		{"empty", nil, "", nil},
+		{"one language", []float64{1}, "go", nil},
+		{"secondary", []float64{0.7, 0.2, 0.1}, "go", []string{"typescript", "python"}},
+		{"too small", []float64{0.85, 0.09, 0.06}, "go", nil},
+		{"at most three", []float64{0.3, 0.2, 0.2, 0.15, 0.15}, "go", []string{"typescript", "python", "rust"}},
+	}
+	names := []string{"go", "typescript", "python", "rust", "c"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var languages []LanguageShare
+			for i, share := range tt.shares {
+				languages = append(languages, LanguageShare{Language: names[i], Share: share})
+			}
+			primary, secondary := primaryAndSecondary(languages)
+			if primary != tt.primary || strings.Join(secondary, ",") != strings.Join(tt.secondary, ",") {
+				t.Errorf("primaryAndSecondary() = %q, %q, want %q, %q", primary, secondary, tt.primary, tt.secondary)
+			}
+		})
+	}
+}
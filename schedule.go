@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeRange is part of a day in minutes since midnight. A range ending
+// before it starts runs past midnight into the next day.
+type timeRange struct {
+	start, end int
+}
+
+func (r timeRange) crossesMidnight() bool {
+	return r.end <= r.start
+}
+
+// parseTimeRange reads "09:00-17:30".
+func parseTimeRange(s string) (timeRange, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return timeRange{}, fmt.Errorf("%q is not a range like \"09:00-17:30\"", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return timeRange{}, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return timeRange{}, err
+	}
+	if start == end {
+		return timeRange{}, fmt.Errorf("%q is empty", s)
+	}
+	return timeRange{start: start, end: end}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time like \"17:30\"", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Days working hours can be given for; "weekdays" and "weekend" are
+// overridden by the days they cover
+var scheduleDays = map[string][]time.Weekday{
+	"monday":    {time.Monday},
+	"tuesday":   {time.Tuesday},
+	"wednesday": {time.Wednesday},
+	"thursday":  {time.Thursday},
+	"friday":    {time.Friday},
+	"saturday":  {time.Saturday},
+	"sunday":    {time.Sunday},
+	"weekdays":  {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekend":   {time.Saturday, time.Sunday},
+}
+
+const holidayLayout = "2006-01-02"
+
+// Schedule says when encouragement is welcome: within working hours, if
+// any are set, outside quiet hours, and not on holidays.
+type Schedule struct {
+	working  map[time.Weekday][]timeRange // nil when any time is working time
+	quiet    []timeRange
+	holidays map[string]bool
+	wrapUp   bool
+}
+
+// NewSchedule builds a schedule from a validated config.
+func NewSchedule(config *ScheduleConfig) *Schedule {
+	s := &Schedule{
+		holidays: make(map[string]bool),
+		wrapUp:   config.WrapUp,
+	}
+
+	if len(config.WorkingHours) > 0 {
+		s.working = make(map[time.Weekday][]timeRange)
+		// Groups first, so single days replace them
+		for _, group := range []bool{true, false} {
+			for day, ranges := range config.WorkingHours {
+				weekdays := scheduleDays[strings.ToLower(day)]
+				if (len(weekdays) > 1) != group {
+					continue
+				}
+				parsed := make([]timeRange, 0, len(ranges))
+				for _, r := range ranges {
+					if tr, err := parseTimeRange(r); err == nil {
+						parsed = append(parsed, tr)
+					}
+				}
+				for _, weekday := range weekdays {
+					s.working[weekday] = parsed
+				}
+			}
+		}
+	}
+
+	for _, r := range config.QuietHours {
+		if tr, err := parseTimeRange(r); err == nil {
+			s.quiet = append(s.quiet, tr)
+		}
+	}
+	for _, day := range config.Holidays {
+		s.holidays[day] = true
+	}
+	return s
+}
+
+// Allows reports whether encouragement may be sent at t.
+func (s *Schedule) Allows(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	for _, r := range s.quiet {
+		if r.crossesMidnight() && (minute >= r.start || minute < r.end) ||
+			!r.crossesMidnight() && minute >= r.start && minute < r.end {
+			return false
+		}
+	}
+	if s.working == nil {
+		return !s.holidays[t.Format(holidayLayout)]
+	}
+	return s.inWorkingHours(t)
+}
+
+// inWorkingHours reports whether t is in working hours, counting the end of a
+// range that started yesterday and ran past midnight.
+func (s *Schedule) inWorkingHours(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if s.isWorkday(t) {
+		for _, r := range s.working[t.Weekday()] {
+			if minute >= r.start && (r.crossesMidnight() || minute < r.end) {
+				return true
+			}
+		}
+	}
+	yesterday := t.AddDate(0, 0, -1)
+	if s.isWorkday(yesterday) {
+		for _, r := range s.working[yesterday.Weekday()] {
+			if r.crossesMidnight() && minute < r.end {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Schedule) isWorkday(t time.Time) bool {
+	return len(s.working[t.Weekday()]) > 0 && !s.holidays[t.Format(holidayLayout)]
+}
+
+// LastWorkday returns when the most recent working day that has ended by
+// t started and ended, looking back as far as yesterday.
+func (s *Schedule) LastWorkday(t time.Time) (start, end time.Time, ok bool) {
+	for _, day := range []time.Time{t, t.AddDate(0, 0, -1)} {
+		if start, end, ok := s.workdayHours(day); ok && !end.After(t) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// WorkdayStarted reports whether a working day has begun since end, by t.
+func (s *Schedule) WorkdayStarted(end, t time.Time) bool {
+	for _, day := range []time.Time{t, t.AddDate(0, 0, -1)} {
+		if start, _, ok := s.workdayHours(day); ok && start.After(end) && !start.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// workdayHours returns when the working day on day's date starts and
+// ends, which may be after midnight.
+func (s *Schedule) workdayHours(day time.Time) (start, end time.Time, ok bool) {
+	if !s.isWorkday(day) {
+		return time.Time{}, time.Time{}, false
+	}
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	for _, r := range s.working[day.Weekday()] {
+		rangeStart := midnight.Add(time.Duration(r.start) * time.Minute)
+		rangeEnd := midnight.Add(time.Duration(r.end) * time.Minute)
+		if r.crossesMidnight() {
+			rangeEnd = rangeEnd.AddDate(0, 0, 1)
+		}
+		if start.IsZero() || rangeStart.Before(start) {
+			start = rangeStart
+		}
+		if rangeEnd.After(end) {
+			end = rangeEnd
+		}
+	}
+	return start, end, true
+}
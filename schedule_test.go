@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleAllows(t *testing.T) {
+	// The week of Monday 2 March 2026
+	day := func(weekday time.Weekday, hour, minute int) time.Time {
+		return time.Date(2026, time.March, 2+(int(weekday)+6)%7, hour, minute, 0, 0, time.Local)
+	}
+	nextSunday := func(hour, minute int) time.Time {
+		return day(time.Sunday, 24*7+hour, minute)
+	}
+
+	quietOnly := NewSchedule(&ScheduleConfig{
+		QuietHours: []string{"22:00-07:00", "12:00-13:00"},
+		Holidays:   []string{"2026-03-04"},
+	})
+	working := NewSchedule(&ScheduleConfig{
+		WorkingHours: map[string][]string{
+			"weekdays": {"09:00-17:00"},
+			"Friday":   {"10:00-12:00"},
+			"saturday": {"22:00-02:00"},
+		},
+		QuietHours: []string{"12:00-13:00"},
+		Holidays:   []string{"2026-03-04"},
+	})
+	lateHoliday := NewSchedule(&ScheduleConfig{
+		WorkingHours: map[string][]string{"saturday": {"22:00-02:00"}},
+		Holidays:     []string{"2026-03-07", "2026-03-15"},
+	})
+
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		at       time.Time
+		want     bool
+	}{
+		{"before quiet hours", quietOnly, day(time.Monday, 21, 59), true},
+		{"quiet hours start", quietOnly, day(time.Monday, 22, 0), false},
+		{"quiet hours before midnight", quietOnly, day(time.Monday, 23, 59), false},
+		{"quiet hours after midnight", quietOnly, day(time.Tuesday, 0, 30), false},
+		{"quiet hours nearly over", quietOnly, day(time.Tuesday, 6, 59), false},
+		{"quiet hours end", quietOnly, day(time.Tuesday, 7, 0), true},
+		{"quiet lunch", quietOnly, day(time.Tuesday, 12, 30), false},
+		{"after lunch", quietOnly, day(time.Tuesday, 13, 0), true},
+		{"weekend without working hours", quietOnly, day(time.Saturday, 15, 0), true},
+		{"holiday without working hours", quietOnly, day(time.Wednesday, 10, 0), false},
+		{"day after a holiday", quietOnly, day(time.Thursday, 10, 0), true},
+
+		{"before work", working, day(time.Monday, 8, 59), false},
+		{"work starts", working, day(time.Monday, 9, 0), true},
+		{"end of work", working, day(time.Monday, 16, 59), true},
+		{"after work", working, day(time.Monday, 17, 0), false},
+		{"quiet hours at work", working, day(time.Monday, 12, 30), false},
+		{"holiday with working hours", working, day(time.Wednesday, 10, 0), false},
+		{"workday after a holiday", working, day(time.Thursday, 10, 0), true},
+		{"friday overrides weekdays early", working, day(time.Friday, 9, 30), false},
+		{"friday hours", working, day(time.Friday, 11, 0), true},
+		{"friday overrides weekdays late", working, day(time.Friday, 15, 0), false},
+		{"before a late shift", working, day(time.Saturday, 21, 59), false},
+		{"late shift before midnight", working, day(time.Saturday, 23, 0), true},
+		{"late shift after midnight", working, day(time.Sunday, 1, 59), true},
+		{"late shift over", working, day(time.Sunday, 2, 0), false},
+		{"day off", working, day(time.Sunday, 10, 0), false},
+		{"the next week", working, nextSunday(1, 0), true},
+
+		{"late shift on a holiday", lateHoliday, day(time.Saturday, 23, 0), false},
+		{"after a late shift on a holiday", lateHoliday, day(time.Sunday, 1, 0), false},
+		{"late shift into a holiday", lateHoliday, nextSunday(1, 0), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Allows(tt.at); got != tt.want {
+				t.Errorf("Allows(%s) = %v, want %v", tt.at.Format("Mon 2 Jan 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleWithoutRanges(t *testing.T) {
+	// A day given no ranges is a day off, even within "weekdays"
+	schedule := NewSchedule(&ScheduleConfig{
+		WorkingHours: map[string][]string{"weekdays": {"09:00-17:00"}, "wednesday": {}},
+	})
+	wednesday := time.Date(2026, time.March, 4, 10, 0, 0, 0, time.Local)
+	if schedule.Allows(wednesday) {
+		t.Error("Allows() on a day with no ranges")
+	}
+	if !schedule.Allows(wednesday.AddDate(0, 0, 1)) {
+		t.Error("Allows() = false on the next weekday")
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// SessionEvent is a change in whether the user can be at the computer:
+// the screen locking or unlocking, or the machine suspending or resuming.
+type SessionEvent struct {
+	Kind string // "lock", "unlock", "sleep" or "resume"
+	At   time.Time
+
+	// Lets a suspend that waits for this event go ahead
+	release func()
+}
+
+// Release lets the machine go to sleep once a sleep event has been
+// handled. It does nothing for other events.
+func (e SessionEvent) Release() {
+	if e.release != nil {
+		e.release()
+	}
+}
+
+// WatchSessionEvents subscribes to logind's PrepareForSleep on the system bus
+// and the screensaver's ActiveChanged on the session bus. The channel is
+// closed once done is closed or both buses go away.
+func WatchSessionEvents(done <-chan struct{}) (<-chan SessionEvent, error) {
+	system, err := dbus.SystemBus()
+	if err != nil {
+		system = nil
+	}
+	session, err := dbus.SessionBus()
+	if err != nil {
+		session = nil
+	}
+	return watchSessionEvents(system, session, done)
+}
+
+// watchSessionEvents listens on whichever of the buses it's given. While
+// subscribed to logind it holds a delay inhibitor lock, so suspending waits
+// until the sleep event has been released rather than the signal only
+// being seen after resuming, which would count the time asleep as time
+// spent in the focused window.
+func watchSessionEvents(system, session *dbus.Conn, done <-chan struct{}) (<-chan SessionEvent, error) {
+	signals := make(chan *dbus.Signal, 16)
+	var conns []*dbus.Conn
+	var inhibitor *sleepInhibitor
+
+	if system != nil {
+		err := system.AddMatchSignal(
+			dbus.WithMatchInterface("org.freedesktop.login1.Manager"),
+			dbus.WithMatchMember("PrepareForSleep"),
+		)
+		if err == nil {
+			system.Signal(signals)
+			conns = append(conns, system)
+			inhibitor = &sleepInhibitor{conn: system, fd: -1}
+			inhibitor.take()
+		}
+	}
+
+	if session != nil {
+		subscribed := false
+		// GNOME only emits on its own interface
+		for _, iface := range []string{"org.freedesktop.ScreenSaver", "org.gnome.ScreenSaver"} {
+			err := session.AddMatchSignal(
+				dbus.WithMatchInterface(iface),
+				dbus.WithMatchMember("ActiveChanged"),
+			)
+			if err == nil {
+				subscribed = true
+			}
+		}
+		if subscribed {
+			session.Signal(signals)
+			conns = append(conns, session)
+		}
+	}
+
+	if len(conns) == 0 {
+		return nil, errors.New("could not subscribe to lock or sleep signals")
+	}
+
+	events := make(chan SessionEvent)
+	go func() {
+		defer close(events)
+		defer func() {
+			for _, conn := range conns {
+				conn.RemoveSignal(signals)
+			}
+			if inhibitor != nil {
+				inhibitor.release()
+			}
+		}()
+
+		for {
+			select {
+			case <-done:
+				return
+			case signal, ok := <-signals:
+				if !ok {
+					return
+				}
+				event, ok := sessionEventFromSignal(signal, time.Now())
+				if !ok {
+					continue
+				}
+				if inhibitor != nil {
+					switch event.Kind {
+					case "sleep":
+						event.release = inhibitor.release
+					case "resume":
+						// Ready for the next suspend
+						inhibitor.take()
+					}
+				}
+				select {
+				case events <- event:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+func sessionEventFromSignal(signal *dbus.Signal, now time.Time) (SessionEvent, bool) {
+	if len(signal.Body) == 0 {
+		return SessionEvent{}, false
+	}
+	active, ok := signal.Body[0].(bool)
+	if !ok {
+		return SessionEvent{}, false
+	}
+
+	event := SessionEvent{At: now}
+	switch signal.Name {
+	case "org.freedesktop.login1.Manager.PrepareForSleep":
+		event.Kind = "resume"
+		if active {
+			event.Kind = "sleep"
+		}
+	case "org.freedesktop.ScreenSaver.ActiveChanged", "org.gnome.ScreenSaver.ActiveChanged":
+		event.Kind = "unlock"
+		if active {
+			event.Kind = "lock"
+		}
+	default:
+		return SessionEvent{}, false
+	}
+	return event, true
+}
+
+// sleepInhibitor holds a logind delay lock on sleep: suspending waits, up
+// to logind's InhibitDelayMaxSec, until the file descriptor it hands out
+// is closed.
+type sleepInhibitor struct {
+	conn *dbus.Conn
+
+	mu     sync.Mutex
+	fd     int // -1 while not held
+	failed bool
+}
+
+func (i *sleepInhibitor) take() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.fd >= 0 {
+		return
+	}
+
+	var fd dbus.UnixFD
+	err := i.conn.Object("org.freedesktop.login1", "/org/freedesktop/login1").Call(
+		"org.freedesktop.login1.Manager.Inhibit", 0,
+		"sleep", "emotional-support", "Saving the current session", "delay",
+	).Store(&fd)
+	if err != nil {
+		// Only the first failure is worth a warning
+		if !i.failed {
+			log.Printf("Warning: Could not delay sleep, time asleep may be counted as activity: %v", err)
+			i.failed = true
+		}
+		return
+	}
+	i.fd = int(fd)
+	i.failed = false
+}
+
+func (i *sleepInhibitor) release() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.fd >= 0 {
+		syscall.Close(i.fd)
+		i.fd = -1
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestSessionEventFromSignal(t *testing.T) {
+	now := time.Date(2026, time.March, 2, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		name string
+		body []interface{}
+		want string // Empty when the signal isn't an event
+	}{
+		{"org.freedesktop.login1.Manager.PrepareForSleep", []interface{}{true}, "sleep"},
+		{"org.freedesktop.login1.Manager.PrepareForSleep", []interface{}{false}, "resume"},
+		{"org.freedesktop.ScreenSaver.ActiveChanged", []interface{}{true}, "lock"},
+		{"org.freedesktop.ScreenSaver.ActiveChanged", []interface{}{false}, "unlock"},
+		{"org.gnome.ScreenSaver.ActiveChanged", []interface{}{true}, "lock"},
+		{"org.gnome.ScreenSaver.ActiveChanged", []interface{}{false}, "unlock"},
+		{"org.freedesktop.login1.Manager.PrepareForShutdown", []interface{}{true}, ""},
+		{"org.freedesktop.ScreenSaver.ActiveChanged", nil, ""},
+		{"org.freedesktop.ScreenSaver.ActiveChanged", []interface{}{"yes"}, ""},
+	}
+	for _, tt := range tests {
+		event, ok := sessionEventFromSignal(&dbus.Signal{Name: tt.name, Body: tt.body}, now)
+		if ok != (tt.want != "") || event.Kind != tt.want {
+			t.Errorf("sessionEventFromSignal(%s %v) = %q, %v, want %q", tt.name, tt.body, event.Kind, ok, tt.want)
+			continue
+		}
+		if ok && !event.At.Equal(now) {
+			t.Errorf("sessionEventFromSignal(%s %v) at %v, want %v", tt.name, tt.body, event.At, now)
+		}
+	}
+}
+
+func TestHandleSessionEvent(t *testing.T) {
+	start := time.Date(2026, time.March, 2, 10, 0, 0, 0, time.Local)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	type step struct {
+		kind    string
+		minutes int
+		back    bool
+	}
+	tests := []struct {
+		name     string
+		steps    []step
+		away     string
+		sessions string
+		periods  string
+	}{
+		{
+			"suspend",
+			[]step{{"sleep", 30, false}, {"resume", 480, true}},
+			"", "vim 30m0s", "sleep 7h30m0s",
+		},
+		{
+			"lock",
+			[]step{{"lock", 30, false}, {"unlock", 45, true}},
+			"", "vim 30m0s", "lock 15m0s",
+		},
+		{
+			// Resuming onto the lock screen isn't being back yet
+			"lock, suspend, resume and unlock",
+			[]step{{"lock", 30, false}, {"sleep", 31, false}, {"resume", 480, false}, {"unlock", 482, true}},
+			"", "vim 30m0s", "lock 1m0s,sleep 7h29m0s,lock 2m0s",
+		},
+		{
+			"still asleep while unlocked",
+			[]step{{"sleep", 30, false}, {"unlock", 40, false}},
+			"sleep", "vim 30m0s", "",
+		},
+		{
+			"repeated lock",
+			[]step{{"lock", 30, false}, {"lock", 40, false}},
+			"lock", "vim 30m0s", "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApp()
+			app.database = newTestDatabase(t)
+			app.handleWindow(testEditorWindow, start)
+
+			for _, s := range tt.steps {
+				if back := app.handleSessionEvent(SessionEvent{Kind: s.kind, At: at(s.minutes)}); back != s.back {
+					t.Errorf("handleSessionEvent(%s) = %v, want %v", s.kind, back, s.back)
+				}
+			}
+			if app.awayReason != tt.away {
+				t.Errorf("away for %q, want %q", app.awayReason, tt.away)
+			}
+			// Time away isn't credited to the window focused before
+			if got := strings.Join(loggedSessions(t, app.database), ","); got != tt.sessions {
+				t.Errorf("sessions %s, want %s", got, tt.sessions)
+			}
+			if got := strings.Join(loggedAwayPeriods(t, app.database), ","); got != tt.periods {
+				t.Errorf("away periods %s, want %s", got, tt.periods)
+			}
+			var events int
+			app.database.db.QueryRow(`SELECT COUNT(*) FROM session_events`).Scan(&events)
+			if events != len(tt.steps) {
+				t.Errorf("%d session events logged, want %d", events, len(tt.steps))
+			}
+		})
+	}
+}
+
+// loggedAwayPeriods lists the away periods in the database as
+// "reason duration", oldest first.
+func loggedAwayPeriods(t *testing.T, database *Database) []string {
+	t.Helper()
+	rows, err := database.db.Query(`SELECT reason, duration_seconds FROM away_periods ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var periods []string
+	for rows.Next() {
+		var reason string
+		var seconds int
+		if err := rows.Scan(&reason, &seconds); err != nil {
+			t.Fatal(err)
+		}
+		periods = append(periods, reason+" "+(time.Duration(seconds)*time.Second).String())
+	}
+	return periods
+}
+
+// fakeLogind hands out delay locks as logind does: the read end of a pipe
+// stays open until every copy of the write end it passed out is closed.
+type fakeLogind struct {
+	mu    sync.Mutex
+	locks []*os.File // Read ends, one per Inhibit call
+	sent  []*os.File // Write ends, still to be closed on our side
+}
+
+func (l *fakeLogind) Inhibit(what, who, why, mode string) (dbus.UnixFD, *dbus.Error) {
+	if what != "sleep" || mode != "delay" {
+		return 0, dbus.MakeFailedError(os.ErrInvalid)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, dbus.MakeFailedError(err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.locks = append(l.locks, r)
+	l.sent = append(l.sent, w)
+	return dbus.UnixFD(w.Fd()), nil
+}
+
+// held reports whether the i-th lock is still held by anyone but us.
+func (l *fakeLogind) held(t *testing.T, i int) bool {
+	t.Helper()
+	l.mu.Lock()
+	if i >= len(l.locks) {
+		l.mu.Unlock()
+		t.Fatalf("only %d locks taken, want lock %d", len(l.locks), i+1)
+	}
+	lock, sent := l.locks[i], l.sent[i]
+	l.mu.Unlock()
+
+	// Our copy went out with the reply already
+	sent.Close()
+	lock.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, err := lock.Read(make([]byte, 1))
+	return os.IsTimeout(err)
+}
+
+func TestWatchSessionEventsDelaysSleep(t *testing.T) {
+	address := privateSessionBus(t)
+	connect := func() *dbus.Conn {
+		conn, err := dbus.Connect(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+
+	server := connect()
+	logind := &fakeLogind{}
+	if err := server.Export(logind, "/org/freedesktop/login1", "org.freedesktop.login1.Manager"); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := server.RequestName("org.freedesktop.login1", dbus.NameFlagDoNotQueue); err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("could not own org.freedesktop.login1: %v", err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	events, err := watchSessionEvents(connect(), nil, done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := func(member string, active bool) SessionEvent {
+		t.Helper()
+		if err := server.Emit("/org/freedesktop/login1", "org.freedesktop.login1.Manager."+member, active); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no event for %s(%v)", member, active)
+			return SessionEvent{}
+		}
+	}
+
+	sleep := next("PrepareForSleep", true)
+	if sleep.Kind != "sleep" {
+		t.Fatalf("got %q, want sleep", sleep.Kind)
+	}
+	if !logind.held(t, 0) {
+		t.Fatal("sleep went ahead before the event was handled")
+	}
+	sleep.Release()
+	if logind.held(t, 0) {
+		t.Fatal("sleep still delayed after the event was released")
+	}
+
+	// Resuming takes a lock for the next time
+	if resume := next("PrepareForSleep", false); resume.Kind != "resume" {
+		t.Fatalf("got %q, want resume", resume.Kind)
+	}
+	if !logind.held(t, 1) {
+		t.Error("no lock taken for the next suspend")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Kinds of site a browser tab can be on
+const (
+	SiteDocs         = "docs"
+	SiteCodeReview   = "code_review"
+	SiteIssueTracker = "issue_tracker"
+	SiteCodeHosting  = "code_hosting"
+	SiteSocial       = "social"
+	SiteVideo        = "video"
+)
+
+var siteCategories = map[string]bool{
+	SiteDocs: true, SiteCodeReview: true, SiteIssueTracker: true,
+	SiteCodeHosting: true, SiteSocial: true, SiteVideo: true,
+}
+
+// SiteRule recognises a site from a browser tab's title, or from a domain
+// that appears in it (pages without a title show their URL).
+type SiteRule struct {
+	Name     string
+	Site     string
+	Title    *regexp.Regexp
+	Domains  []string
+	Category string
+	Language string
+}
+
+func (r *SiteRule) matchesAnyDomain(domains []string) bool {
+	for _, domain := range domains {
+		for _, d := range r.Domains {
+			if domain == d || strings.HasSuffix(domain, "."+d) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	// What browsers append to the page title
+	browserTitleSuffix = regexp.MustCompile(`(?i)\s+[-—–]\s+(mozilla firefox|firefox|google chrome|chromium|brave|vivaldi|microsoft edge|librewolf|opera)( private browsing| \(incognito\))?$`)
+	// Host names, as in "docs.example.com/guide" or "example.org"
+	titleDomain = regexp.MustCompile(`(?i)\b((?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,})\b`)
+	// Where "Page - Site" style titles put the site
+	titleSiteSeparator = regexp.MustCompile(`\s+(?:[-—–|·•:]|/)\s+`)
+)
+
+// Sites longer than this in a title are probably part of the page name
+const maxTitleSiteLength = 30
+
+// detectSite works out the site and its category from a browser title.
+// The first rule whose title pattern or domains match wins. Without one
+// the site is the last part of a "Page - Site" title.
+func (cd *ContextDetector) detectSite(ctx *Context, title string) {
+	page := browserTitleSuffix.ReplaceAllString(title, "")
+
+	var domains []string
+	for _, match := range titleDomain.FindAllStringSubmatch(page, -1) {
+		domain := strings.ToLower(match[1])
+		// Skip file names like "main.go" that look like hosts
+		if cd.languageForFile(domain) == "" {
+			domains = append(domains, domain)
+		}
+	}
+
+	// Rules are tried in order, each by title and then by domain, so a
+	// pull request on github.com is a review rather than code hosting
+	var rule *SiteRule
+	var match []string
+	for _, r := range cd.siteRules {
+		if r.Title != nil {
+			if match = r.Title.FindStringSubmatch(page); match != nil {
+				rule = r
+				break
+			}
+		}
+		if r.matchesAnyDomain(domains) {
+			rule = r
+			break
+		}
+	}
+
+	if rule == nil {
+		if len(domains) > 0 {
+			ctx.Site = domains[0]
+		} else if parts := titleSiteSeparator.Split(page, -1); len(parts) > 1 {
+			if site := strings.TrimSpace(parts[len(parts)-1]); len(site) <= maxTitleSiteLength {
+				ctx.Site = site
+			}
+		}
+		return
+	}
+
+	ctx.Site = rule.Site
+	ctx.SiteCategory = rule.Category
+	if rule.Language != "" {
+		ctx.Language = rule.Language
+	}
+	// Titles like "Fix it · Pull Request #12 · owner/repo · GitHub" name
+	// the project being reviewed
+	if match != nil {
+		if i := rule.Title.SubexpIndex("project"); i > 0 && match[i] != "" {
+			ctx.ProjectName = match[i]
+		}
+	}
+}
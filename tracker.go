@@ -1,8 +1,7 @@
 package main
 
 import (
-	"fmt"
-	"os/exec"
+	"os"
 	"strings"
 )
 
@@ -10,68 +9,44 @@ type WindowInfo struct {
 	Title   string
 	Process string
 	PID     string
-}
-
-type WindowTracker struct{}
+	Class   string // WM_CLASS class, or the Wayland app_id
 
-func NewWindowTracker() *WindowTracker {
-	return &WindowTracker{}
+	// Fullscreen is set while the window covers the whole output, as
+	// for a video, a game or a presentation
+	Fullscreen bool
 }
 
-func (wt *WindowTracker) GetActiveWindow() (*WindowInfo, error) {
-	// First, try to get the active window ID
-	cmd := exec.Command("xdotool", "getactivewindow")
-	windowIDBytes, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get active window ID: %w", err)
-	}
-	windowID := strings.TrimSpace(string(windowIDBytes))
-	if windowID == "" || windowID == "0" {
-		return nil, fmt.Errorf("no active window found")
-	}
+// WindowTracker reports the currently focused window. Each backend speaks
+// to a different display server or window manager.
+type WindowTracker interface {
+	GetActiveWindow() (*WindowInfo, error)
+}
 
-	// Get window title (this might fail for some windows, so we make it optional)
-	title := ""
-	cmd = exec.Command("xdotool", "getwindowname", windowID)
-	if titleBytes, err := cmd.Output(); err == nil {
-		title = strings.TrimSpace(string(titleBytes))
-	}
+// FocusWatcher is implemented by trackers that can push focus changes as
+// they happen instead of waiting to be polled. The returned channel is
+// closed when the event source goes away or done is closed.
+type FocusWatcher interface {
+	WatchFocus(done <-chan struct{}) (<-chan *WindowInfo, error)
+}
 
-	// Get window PID
-	pid := ""
-	cmd = exec.Command("xdotool", "getwindowpid", windowID)
-	if pidBytes, err := cmd.Output(); err == nil {
-		pid = strings.TrimSpace(string(pidBytes))
+// NewWindowTracker picks the best available backend for the running session.
+// i3/sway IPC is preferred when its socket is advertised, otherwise we talk
+// to the X server directly.
+func NewWindowTracker() WindowTracker {
+	if socketPath := i3SocketPath(); socketPath != "" {
+		return NewI3Tracker(socketPath)
 	}
+	return NewX11Tracker(os.Getenv("DISPLAY"))
+}
 
-	// Get process name from PID
-	process := ""
-	if pid != "" && pid != "0" {
-		cmd = exec.Command("ps", "-p", pid, "-o", "comm=")
-		if processBytes, err := cmd.Output(); err == nil {
-			process = strings.TrimSpace(string(processBytes))
-		}
+// processNameFromPID reads the short command name of a process from /proc.
+func processNameFromPID(pid string) string {
+	if pid == "" || pid == "0" {
+		return ""
 	}
-
-	// If we still don't have a process name, try xprop WM_CLASS as fallback
-	// This works better with some window managers like i3
-	if process == "" {
-		cmd = exec.Command("xprop", "-id", windowID, "WM_CLASS")
-		if classBytes, err := cmd.Output(); err == nil {
-			classStr := string(classBytes)
-			// WM_CLASS format: WM_CLASS(STRING) = "instance", "class"
-			// We want the class name (second quoted string)
-			if idx := strings.LastIndex(classStr, `"`); idx > 0 {
-				if prevIdx := strings.LastIndex(classStr[:idx], `"`); prevIdx > 0 {
-					process = strings.ToLower(strings.TrimSpace(classStr[prevIdx+1 : idx]))
-				}
-			}
-		}
+	data, err := os.ReadFile("/proc/" + pid + "/comm")
+	if err != nil {
+		return ""
 	}
-
-	return &WindowInfo{
-		Title:   title,
-		Process: process,
-		PID:     pid,
-	}, nil
+	return strings.TrimSpace(string(data))
 }
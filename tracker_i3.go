@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// i3 IPC message types (shared by sway)
+const (
+	i3IPCSubscribe uint32 = 2
+	i3IPCGetTree   uint32 = 4
+
+	// Events have the high bit set on their message type
+	i3IPCEventWindow uint32 = 0x80000003
+)
+
+const i3IPCMagic = "i3-ipc"
+
+// i3Node is the subset of an i3/sway layout tree node we care about.
+type i3Node struct {
+	ID               int64  `json:"id"`
+	Name             string `json:"name"`
+	Type             string `json:"type"`
+	Focused          bool   `json:"focused"`
+	AppID            string `json:"app_id"`
+	PID              int    `json:"pid"`
+	Window           int64  `json:"window"`
+	FullscreenMode   int    `json:"fullscreen_mode"` // 0 none, 1 output, 2 global
+	WindowProperties struct {
+		Class    string `json:"class"`
+		Instance string `json:"instance"`
+		Title    string `json:"title"`
+	} `json:"window_properties"`
+	Nodes         []*i3Node `json:"nodes"`
+	FloatingNodes []*i3Node `json:"floating_nodes"`
+}
+
+// I3Tracker reads the focused window from i3 or sway over their IPC socket.
+type I3Tracker struct {
+	socketPath string
+	timeout    time.Duration
+}
+
+func NewI3Tracker(socketPath string) *I3Tracker {
+	return &I3Tracker{socketPath: socketPath, timeout: 2 * time.Second}
+}
+
+// i3SocketPath returns the IPC socket advertised by sway or i3, if any.
+func i3SocketPath() string {
+	if path := os.Getenv("SWAYSOCK"); path != "" {
+		return path
+	}
+	return os.Getenv("I3SOCK")
+}
+
+func (t *I3Tracker) GetActiveWindow() (*WindowInfo, error) {
+	root, err := t.getTree()
+	if err != nil {
+		return nil, err
+	}
+
+	node := findFocusedNode(root)
+	if node == nil || (node.PID == 0 && node.AppID == "" && node.Window == 0) {
+		return nil, fmt.Errorf("no active window found")
+	}
+	windowInfo := windowInfoFromI3Node(node)
+	windowInfo.Fullscreen, _ = focusedFullscreen(root)
+	return windowInfo, nil
+}
+
+// getTree fetches the layout tree over a connection of its own.
+func (t *I3Tracker) getTree() (*i3Node, error) {
+	conn, err := net.DialTimeout("unix", t.socketPath, t.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to IPC socket: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(t.timeout))
+
+	if err := writeI3Message(conn, i3IPCGetTree, nil); err != nil {
+		return nil, fmt.Errorf("failed to request tree: %w", err)
+	}
+	_, payload, err := readI3Message(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree: %w", err)
+	}
+
+	var root i3Node
+	if err := json.Unmarshal(payload, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse tree: %w", err)
+	}
+	return &root, nil
+}
+
+// WatchFocus subscribes to window events and reports focus, title and
+// fullscreen changes of the focused container.
+func (t *I3Tracker) WatchFocus(done <-chan struct{}) (<-chan *WindowInfo, error) {
+	conn, err := net.DialTimeout("unix", t.socketPath, t.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to IPC socket: %w", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(t.timeout))
+	if err := writeI3Message(conn, i3IPCSubscribe, []byte(`["window"]`)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to subscribe to window events: %w", err)
+	}
+	_, payload, err := readI3Message(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read subscribe reply: %w", err)
+	}
+	var reply struct {
+		Success bool `json:"success"`
+	}
+	if err := json.Unmarshal(payload, &reply); err != nil || !reply.Success {
+		conn.Close()
+		return nil, fmt.Errorf("subscription to window events was refused")
+	}
+	// Events arrive whenever the user does something, so no deadline from here on
+	conn.SetDeadline(time.Time{})
+
+	// Closing the connection ends a read blocked on it; the reader going
+	// away on its own ends the wait for done
+	events := make(chan *WindowInfo)
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+		case <-stopped:
+		}
+		conn.Close()
+	}()
+	go func() {
+		defer close(events)
+		defer close(stopped)
+
+		for {
+			msgType, payload, err := readI3Message(conn)
+			if err != nil {
+				return
+			}
+			if msgType != i3IPCEventWindow {
+				continue
+			}
+
+			var event struct {
+				Change    string  `json:"change"`
+				Container *i3Node `json:"container"`
+			}
+			if err := json.Unmarshal(payload, &event); err != nil || event.Container == nil {
+				continue
+			}
+			switch event.Change {
+			case "focus":
+			case "title", "fullscreen_mode":
+				// Changes to background windows don't concern us
+				if !event.Container.Focused {
+					continue
+				}
+			default:
+				continue
+			}
+
+			// The container only knows its own fullscreen mode, so ask the
+			// tree whether it's inside a fullscreen split
+			windowInfo := windowInfoFromI3Node(event.Container)
+			if root, err := t.getTree(); err == nil {
+				if fullscreen, ok := focusedFullscreen(root); ok {
+					windowInfo.Fullscreen = fullscreen
+				}
+			}
+
+			select {
+			case events <- windowInfo:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// findFocusedNode walks the layout tree looking for the focused container.
+func findFocusedNode(node *i3Node) *i3Node {
+	if node.Focused {
+		return node
+	}
+	for _, children := range [][]*i3Node{node.Nodes, node.FloatingNodes} {
+		for _, child := range children {
+			if found := findFocusedNode(child); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// focusedFullscreen reports whether the focused container is fullscreen,
+// itself or by being inside a fullscreen split. ok is false when the focus
+// isn't under node.
+func focusedFullscreen(node *i3Node) (fullscreen, ok bool) {
+	if node.Focused {
+		return node.FullscreenMode != 0, true
+	}
+	for _, children := range [][]*i3Node{node.Nodes, node.FloatingNodes} {
+		for _, child := range children {
+			if fullscreen, ok := focusedFullscreen(child); ok {
+				return fullscreen || node.FullscreenMode != 0, true
+			}
+		}
+	}
+	return false, false
+}
+
+func windowInfoFromI3Node(node *i3Node) *WindowInfo {
+	title := node.Name
+	if title == "" {
+		title = node.WindowProperties.Title
+	}
+
+	pid := ""
+	if node.PID > 0 {
+		pid = strconv.Itoa(node.PID)
+	}
+
+	// Prefer the real process name, then the Wayland app_id, then the
+	// X11 class (i3 and Xwayland windows)
+	process := processNameFromPID(pid)
+	if process == "" {
+		process = strings.ToLower(node.AppID)
+	}
+	if process == "" {
+		process = strings.ToLower(node.WindowProperties.Class)
+	}
+
+	class := node.WindowProperties.Class
+	if class == "" {
+		class = node.AppID
+	}
+
+	return &WindowInfo{
+		Title:      title,
+		Process:    process,
+		PID:        pid,
+		Class:      class,
+		Fullscreen: node.FullscreenMode != 0,
+	}
+}
+
+// writeI3Message frames a payload as "i3-ipc" <length> <type> <payload>.
+// Integers are in the host's native byte order.
+func writeI3Message(w io.Writer, msgType uint32, payload []byte) error {
+	buf := make([]byte, len(i3IPCMagic)+8+len(payload))
+	copy(buf, i3IPCMagic)
+	binary.NativeEndian.PutUint32(buf[len(i3IPCMagic):], uint32(len(payload)))
+	binary.NativeEndian.PutUint32(buf[len(i3IPCMagic)+4:], msgType)
+	copy(buf[len(i3IPCMagic)+8:], payload)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readI3Message(r io.Reader) (uint32, []byte, error) {
+	header := make([]byte, len(i3IPCMagic)+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	if string(header[:len(i3IPCMagic)]) != i3IPCMagic {
+		return 0, nil, fmt.Errorf("invalid IPC magic %q", header[:len(i3IPCMagic)])
+	}
+	length := binary.NativeEndian.Uint32(header[len(i3IPCMagic):])
+	msgType := binary.NativeEndian.Uint32(header[len(i3IPCMagic)+4:])
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return msgType, payload, nil
+}
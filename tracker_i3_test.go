@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+const testI3Tree = `{
+	"id": 1, "type": "root", "nodes": [
+		{"id": 2, "type": "output", "nodes": [
+			{"id": 3, "type": "workspace", "nodes": [
+				{"id": 4, "type": "con", "name": "notes.txt - Kate", "app_id": "org.kde.kate"},
+				{"id": 5, "type": "con", "name": "main.go - NVIM", "focused": true, "window": 42,
+					"window_properties": {"class": "Alacritty", "title": "main.go - NVIM"}}
+			]}
+		]}
+	]
+}`
+
+// fakeI3Server answers GET_TREE with tree and SUBSCRIBE with success,
+// followed by events, on a temporary unix socket.
+func fakeI3Server(t *testing.T, tree string, events []string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ipc.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				for {
+					msgType, _, err := readI3Message(conn)
+					if err != nil {
+						return
+					}
+					switch msgType {
+					case i3IPCGetTree:
+						writeI3Message(conn, i3IPCGetTree, []byte(tree))
+					case i3IPCSubscribe:
+						writeI3Message(conn, i3IPCSubscribe, []byte(`{"success": true}`))
+						for _, event := range events {
+							writeI3Message(conn, i3IPCEventWindow, []byte(event))
+						}
+					}
+				}
+			}(conn)
+		}
+	}()
+	return path
+}
+
+func TestI3TrackerGetActiveWindow(t *testing.T) {
+	tracker := NewI3Tracker(fakeI3Server(t, testI3Tree, nil))
+
+	info, err := tracker.GetActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := WindowInfo{Title: "main.go - NVIM", Process: "alacritty", Class: "Alacritty"}
+	if *info != want {
+		t.Errorf("GetActiveWindow() = %+v, want %+v", *info, want)
+	}
+}
+
+func TestI3TrackerWatchFocus(t *testing.T) {
+	events := []string{
+		`{"change": "focus", "container": {"id": 4, "name": "notes.txt - Kate", "app_id": "org.kde.kate", "focused": true}}`,
+		// Background windows changing title don't matter
+		`{"change": "title", "container": {"id": 5, "name": "other.go - NVIM", "window": 42}}`,
+		`{"change": "new", "container": {"id": 6, "name": "new window", "focused": true}}`,
+		`{"change": "title", "container": {"id": 4, "name": "todo.txt - Kate", "app_id": "org.kde.kate", "focused": true}}`,
+	}
+	tracker := NewI3Tracker(fakeI3Server(t, testI3Tree, events))
+
+	done := make(chan struct{})
+	defer close(done)
+	focus, err := tracker.WatchFocus(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, title := range []string{"notes.txt - Kate", "todo.txt - Kate"} {
+		select {
+		case info, ok := <-focus:
+			if !ok {
+				t.Fatal("focus events closed early")
+			}
+			if info.Title != title || info.Process != "org.kde.kate" {
+				t.Errorf("got %+v, want %q from org.kde.kate", *info, title)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no event for %q", title)
+		}
+	}
+}
+
+func TestI3TrackerWatchFocusRefused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ipc.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		readI3Message(conn)
+		reply, _ := json.Marshal(map[string]bool{"success": false})
+		writeI3Message(conn, i3IPCSubscribe, reply)
+	}()
+
+	if _, err := NewI3Tracker(path).WatchFocus(make(chan struct{})); err == nil {
+		t.Error("WatchFocus succeeded although the subscription was refused")
+	}
+}
+
+func TestI3TrackerFullscreenSplit(t *testing.T) {
+	// The focused window is in a split that was made fullscreen
+	tree := `{
+		"id": 1, "type": "root", "nodes": [
+			{"id": 3, "type": "workspace", "nodes": [
+				{"id": 7, "type": "con", "fullscreen_mode": 1, "nodes": [
+					{"id": 5, "type": "con", "name": "Film - mpv", "app_id": "mpv", "focused": true}
+				]}
+			]}
+		]
+	}`
+	events := []string{
+		`{"change": "focus", "container": {"id": 5, "name": "Film - mpv", "app_id": "mpv", "focused": true}}`,
+	}
+	tracker := NewI3Tracker(fakeI3Server(t, tree, events))
+
+	info, err := tracker.GetActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Fullscreen {
+		t.Error("GetActiveWindow() isn't fullscreen inside a fullscreen split")
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	focus, err := tracker.WatchFocus(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case info := <-focus:
+		if info == nil || !info.Fullscreen {
+			t.Errorf("focus event %+v isn't fullscreen inside a fullscreen split", info)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no focus event")
+	}
+}
+
+func TestI3TrackerWatchFocusEnds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ipc.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	before := runtime.NumGoroutine()
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		// The window manager goes away right after subscribing
+		readI3Message(conn)
+		writeI3Message(conn, i3IPCSubscribe, []byte(`{"success": true}`))
+		conn.Close()
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	focus, err := NewI3Tracker(path).WatchFocus(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case _, ok := <-focus:
+		if ok {
+			t.Fatal("got a focus event from a closed connection")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("focus events weren't closed")
+	}
+	<-served
+
+	// Nothing is left waiting for done, which is still open
+	for deadline := time.Now().Add(2 * time.Second); runtime.NumGoroutine() > before; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left running after the events ended, had %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// X11Tracker reads the active window straight from the X server using the
+// EWMH properties set by the window manager.
+type X11Tracker struct {
+	display string
+
+	// mu guards conn, which is shared by polling and focus events
+	mu    sync.Mutex
+	conn  *x11Conn
+	atoms map[string]uint32
+}
+
+// Atoms the tracker needs, interned once per connection
+var x11TrackerAtoms = []string{
+	"_NET_ACTIVE_WINDOW",
+	"_NET_WM_NAME",
+	"_NET_WM_PID",
+	"WM_NAME",
+	"WM_CLASS",
+	"_NET_WM_STATE",
+	"_NET_WM_STATE_FULLSCREEN",
+}
+
+func NewX11Tracker(display string) *X11Tracker {
+	return &X11Tracker{display: display}
+}
+
+// connect lazily (re)establishes the connection. Callers must hold mu.
+func (t *X11Tracker) connect() error {
+	if t.conn != nil {
+		return nil
+	}
+	conn, err := dialX11(t.display)
+	if err != nil {
+		return err
+	}
+	atoms, err := internAtoms(conn, x11TrackerAtoms)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	t.conn = conn
+	t.atoms = atoms
+	return nil
+}
+
+func (t *X11Tracker) disconnect() {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+}
+
+func internAtoms(conn *x11Conn, names []string) (map[string]uint32, error) {
+	atoms := make(map[string]uint32, len(names))
+	for _, name := range names {
+		atom, err := conn.internAtom(name)
+		if err != nil {
+			return nil, err
+		}
+		atoms[name] = atom
+	}
+	return atoms, nil
+}
+
+func (t *X11Tracker) GetActiveWindow() (*WindowInfo, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.connect(); err != nil {
+		return nil, err
+	}
+	windowInfo, err := t.activeWindow()
+	if err != nil {
+		// Drop the connection so the next call starts fresh
+		t.disconnect()
+		return nil, err
+	}
+	return windowInfo, nil
+}
+
+func (t *X11Tracker) activeWindow() (*WindowInfo, error) {
+	window, ok, err := t.conn.getPropertyUint32(t.conn.root, t.atoms["_NET_ACTIVE_WINDOW"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active window ID: %w", err)
+	}
+	if !ok || window == 0 {
+		return nil, errors.New("no active window found")
+	}
+
+	// Prefer the UTF-8 EWMH title, falling back to the legacy WM_NAME
+	title := ""
+	if value, _, err := t.conn.getProperty(window, t.atoms["_NET_WM_NAME"]); err == nil && len(value) > 0 {
+		title = string(value)
+	} else if value, _, err := t.conn.getProperty(window, t.atoms["WM_NAME"]); err == nil {
+		title = string(value)
+	}
+
+	pid := ""
+	if value, ok, err := t.conn.getPropertyUint32(window, t.atoms["_NET_WM_PID"]); err == nil && ok && value != 0 {
+		pid = strconv.FormatUint(uint64(value), 10)
+	}
+
+	// WM_CLASS is "instance\0class\0"; the class is the second string
+	class := ""
+	if value, _, err := t.conn.getProperty(window, t.atoms["WM_CLASS"]); err == nil {
+		parts := bytes.Split(bytes.TrimRight(value, "\x00"), []byte{0})
+		if len(parts) >= 2 {
+			class = string(parts[1])
+		}
+	}
+
+	process := processNameFromPID(pid)
+	if process == "" {
+		process = strings.ToLower(class)
+	}
+
+	return &WindowInfo{
+		Title:      strings.TrimSpace(title),
+		Process:    process,
+		PID:        pid,
+		Class:      class,
+		Fullscreen: t.hasState(window, t.atoms["_NET_WM_STATE_FULLSCREEN"]),
+	}, nil
+}
+
+// hasState looks for an atom in the window's _NET_WM_STATE list.
+func (t *X11Tracker) hasState(window, state uint32) bool {
+	value, format, err := t.conn.getProperty(window, t.atoms["_NET_WM_STATE"])
+	if err != nil || format != 32 {
+		return false
+	}
+	for i := 0; i+4 <= len(value); i += 4 {
+		if binary.LittleEndian.Uint32(value[i:]) == state {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchFocus listens for PropertyNotify events on a dedicated connection.
+// Changes to _NET_ACTIVE_WINDOW on the root window signal focus moving, and
+// changes to the title or fullscreen state of the active window are
+// reported as well.
+func (t *X11Tracker) WatchFocus(done <-chan struct{}) (<-chan *WindowInfo, error) {
+	conn, err := dialX11(t.display)
+	if err != nil {
+		return nil, err
+	}
+	atoms, err := internAtoms(conn, x11TrackerAtoms)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.selectEvents(conn.root, x11PropertyChangeMask); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to select root window events: %w", err)
+	}
+
+	// Closing the connection ends a read blocked on it; the reader going
+	// away on its own ends the wait for done
+	events := make(chan *WindowInfo)
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+		case <-stopped:
+		}
+		conn.Close()
+	}()
+	go func() {
+		defer close(events)
+		defer close(stopped)
+
+		// Follow title changes on whichever window has focus
+		activeWindow := uint32(0)
+		if window, ok, err := t.activeWindowID(); err == nil && ok && window != 0 {
+			conn.selectEvents(window, x11PropertyChangeMask)
+			activeWindow = window
+		}
+
+		for {
+			event, err := conn.readEvent()
+			if err != nil {
+				return
+			}
+			if event[0]&0x7f != x11EventPropertyNotify {
+				continue
+			}
+			window := binary.LittleEndian.Uint32(event[4:])
+			atom := binary.LittleEndian.Uint32(event[8:])
+
+			switch {
+			case window == conn.root && atom == atoms["_NET_ACTIVE_WINDOW"]:
+			case window == activeWindow && (atom == atoms["_NET_WM_NAME"] || atom == atoms["WM_NAME"] || atom == atoms["_NET_WM_STATE"]):
+			default:
+				continue
+			}
+
+			windowInfo, err := t.GetActiveWindow()
+			if err != nil {
+				continue
+			}
+
+			if window == conn.root {
+				newActive, ok, err := t.activeWindowID()
+				if err == nil && ok && newActive != activeWindow {
+					if activeWindow != 0 {
+						conn.selectEvents(activeWindow, 0)
+					}
+					conn.selectEvents(newActive, x11PropertyChangeMask)
+					activeWindow = newActive
+				}
+			}
+
+			select {
+			case events <- windowInfo:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+func (t *X11Tracker) activeWindowID() (uint32, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.connect(); err != nil {
+		return 0, false, err
+	}
+	return t.conn.getPropertyUint32(t.conn.root, t.atoms["_NET_ACTIVE_WINDOW"])
+}
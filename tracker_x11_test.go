@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Predefined X11 atoms used as property types
+const (
+	x11AtomAtom   = 4
+	x11AtomString = 31
+	x11AtomWindow = 33
+)
+
+const x11OpChangeProperty = 18
+
+// startXvfb runs a virtual X server for the test and returns its display,
+// skipping the test when Xvfb isn't installed.
+func startXvfb(t *testing.T) string {
+	t.Helper()
+	path, err := exec.LookPath("Xvfb")
+	if err != nil {
+		t.Skip("Xvfb not found")
+	}
+
+	// Xvfb picks a free display and writes its number to fd 3
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	cmd := exec.Command(path, "-displayfd", "3", "-nolisten", "tcp", "-screen", "0", "640x480x24")
+	cmd.ExtraFiles = []*os.File{w}
+	if err := cmd.Start(); err != nil {
+		w.Close()
+		t.Skipf("could not start Xvfb: %v", err)
+	}
+	w.Close()
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	number, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Xvfb didn't report its display: %v", err)
+	}
+	return ":" + strings.TrimSpace(number)
+}
+
+// setProperty does what a window manager would, replacing a property.
+func setProperty(t *testing.T, conn *x11Conn, window, property, typ uint32, format byte, data []byte) {
+	t.Helper()
+	req := make([]byte, 24+len(data)+x11Pad(len(data)))
+	req[0] = x11OpChangeProperty
+	binary.LittleEndian.PutUint32(req[4:], window)
+	binary.LittleEndian.PutUint32(req[8:], property)
+	binary.LittleEndian.PutUint32(req[12:], typ)
+	req[16] = format
+	binary.LittleEndian.PutUint32(req[20:], uint32(len(data)*8/int(format)))
+	copy(req[24:], data)
+	if _, err := conn.send(req); err != nil {
+		t.Fatal(err)
+	}
+	// ChangeProperty has no reply; a round trip makes sure it's done
+	if _, err := conn.internAtom("WM_NAME"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func x11Uint32s(values ...uint32) []byte {
+	data := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.LittleEndian.PutUint32(data[4*i:], value)
+	}
+	return data
+}
+
+// TestX11Tracker plays window manager on a virtual display, making the
+// root window itself the active window.
+func TestX11Tracker(t *testing.T) {
+	display := startXvfb(t)
+
+	wm, err := dialX11(display)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wm.Close()
+	atoms, err := internAtoms(wm, x11TrackerAtoms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := wm.root
+	setProperty(t, wm, root, atoms["WM_CLASS"], x11AtomString, 8, []byte("xterm\x00XTerm\x00"))
+	setProperty(t, wm, root, atoms["_NET_WM_NAME"], x11AtomString, 8, []byte("main.go - vim"))
+	setProperty(t, wm, root, atoms["_NET_WM_STATE"], x11AtomAtom, 32, x11Uint32s(atoms["_NET_WM_STATE_FULLSCREEN"]))
+	setProperty(t, wm, root, atoms["_NET_ACTIVE_WINDOW"], x11AtomWindow, 32, x11Uint32s(root))
+
+	tracker := NewX11Tracker(display)
+	info, err := tracker.GetActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := WindowInfo{Title: "main.go - vim", Process: "xterm", Class: "XTerm", Fullscreen: true}
+	if *info != want {
+		t.Errorf("GetActiveWindow() = %+v, want %+v", *info, want)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	focus, err := tracker.WatchFocus(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The watcher subscribes in the background, so keep changing the
+	// title until it notices
+	setProperty(t, wm, root, atoms["_NET_WM_STATE"], x11AtomAtom, 32, nil)
+	deadline := time.After(5 * time.Second)
+	for {
+		setProperty(t, wm, root, atoms["_NET_WM_NAME"], x11AtomString, 8, []byte("other.go - vim"))
+		setProperty(t, wm, root, atoms["_NET_ACTIVE_WINDOW"], x11AtomWindow, 32, x11Uint32s(root))
+		select {
+		case info, ok := <-focus:
+			if !ok {
+				t.Fatal("focus events closed")
+			}
+			// Earlier events may have come before the title changed
+			if info.Title != "other.go - vim" {
+				continue
+			}
+			if info.Fullscreen {
+				t.Error("still fullscreen after _NET_WM_STATE was cleared")
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("no focus event")
+		}
+	}
+}
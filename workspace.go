@@ -0,0 +1,354 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WorkspaceInfo is what an IDE's project files say about a project.
+type WorkspaceInfo struct {
+	Name      string   // Project name the IDE shows, if set explicitly
+	Languages []string // Candidate languages, most reliable first
+}
+
+// VS Code extensions that only make sense for one language
+var vscodeExtensionLanguages = map[string]string{
+	"golang.go":                             "go",
+	"rust-lang.rust-analyzer":               "rust",
+	"ms-python.python":                      "python",
+	"ms-python.vscode-pylance":              "python",
+	"charliermarsh.ruff":                    "python",
+	"dbaeumer.vscode-eslint":                "javascript",
+	"ms-vscode.cpptools":                    "cpp",
+	"llvm-vs-code-extensions.vscode-clangd": "cpp",
+	"ms-dotnettools.csharp":                 "csharp",
+	"ms-dotnettools.csdevkit":               "csharp",
+	"vscjava.vscode-java-pack":              "java",
+	"redhat.java":                           "java",
+	"fwcd.kotlin":                           "kotlin",
+	"mathiasfrohlich.kotlin":                "kotlin",
+	"scala-lang.scala":                      "scala",
+	"scalameta.metals":                      "scala",
+	"shopify.ruby-lsp":                      "ruby",
+	"rebornix.ruby":                         "ruby",
+	"bmewburn.vscode-intelephense-client":   "php",
+	"xdebug.php-debug":                      "php",
+	"swiftlang.swift-vscode":                "swift",
+	"sswg.swift-lang":                       "swift",
+	"dart-code.dart-code":                   "dart",
+	"dart-code.flutter":                     "dart",
+	"jakebecker.elixir-ls":                  "elixir",
+	"elixir-lsp.elixir-ls":                  "elixir",
+	"erlang-ls.erlang-ls":                   "erlang",
+	"haskell.haskell":                       "haskell",
+	"ocamllabs.ocaml-platform":              "ocaml",
+	"betterthantomorrow.calva":              "clojure",
+	"ziglang.vscode-zig":                    "zig",
+	"sumneko.lua":                           "lua",
+	"julialang.language-julia":              "julia",
+	"timonwong.shellcheck":                  "shell",
+	"jnoortheen.nix-ide":                    "nix",
+	"ms-azuretools.vscode-docker":           "docker",
+	"twxs.cmake":                            "cmake",
+	"bazelbuild.vscode-bazel":               "starlark",
+	"ms-vscode.makefile-tools":              "make",
+	"denoland.vscode-deno":                  "typescript",
+	"ms-vscode.vscode-typescript-next":      "typescript",
+	"vue.volar":                             "typescript",
+	"svelte.svelte-vscode":                  "javascript",
+}
+
+// JetBrains module types in .iml files and SDK types in misc.xml. Generic
+// ones like WEB_MODULE, which GoLand and WebStorm both use, are left out.
+var jetbrainsModuleLanguages = map[string]string{
+	"JAVA_MODULE":   "java",
+	"PYTHON_MODULE": "python",
+	"RUBY_MODULE":   "ruby",
+	"GO_MODULE":     "go",
+	"CPP_MODULE":    "cpp",
+	"RUST_MODULE":   "rust",
+}
+
+var jetbrainsSDKLanguages = map[string]string{
+	"JavaSDK":     "java",
+	"Android SDK": "java",
+	"Kotlin SDK":  "kotlin",
+	"Python SDK":  "python",
+	"RUBY_SDK":    "ruby",
+	"Go SDK":      "go",
+	"Dart SDK":    "dart",
+	"Scala SDK":   "scala",
+}
+
+// ideaXML covers the parts of .idea/misc.xml and .iml files we read.
+type ideaXML struct {
+	Type       string `xml:"type,attr"`
+	Components []struct {
+		Name    string `xml:"name,attr"`
+		JDKType string `xml:"project-jdk-type,attr"`
+		Facets  []struct {
+			Type string `xml:"type,attr"`
+		} `xml:"facet"`
+		OrderEntries []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"orderEntry"`
+	} `xml:"component"`
+}
+
+// readWorkspace gathers the project name and language hints from JetBrains
+// and VS Code project files in dir.
+func readWorkspace(dir string) WorkspaceInfo {
+	var info WorkspaceInfo
+
+	// Explicit file associations are the strongest hint there is
+	vscodeDir := filepath.Join(dir, ".vscode")
+	var settings struct {
+		Associations map[string]interface{} `json:"files.associations"`
+	}
+	if readJSONC(filepath.Join(vscodeDir, "settings.json"), &settings) == nil {
+		info.Languages = append(info.Languages, associationLanguages(settings.Associations)...)
+	}
+
+	name, languages := readJetBrainsProject(dir)
+	info.Name = name
+	info.Languages = append(info.Languages, languages...)
+
+	// A .code-workspace file names a multi-root workspace and can carry
+	// its own settings and recommendations
+	for _, workspace := range readCodeWorkspaces(dir) {
+		if info.Name == "" {
+			info.Name = strings.TrimSuffix(filepath.Base(workspace.path), ".code-workspace")
+		}
+		info.Languages = append(info.Languages, associationLanguages(workspace.Settings.Associations)...)
+		info.Languages = append(info.Languages, workspace.Extensions.languages()...)
+	}
+
+	var extensions vscodeExtensions
+	if readJSONC(filepath.Join(vscodeDir, "extensions.json"), &extensions) == nil {
+		info.Languages = append(info.Languages, extensions.languages()...)
+	}
+
+	return info
+}
+
+// codeWorkspace covers the parts of a .code-workspace file we read.
+type codeWorkspace struct {
+	path    string
+	Folders []struct {
+		Path string `json:"path"`
+	} `json:"folders"`
+	Settings struct {
+		Associations map[string]interface{} `json:"files.associations"`
+	} `json:"settings"`
+	Extensions vscodeExtensions `json:"extensions"`
+}
+
+// readCodeWorkspaces reads the .code-workspace files that dir belongs to.
+// They usually sit at the top of one of their folders or in the directory
+// above them all, so both dir and its parent are looked in.
+func readCodeWorkspaces(dir string) []*codeWorkspace {
+	dir = filepath.Clean(dir)
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.code-workspace"))
+	if parent := filepath.Dir(dir); parent != dir {
+		parentPaths, _ := filepath.Glob(filepath.Join(parent, "*.code-workspace"))
+		paths = append(paths, parentPaths...)
+	}
+
+	var workspaces []*codeWorkspace
+	for _, path := range paths {
+		workspace := &codeWorkspace{path: path}
+		if readJSONC(path, workspace) == nil && workspace.contains(dir) {
+			workspaces = append(workspaces, workspace)
+		}
+	}
+	return workspaces
+}
+
+// contains reports whether dir is in one of the workspace's folders, which
+// are relative to the file unless absolute. One without folders is taken
+// to be about the directory it's in.
+func (w *codeWorkspace) contains(dir string) bool {
+	if len(w.Folders) == 0 {
+		return filepath.Dir(w.path) == dir
+	}
+	for _, folder := range w.Folders {
+		if folder.Path == "" {
+			continue // Remote folders only have a URI
+		}
+		path := folder.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(w.path), path)
+		}
+		if rel, err := filepath.Rel(path, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+type vscodeExtensions struct {
+	Recommendations []string `json:"recommendations"`
+}
+
+func (e vscodeExtensions) languages() []string {
+	var languages []string
+	for _, id := range e.Recommendations {
+		if lang := vscodeExtensionLanguages[strings.ToLower(id)]; lang != "" {
+			languages = append(languages, lang)
+		}
+	}
+	return languages
+}
+
+// associationLanguages lists the language IDs files are associated with,
+// in a stable order.
+func associationLanguages(associations map[string]interface{}) []string {
+	patterns := make([]string, 0, len(associations))
+	for pattern := range associations {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	languages := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		languages = append(languages, fmt.Sprintf("%v", associations[pattern]))
+	}
+	return languages
+}
+
+// readJetBrainsProject reads the project name from .idea/.name, or the
+// only module's name, and languages from module types, Kotlin facets and
+// the project SDK.
+func readJetBrainsProject(dir string) (string, []string) {
+	ideaDir := filepath.Join(dir, ".idea")
+	if _, err := os.Stat(ideaDir); err != nil {
+		return "", nil
+	}
+
+	var name string
+	if data, err := os.ReadFile(filepath.Join(ideaDir, ".name")); err == nil {
+		name = strings.TrimSpace(string(data))
+	}
+
+	var languages []string
+	kotlin := false
+	if _, err := os.Stat(filepath.Join(ideaDir, "kotlinc.xml")); err == nil {
+		kotlin = true
+	}
+
+	// Modules live in .idea or next to it
+	modules, _ := filepath.Glob(filepath.Join(ideaDir, "*.iml"))
+	rootModules, _ := filepath.Glob(filepath.Join(dir, "*.iml"))
+	modules = append(modules, rootModules...)
+	if name == "" && len(modules) == 1 {
+		name = strings.TrimSuffix(filepath.Base(modules[0]), ".iml")
+	}
+	for _, module := range modules {
+		var iml ideaXML
+		if readXML(module, &iml) != nil {
+			continue
+		}
+		for _, component := range iml.Components {
+			for _, facet := range component.Facets {
+				if facet.Type == "kotlin-language" {
+					kotlin = true
+				}
+			}
+			for _, entry := range component.OrderEntries {
+				if strings.HasPrefix(entry.Name, "KotlinJavaRuntime") {
+					kotlin = true
+				}
+			}
+		}
+		if lang := jetbrainsModuleLanguages[iml.Type]; lang != "" {
+			languages = append(languages, lang)
+		}
+	}
+
+	var misc ideaXML
+	if readXML(filepath.Join(ideaDir, "misc.xml"), &misc) == nil {
+		for _, component := range misc.Components {
+			if component.Name != "ProjectRootManager" {
+				continue
+			}
+			if lang := jetbrainsSDKLanguages[component.JDKType]; lang != "" {
+				languages = append(languages, lang)
+			}
+		}
+	}
+
+	// Kotlin projects are Java modules on a JDK as far as the rest says
+	if kotlin {
+		languages = append([]string{"kotlin"}, languages...)
+	}
+	return name, languages
+}
+
+func readXML(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(data, v)
+}
+
+// readJSONC reads a VS Code JSON file, which may have comments and
+// trailing commas.
+func readJSONC(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(stripJSONC(data), v)
+}
+
+// stripJSONC removes comments and trailing commas, leaving strings alone.
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString:
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := strings.Index(string(data[i+2:]), "*/")
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+		case c == '}' || c == ']':
+			// Drop a comma left before the closing bracket
+			j := len(out) - 1
+			for j >= 0 && (out[j] == ' ' || out[j] == '\t' || out[j] == '\n' || out[j] == '\r') {
+				j--
+			}
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
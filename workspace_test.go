@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadWorkspaceFolders(t *testing.T) {
+	root := t.TempDir()
+	backend := filepath.Join(root, "backend")
+	frontend := filepath.Join(root, "frontend")
+
+	// One workspace at the top of the backend that pulls in the frontend,
+	// one beside it for something else, and one above them both
+	writeTestFile(t, filepath.Join(backend, "shop.code-workspace"), `{
+		"folders": [{"path": "."}, {"path": "../frontend"}],
+		"extensions": {"recommendations": ["golang.go"]},
+	}`)
+	writeTestFile(t, filepath.Join(backend, "docs.code-workspace"), `{
+		"folders": [{"path": "../docs"}],
+		"extensions": {"recommendations": ["ms-python.python"]},
+	}`)
+	writeTestFile(t, filepath.Join(root, "all.code-workspace"), `{
+		// Folders are relative to this file
+		"folders": [{"path": "frontend"}, {"uri": "vscode-remote://ssh-remote+box/srv"}],
+		"settings": {"files.associations": {"*.tmpl": "html"}},
+	}`)
+	writeTestFile(t, filepath.Join(frontend, "src", "app.ts"), "")
+
+	tests := []struct {
+		dir       string
+		name      string
+		languages []string
+	}{
+		{backend, "shop", []string{"go"}},
+		{frontend, "all", []string{"html"}},
+		{filepath.Join(frontend, "src"), "", nil},
+		{root, "", nil},
+	}
+	for _, tt := range tests {
+		info := readWorkspace(tt.dir)
+		if info.Name != tt.name || !reflect.DeepEqual(info.Languages, tt.languages) {
+			t.Errorf("readWorkspace(%s) = %+v, want name %q and languages %v", tt.dir, info, tt.name, tt.languages)
+		}
+	}
+}
+
+func TestReadWorkspaceWithoutFolders(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "tool.code-workspace"), `{"extensions": {"recommendations": ["rust-lang.rust-analyzer"]}}`)
+
+	info := readWorkspace(dir)
+	if info.Name != "tool" || !reflect.DeepEqual(info.Languages, []string{"rust"}) {
+		t.Errorf("readWorkspace() = %+v, want tool with rust", info)
+	}
+	if info := readWorkspace(filepath.Join(dir, "sub")); info.Name != "" {
+		t.Errorf("readWorkspace(sub) = %+v, want nothing from the parent", info)
+	}
+}
+
+func TestReadJetBrainsProject(t *testing.T) {
+	const javaMisc = `<?xml version="1.0" encoding="UTF-8"?>
+<project version="4">
+  <component name="ExternalStorageConfigurationManager" enabled="true" />
+  <component name="ProjectRootManager" version="2" languageLevel="JDK_17" default="true" project-jdk-name="17" project-jdk-type="JavaSDK">
+    <output url="file://$PROJECT_DIR$/out" />
+  </component>
+</project>`
+
+	tests := []struct {
+		name      string
+		files     map[string]string
+		project   string
+		languages []string
+	}{
+		{
+			"name file beats the module name",
+			map[string]string{
+				".idea/.name":    "Billing Service\n",
+				".idea/misc.xml": javaMisc,
+				"billing.iml":    `<module type="JAVA_MODULE" version="4"><component name="NewModuleRootManager" inherit-compiler-output="true" /></module>`,
+			},
+			"Billing Service",
+			[]string{"java", "java"},
+		},
+		{
+			"only module names the project",
+			map[string]string{
+				".idea/scraper.iml": `<module type="PYTHON_MODULE" version="4" />`,
+				".idea/misc.xml":    `<project version="4"><component name="ProjectRootManager" version="2" project-jdk-name="Python 3.12" project-jdk-type="Python SDK" /></project>`,
+			},
+			"scraper",
+			[]string{"python", "python"},
+		},
+		{
+			"several modules name nothing",
+			map[string]string{
+				".idea/api.iml": `<module type="GO_MODULE" version="4" />`,
+				".idea/web.iml": `<module type="WEB_MODULE" version="4" />`,
+			},
+			"",
+			[]string{"go"},
+		},
+		{
+			"Kotlin facet",
+			map[string]string{
+				".idea/misc.xml": javaMisc,
+				".idea/app.iml": `<module type="JAVA_MODULE" version="4">
+  <component name="FacetManager">
+    <facet type="kotlin-language" name="Kotlin"><configuration version="5" platform="JVM 17" /></facet>
+  </component>
+</module>`,
+			},
+			"app",
+			[]string{"kotlin", "java", "java"},
+		},
+		{
+			"Kotlin runtime library",
+			map[string]string{
+				".idea/workspace.xml": `<project version="4" />`,
+				"app.iml": `<module type="JAVA_MODULE" version="4">
+  <component name="NewModuleRootManager">
+    <orderEntry type="inheritedJdk" />
+    <orderEntry type="library" name="KotlinJavaRuntime" level="project" />
+  </component>
+</module>`,
+			},
+			"app",
+			[]string{"kotlin", "java"},
+		},
+		{
+			"Kotlin compiler settings",
+			map[string]string{
+				".idea/kotlinc.xml": `<project version="4"><component name="KotlinJpsPluginSettings"><option name="version" value="1.9.22" /></component></project>`,
+				".idea/misc.xml":    javaMisc,
+			},
+			"",
+			[]string{"kotlin", "java"},
+		},
+		{
+			"unreadable module",
+			map[string]string{
+				".idea/broken.iml": `<module type="RUST_MODULE"`,
+				".idea/misc.xml":   `<project version="4"><component name="ProjectRootManager" project-jdk-type="Go SDK" /></project>`,
+			},
+			"broken",
+			[]string{"go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for path, content := range tt.files {
+				writeTestFile(t, filepath.Join(dir, path), content)
+			}
+
+			project, languages := readJetBrainsProject(dir)
+			if project != tt.project || !reflect.DeepEqual(languages, tt.languages) {
+				t.Errorf("readJetBrainsProject() = %q, %v, want %q, %v", project, languages, tt.project, tt.languages)
+			}
+		})
+	}
+
+	// Module files alone aren't a JetBrains project
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "app.iml"), `<module type="JAVA_MODULE" version="4" />`)
+	if project, languages := readJetBrainsProject(dir); project != "" || languages != nil {
+		t.Errorf("readJetBrainsProject() = %q, %v without .idea, want nothing", project, languages)
+	}
+}
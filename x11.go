@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A minimal X11 protocol client: just enough to read window properties and
+// listen for PropertyNotify events without depending on Xlib or xdotool.
+// Everything is sent in little-endian byte order.
+
+// X11 core request opcodes
+const (
+	x11OpChangeWindowAttributes = 2
+	x11OpInternAtom             = 16
+	x11OpGetProperty            = 20
+	x11OpQueryExtension         = 98
+)
+
+const (
+	x11EventPropertyNotify = 28
+
+	x11CWEventMask          = 1 << 11
+	x11PropertyChangeMask   = 1 << 22
+	x11AnyPropertyType      = 0
+	x11MaxPropertyLongCount = 1 << 16
+)
+
+type x11Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	root   uint32
+	seq    uint16
+
+	// Events read while waiting for a reply, handed out by readEvent
+	pending [][]byte
+}
+
+// dialX11 connects to the display named by a $DISPLAY string and performs
+// the connection setup handshake.
+func dialX11(display string) (*x11Conn, error) {
+	if display == "" {
+		return nil, errors.New("DISPLAY is not set")
+	}
+
+	host, displayNum, screenNum, err := parseX11Display(display)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+	if host == "" || host == "unix" {
+		socketPath := fmt.Sprintf("/tmp/.X11-unix/X%d", displayNum)
+		conn, err = net.DialTimeout("unix", socketPath, 2*time.Second)
+		if err != nil {
+			// Some setups only listen on the abstract socket
+			conn, err = net.DialTimeout("unix", "@"+socketPath, 2*time.Second)
+		}
+	} else {
+		conn, err = net.DialTimeout("tcp", fmt.Sprintf("%s:%d", host, 6000+displayNum), 2*time.Second)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to display %s: %w", display, err)
+	}
+
+	c := &x11Conn{conn: conn, reader: bufio.NewReader(conn)}
+	authName, authData := readXauthority(host, displayNum)
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if err := c.setup(authName, authData, screenNum); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// parseX11Display splits "host:display.screen" into its parts.
+func parseX11Display(display string) (string, int, int, error) {
+	idx := strings.LastIndex(display, ":")
+	if idx < 0 {
+		return "", 0, 0, fmt.Errorf("invalid DISPLAY %q", display)
+	}
+	host := display[:idx]
+	rest := display[idx+1:]
+
+	screenNum := 0
+	if dot := strings.Index(rest, "."); dot >= 0 {
+		screen, err := strconv.Atoi(rest[dot+1:])
+		if err != nil {
+			return "", 0, 0, fmt.Errorf("invalid DISPLAY %q", display)
+		}
+		screenNum = screen
+		rest = rest[:dot]
+	}
+	displayNum, err := strconv.Atoi(rest)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid DISPLAY %q", display)
+	}
+	return host, displayNum, screenNum, nil
+}
+
+// readXauthority looks up the MIT-MAGIC-COOKIE-1 for a display in the
+// Xauthority file. Missing cookies are not an error; many servers accept
+// local connections without one.
+func readXauthority(host string, displayNum int) (string, []byte) {
+	path := os.Getenv("XAUTHORITY")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", nil
+		}
+		path = filepath.Join(home, ".Xauthority")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil
+	}
+
+	if host == "" || host == "unix" {
+		host, _ = os.Hostname()
+	}
+	number := strconv.Itoa(displayNum)
+
+	readField := func() ([]byte, bool) {
+		if len(data) < 2 {
+			return nil, false
+		}
+		n := int(binary.BigEndian.Uint16(data))
+		if len(data) < 2+n {
+			return nil, false
+		}
+		field := data[2 : 2+n]
+		data = data[2+n:]
+		return field, true
+	}
+
+	for len(data) >= 2 {
+		family := binary.BigEndian.Uint16(data)
+		data = data[2:]
+		address, ok1 := readField()
+		num, ok2 := readField()
+		name, ok3 := readField()
+		cookie, ok4 := readField()
+		if !ok1 || !ok2 || !ok3 || !ok4 {
+			break
+		}
+
+		// 256 is FamilyLocal, 65535 is FamilyWild
+		if family != 65535 && (family != 256 || string(address) != host) {
+			continue
+		}
+		if len(num) > 0 && string(num) != number {
+			continue
+		}
+		if string(name) == "MIT-MAGIC-COOKIE-1" {
+			return string(name), cookie
+		}
+	}
+	return "", nil
+}
+
+func x11Pad(n int) int {
+	return (4 - n%4) % 4
+}
+
+func (c *x11Conn) setup(authName string, authData []byte, screenNum int) error {
+	req := make([]byte, 12, 12+len(authName)+x11Pad(len(authName))+len(authData)+x11Pad(len(authData)))
+	req[0] = 'l'
+	binary.LittleEndian.PutUint16(req[2:], 11) // protocol major version
+	binary.LittleEndian.PutUint16(req[4:], 0)  // protocol minor version
+	binary.LittleEndian.PutUint16(req[6:], uint16(len(authName)))
+	binary.LittleEndian.PutUint16(req[8:], uint16(len(authData)))
+	req = append(req, authName...)
+	req = append(req, make([]byte, x11Pad(len(authName)))...)
+	req = append(req, authData...)
+	req = append(req, make([]byte, x11Pad(len(authData)))...)
+	if _, err := c.conn.Write(req); err != nil {
+		return fmt.Errorf("failed to send X11 setup: %w", err)
+	}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return fmt.Errorf("failed to read X11 setup reply: %w", err)
+	}
+	body := make([]byte, int(binary.LittleEndian.Uint16(header[6:]))*4)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return fmt.Errorf("failed to read X11 setup reply: %w", err)
+	}
+	if header[0] != 1 {
+		reason := body
+		if header[0] == 0 && int(header[1]) <= len(body) {
+			reason = body[:header[1]]
+		}
+		return fmt.Errorf("X11 connection refused: %s", strings.TrimSpace(string(reason)))
+	}
+
+	if len(body) < 32 {
+		return errors.New("X11 setup reply too short")
+	}
+	vendorLen := int(binary.LittleEndian.Uint16(body[16:]))
+	numScreens := int(body[20])
+	numFormats := int(body[21])
+	if screenNum >= numScreens {
+		return fmt.Errorf("X11 screen %d does not exist", screenNum)
+	}
+
+	// Skip the vendor string and pixmap formats to reach the screen list,
+	// then walk the variable-length screens up to the one we want
+	offset := 32 + vendorLen + x11Pad(vendorLen) + 8*numFormats
+	for i := 0; ; i++ {
+		if offset+40 > len(body) {
+			return errors.New("X11 setup reply truncated")
+		}
+		if i == screenNum {
+			c.root = binary.LittleEndian.Uint32(body[offset:])
+			return nil
+		}
+		numDepths := int(body[offset+39])
+		offset += 40
+		for d := 0; d < numDepths; d++ {
+			if offset+8 > len(body) {
+				return errors.New("X11 setup reply truncated")
+			}
+			numVisuals := int(binary.LittleEndian.Uint16(body[offset+2:]))
+			offset += 8 + 24*numVisuals
+		}
+	}
+}
+
+func (c *x11Conn) Close() error {
+	return c.conn.Close()
+}
+
+// send writes a request and returns its sequence number.
+func (c *x11Conn) send(req []byte) (uint16, error) {
+	binary.LittleEndian.PutUint16(req[2:], uint16(len(req)/4))
+	if _, err := c.conn.Write(req); err != nil {
+		return 0, err
+	}
+	c.seq++
+	return c.seq, nil
+}
+
+// readPacket reads one reply, error or event from the server.
+func (c *x11Conn) readPacket() ([]byte, error) {
+	packet := make([]byte, 32)
+	if _, err := io.ReadFull(c.reader, packet); err != nil {
+		return nil, err
+	}
+	// Replies may carry extra data beyond the fixed 32 bytes
+	if packet[0] == 1 {
+		extra := int(binary.LittleEndian.Uint32(packet[4:])) * 4
+		if extra > 0 {
+			packet = append(packet, make([]byte, extra)...)
+			if _, err := io.ReadFull(c.reader, packet[32:]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return packet, nil
+}
+
+// roundTrip sends a request and waits for its reply.
+func (c *x11Conn) roundTrip(req []byte) ([]byte, error) {
+	seq, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	for {
+		packet, err := c.readPacket()
+		if err != nil {
+			return nil, err
+		}
+		packetSeq := binary.LittleEndian.Uint16(packet[2:])
+		switch packet[0] {
+		case 0:
+			if packetSeq == seq {
+				return nil, fmt.Errorf("X11 request failed with error code %d", packet[1])
+			}
+		case 1:
+			if packetSeq == seq {
+				return packet, nil
+			}
+		default:
+			c.pending = append(c.pending, packet)
+		}
+	}
+}
+
+// readEvent blocks until the next event arrives. Errors from requests that
+// have no reply are skipped.
+func (c *x11Conn) readEvent() ([]byte, error) {
+	for {
+		if len(c.pending) > 0 {
+			event := c.pending[0]
+			c.pending = c.pending[1:]
+			return event, nil
+		}
+		packet, err := c.readPacket()
+		if err != nil {
+			return nil, err
+		}
+		if packet[0] > 1 {
+			return packet, nil
+		}
+	}
+}
+
+func (c *x11Conn) internAtom(name string) (uint32, error) {
+	req := make([]byte, 8+len(name)+x11Pad(len(name)))
+	req[0] = x11OpInternAtom
+	req[1] = 0 // create the atom if it doesn't exist yet
+	binary.LittleEndian.PutUint16(req[4:], uint16(len(name)))
+	copy(req[8:], name)
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to intern atom %s: %w", name, err)
+	}
+	return binary.LittleEndian.Uint32(reply[8:]), nil
+}
+
+// getProperty returns the raw value of a window property along with its
+// format (8, 16 or 32). A missing property yields a nil value.
+func (c *x11Conn) getProperty(window, property uint32) ([]byte, byte, error) {
+	req := make([]byte, 24)
+	req[0] = x11OpGetProperty
+	binary.LittleEndian.PutUint32(req[4:], window)
+	binary.LittleEndian.PutUint32(req[8:], property)
+	binary.LittleEndian.PutUint32(req[12:], x11AnyPropertyType)
+	binary.LittleEndian.PutUint32(req[16:], 0)
+	binary.LittleEndian.PutUint32(req[20:], x11MaxPropertyLongCount)
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	format := reply[1]
+	count := int(binary.LittleEndian.Uint32(reply[16:]))
+	size := count * int(format) / 8
+	if format == 0 || 32+size > len(reply) {
+		return nil, format, nil
+	}
+	return reply[32 : 32+size], format, nil
+}
+
+// getPropertyUint32 reads the first item of a 32-bit property such as a
+// WINDOW or CARDINAL.
+func (c *x11Conn) getPropertyUint32(window, property uint32) (uint32, bool, error) {
+	value, format, err := c.getProperty(window, property)
+	if err != nil {
+		return 0, false, err
+	}
+	if format != 32 || len(value) < 4 {
+		return 0, false, nil
+	}
+	return binary.LittleEndian.Uint32(value), true, nil
+}
+
+// selectEvents replaces the event mask this client has on a window.
+func (c *x11Conn) selectEvents(window, mask uint32) error {
+	req := make([]byte, 16)
+	req[0] = x11OpChangeWindowAttributes
+	binary.LittleEndian.PutUint32(req[4:], window)
+	binary.LittleEndian.PutUint32(req[8:], x11CWEventMask)
+	binary.LittleEndian.PutUint32(req[12:], mask)
+	_, err := c.send(req)
+	return err
+}
+
+// queryExtension returns the major opcode of an extension, or false if the
+// server doesn't support it.
+func (c *x11Conn) queryExtension(name string) (byte, bool, error) {
+	req := make([]byte, 8+len(name)+x11Pad(len(name)))
+	req[0] = x11OpQueryExtension
+	binary.LittleEndian.PutUint16(req[4:], uint16(len(name)))
+	copy(req[8:], name)
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return 0, false, err
+	}
+	return reply[9], reply[8] != 0, nil
+}